@@ -3,7 +3,9 @@ package main
 import (
 	"io"
 
+	"go.dedis.ch/kyber/v3"
 	kyber_encoding "go.dedis.ch/kyber/v3/util/encoding"
+	kyber_key "go.dedis.ch/kyber/v3/util/key"
 	onet_network "go.dedis.ch/onet/v3/network"
 
 	"github.com/ldsec/drynx/cmd"
@@ -12,18 +14,29 @@ import (
 	"github.com/pelletier/go-toml"
 )
 
+type configAssignment struct {
+	DataProvider  onet_network.Address
+	ComputingNode onet_network.Address
+}
 type configNetwork struct {
-	Client *onet_network.ServerIdentity
-	Nodes  []onet_network.ServerIdentity
+	Client      *onet_network.ServerIdentity
+	Nodes       []onet_network.ServerIdentity
+	Assignments []configAssignment
+	// collective key of the computing nodes, generated by them to be used with any threshold of them
+	ThresholdKey kyber.Point
 }
 type configSurvey struct {
-	Name      *string
-	Operation *cmd.Operation
-	Sources   *[]libdrynx.ColumnID
+	Name       *string
+	Operation  *cmd.Operation
+	Operations *[]cmd.Operation
+	Sources    *[]libdrynx.ColumnID
+	Dataset    *string
+	LocalDiffP *libdrynx.QueryLocalDiffP
 }
 type config struct {
 	Network *configNetwork
 	Survey  *configSurvey
+	Querier *kyber_key.Pair
 }
 
 type serverIdentityStr struct {
@@ -34,12 +47,19 @@ type clientIdentityStr struct {
 	URL string
 }
 type configNetworkStr struct {
-	Client *clientIdentityStr
-	Nodes  []serverIdentityStr
+	Client       *clientIdentityStr
+	Nodes        []serverIdentityStr
+	Assignments  []configAssignment
+	ThresholdKey string `toml:",omitempty"`
+}
+type keyPairStr struct {
+	Public  string
+	Private string
 }
 type configStr struct {
 	Network *configNetworkStr
 	Survey  *configSurvey
+	Querier *keyPairStr
 }
 
 func serverIdentityToUnsafe(id onet_network.ServerIdentity) (serverIdentityStr, error) {
@@ -72,7 +92,15 @@ func (conf configNetwork) toUnsafe() (configNetworkStr, error) {
 		}
 	}
 
-	return configNetworkStr{client, nodes}, nil
+	var thresholdKey string
+	if conf.ThresholdKey != nil {
+		var err error
+		if thresholdKey, err = kyber_encoding.PointToStringHex(libdrynx.Suite, conf.ThresholdKey); err != nil {
+			return configNetworkStr{}, err
+		}
+	}
+
+	return configNetworkStr{client, nodes, conf.Assignments, thresholdKey}, nil
 }
 
 func (conf configNetworkStr) toSafe() (configNetwork, error) {
@@ -90,7 +118,39 @@ func (conf configNetworkStr) toSafe() (configNetwork, error) {
 		nodes[i] = *onet_network.NewServerIdentity(point, n.Address)
 	}
 
-	return configNetwork{client, nodes}, nil
+	var thresholdKey kyber.Point
+	if conf.ThresholdKey != "" {
+		var err error
+		if thresholdKey, err = kyber_encoding.StringHexToPoint(libdrynx.Suite, conf.ThresholdKey); err != nil {
+			return configNetwork{}, err
+		}
+	}
+
+	return configNetwork{client, nodes, conf.Assignments, thresholdKey}, nil
+}
+
+func keyPairToUnsafe(kp kyber_key.Pair) (keyPairStr, error) {
+	pub, err := kyber_encoding.PointToStringHex(libdrynx.Suite, kp.Public)
+	if err != nil {
+		return keyPairStr{}, err
+	}
+	priv, err := kyber_encoding.ScalarToStringHex(libdrynx.Suite, kp.Private)
+	if err != nil {
+		return keyPairStr{}, err
+	}
+	return keyPairStr{pub, priv}, nil
+}
+
+func (kp keyPairStr) toSafe() (kyber_key.Pair, error) {
+	pub, err := kyber_encoding.StringHexToPoint(libdrynx.Suite, kp.Public)
+	if err != nil {
+		return kyber_key.Pair{}, err
+	}
+	priv, err := kyber_encoding.StringHexToScalar(libdrynx.Suite, kp.Private)
+	if err != nil {
+		return kyber_key.Pair{}, err
+	}
+	return kyber_key.Pair{Public: pub, Private: priv}, nil
 }
 
 func readConfigFrom(r io.Reader) (config, error) {
@@ -108,7 +168,17 @@ func readConfigFrom(r io.Reader) (config, error) {
 		}
 		network = &networkStruct
 	}
-	return config{network, conf.Survey}, nil
+
+	var querier *kyber_key.Pair
+	if conf.Querier != nil {
+		querierStruct, err := conf.Querier.toSafe()
+		if err != nil {
+			return config{}, err
+		}
+		querier = &querierStruct
+	}
+
+	return config{network, conf.Survey, querier}, nil
 }
 
 func (conf config) writeTo(w io.Writer) error {
@@ -120,6 +190,16 @@ func (conf config) writeTo(w io.Writer) error {
 		}
 		network = &networkStruct
 	}
-	conv := configStr{network, conf.Survey}
+
+	var querier *keyPairStr
+	if conf.Querier != nil {
+		querierStruct, err := keyPairToUnsafe(*conf.Querier)
+		if err != nil {
+			return err
+		}
+		querier = &querierStruct
+	}
+
+	conv := configStr{network, conf.Survey, querier}
 	return toml.NewEncoder(w).Encode(&conv)
 }
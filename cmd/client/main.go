@@ -7,6 +7,9 @@ import (
 
 	onet_log "go.dedis.ch/onet/v3/log"
 
+	libdrynxdecryption "github.com/ldsec/drynx/lib/decryption"
+	"github.com/ldsec/drynx/services"
+
 	"github.com/urfave/cli"
 )
 
@@ -29,7 +32,35 @@ func main() {
 	then, you can launch a given survey on a given network
 		cat $my_network_config $my_survey_config |
 			%[1]s survey run
-	`, "\t", "   ", -1)), os.Args[0])
+	several operations can be run over the same sources in a single survey, each printed on its own line
+		%[1]s survey new my-survey |
+			%[1]s survey set-sources my-column |
+			%[1]s survey add-operation sum |
+			%[1]s survey add-operation --range 0,9 frequencyCount >
+			$my_survey_config
+	the data providers are spread over the computing nodes which are up, unless
+	assigned to one of them in the network config
+		%[1]s network assign 1.drynx.c4dt.org 2.drynx.c4dt.org < $my_network_config
+	if the nodes only allow known queriers, sign the surveys with a querier key
+		%[1]s querier new > $my_querier_config
+		%[1]s querier public < $my_querier_config
+		cat $my_network_config $my_survey_config $my_querier_config |
+			%[1]s survey run
+	for the surveys to succeed with only some of the computing nodes, have them generate
+	a collective key which any threshold of them can use, without proofs nor differential privacy
+		%[1]s network dkg --threshold 2 < $my_network_config > $my_threshold_network_config
+	to check what the nodes are doing, also served as JSON under %[2]s for monitoring
+		%[1]s network status < $my_network_config
+	the results of all the groups are printed as a table, with the data providers they come from
+		cat $my_network_config $my_survey_config |
+			%[1]s survey run --format table
+	results beyond the default bounds, such as large counts, are decrypted with a larger max value
+		cat $my_network_config $my_survey_config |
+			%[1]s survey run --max-value 10000000000000
+	a survey taking too long can be stopped, with the same configs
+		cat $my_network_config $my_survey_config |
+			%[1]s survey cancel
+	`, "\t", "   ", -1)), os.Args[0], services.StatusPath)
 
 	app.Commands = []cli.Command{{
 		Name:  "network",
@@ -48,6 +79,39 @@ func main() {
 			ArgsUsage: "host:client-port",
 			Usage:     "on a network config stream, set the client to send the survey query to",
 			Action:    networkSetClient,
+		}, {
+			Name:      "assign",
+			ArgsUsage: "host:data-provider-port host:computing-node-port",
+			Usage:     "on a network config stream, fix the computing node of a data provider, instead of the one assigned automatically",
+			Action:    networkAssign,
+		}, {
+			Name:   "dkg",
+			Flags:  []cli.Flag{cli.IntFlag{Name: "threshold", Usage: "number of computing nodes needed to switch the results, by default all of them"}},
+			Usage:  "on a network config stream, have the computing nodes up generate a collective key, usable by any threshold of them",
+			Action: networkDKG,
+		}, {
+			Name:   "list-datasets",
+			Usage:  "sink of a network stream, list the datasets served by each node",
+			Action: networkListDatasets,
+		}, {
+			Name:   "list-surveys",
+			Usage:  "sink of a network stream, list the surveys held by each node, with their state for each of its roles",
+			Action: networkListSurveys,
+		}, {
+			Name:   "status",
+			Usage:  "sink of a network stream, describe what each node is doing, or that it is down",
+			Action: networkStatus,
+		}}}, {
+		Name:  "querier",
+		Usage: "querier identity",
+		Subcommands: []cli.Command{{
+			Name:   "new",
+			Usage:  "generate a querier config with the key pair signing the surveys, start a querier config stream",
+			Action: querierNew,
+		}, {
+			Name:   "public",
+			Usage:  "sink of a config stream, print the querier's public key, to be allowed by the nodes",
+			Action: querierPublic,
 		}}}, {
 		Name:  "survey",
 		Usage: "network operations",
@@ -68,11 +132,44 @@ func main() {
 			// TODO use op generated list
 			Usage:  "on a survey config stream, set the operation to use, try sum/mean/count/…",
 			Action: surveySetOperation,
+		}, {
+			Name:      "add-operation",
+			ArgsUsage: "operation",
+			Flags:     []cli.Flag{cli.StringFlag{Name: "range"}},
+			Usage:     "on a survey config stream, add an operation to run in a batch, over the same data collection",
+			Action:    surveyAddOperation,
+		}, {
+			Name:      "set-dataset",
+			ArgsUsage: "dataset",
+			Usage:     "on a survey config stream, set the dataset of the data providers to query",
+			Action:    surveySetDataset,
+		}, {
+			Name: "set-local-diffp",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "mechanism", Value: "laplace", Usage: "noise added to values, laplace or gaussian"},
+				cli.Float64Flag{Name: "scale", Usage: "scale of the noise added to values"},
+				cli.Float64Flag{Name: "flip-probability", Usage: "probability to randomize each bit"},
+			},
+			Usage:  "on a survey config stream, ask each data provider to perturb its own results",
+			Action: surveySetLocalDiffP,
 		}, {
 			Name:      "run",
 			ArgsUsage: "client-to-connect public-of-client",
 			Usage:     "sink of a survey and network stream, run the survey on the network",
-			Action:    surveyRun,
+			Flags: []cli.Flag{
+				cli.DurationFlag{Name: "poll", Usage: "submit the survey and poll its status at this interval, instead of waiting on a single request"},
+				cli.DurationFlag{Name: "timeout", Usage: "how long to wait for the data providers, by default for all of them"},
+				cli.Float64Flag{Name: "quorum", Usage: "fraction of the data providers of each computing node which must have answered at the timeout, by default all of them"},
+				cli.StringFlag{Name: "format", Value: "plain", Usage: "plain for the values of a single group, table for the results of all the groups with what they were computed from"},
+				cli.Int64Flag{Name: "table-bound", Value: libdrynxdecryption.DefaultTableBound, Usage: "bound of the values in the decryption table, kept in the user's cache directory"},
+				cli.Int64Flag{Name: "max-value", Value: libdrynxdecryption.DefaultLimit, Usage: "bound of the values of the results, larger ones failing to decrypt"},
+				cli.DurationFlag{Name: "decryption-timeout", Value: libdrynxdecryption.DefaultTimeout, Usage: "how long to search for the value of each output of the results, 0 for no limit"},
+			},
+			Action: surveyRun,
+		}, {
+			Name:   "cancel",
+			Usage:  "sink of a survey and network stream, stop the survey running on the network",
+			Action: surveyCancel,
 		}}}}
 
 	if err := app.Run(os.Args); err != nil {
@@ -2,12 +2,17 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	kyber_util_encoding "go.dedis.ch/kyber/v3/util/encoding"
 	onet_network "go.dedis.ch/onet/v3/network"
 
 	drynx_lib "github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/services"
 
 	"github.com/urfave/cli"
 )
@@ -64,3 +69,202 @@ func networkSetClient(c *cli.Context) error {
 
 	return conf.writeTo(os.Stdout)
 }
+
+func networkAssign(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return errors.New("need a data provider and a computing node")
+	}
+	assignment := configAssignment{
+		DataProvider:  onet_network.NewTCPAddress(args.Get(0)),
+		ComputingNode: onet_network.NewTCPAddress(args.Get(1)),
+	}
+	if assignment.DataProvider == assignment.ComputingNode {
+		return errors.New("a node can't be its own data provider")
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	assignments := []configAssignment{assignment}
+	for _, a := range conf.Network.Assignments {
+		if a.DataProvider != assignment.DataProvider {
+			assignments = append(assignments, a)
+		}
+	}
+	conf.Network.Assignments = assignments
+
+	return conf.writeTo(os.Stdout)
+}
+
+// clientURL is the URL of the client of a node, served by default on the port following the node's one
+func clientURL(node onet_network.ServerIdentity) string {
+	port, err := strconv.Atoi(node.Address.Port())
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("http://%v:%v", node.Address.Host(), port+1)
+}
+
+// connect discovers the nodes which are up and connects to the computing node to send the survey to, the client of the
+// network if it is up, the first computing node up otherwise; this computing node comes first in the returned ones.
+func (conf config) connect() (*services.API, services.Nodes, error) {
+	nodes := services.NewDrynxClient(conf.Network.Client, os.Args[0]).DiscoverNodes(conf.Network.Nodes)
+	if len(nodes.ComputingNodes) == 0 {
+		return nil, services.Nodes{}, errors.New("no computing node up")
+	}
+
+	cns := nodes.ComputingNodes
+	entry := &cns[0]
+	if conf.Network.Client != nil {
+		found := false
+		for i, cn := range cns {
+			if clientURL(cn) == conf.Network.Client.URL {
+				cns[0], cns[i] = cns[i], cns[0]
+				entry, found = conf.Network.Client, true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintln(os.Stderr, "client", conf.Network.Client.URL, "isn't a computing node up, connecting to", cns[0].Address)
+		}
+	}
+
+	return conf.newClient(entry), nodes, nil
+}
+
+func networkDKG(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errors.New("no args expected")
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if conf.Network == nil {
+		return errors.New("need some network config")
+	}
+	client, nodes, err := conf.connect()
+	if err != nil {
+		return err
+	}
+
+	threshold := c.Int("threshold")
+	if threshold == 0 {
+		threshold = len(nodes.ComputingNodes)
+	}
+	if conf.Network.ThresholdKey, err = client.SendStartDKG(nodes.ComputingNodes, threshold); err != nil {
+		return err
+	}
+
+	return conf.writeTo(os.Stdout)
+}
+
+func networkListDatasets(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errors.New("no args expected")
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if conf.Network == nil {
+		return errors.New("need some network config")
+	}
+
+	client := services.NewDrynxClient(conf.Network.Client, os.Args[0])
+	for _, node := range conf.Network.Nodes {
+		node := node
+		names, err := client.SendGetDatasets(&node)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Printf("%v\t%v\n", node.Address, name)
+		}
+	}
+
+	return nil
+}
+
+func networkListSurveys(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errors.New("no args expected")
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if conf.Network == nil {
+		return errors.New("need some network config")
+	}
+
+	client := services.NewDrynxClient(conf.Network.Client, os.Args[0])
+	for _, node := range conf.Network.Nodes {
+		node := node
+		surveys, err := client.SendListSurveys(&node)
+		if err != nil {
+			return err
+		}
+		for _, s := range surveys {
+			since := time.Unix(s.Since, 0).Format(time.RFC3339)
+			fmt.Printf("%v\t%v\t%v\t%v\t%v\t%v\n", node.Address, s.SurveyID, s.Role, s.State, since, s.Error)
+		}
+	}
+
+	return nil
+}
+
+func networkStatus(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errors.New("no args expected")
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if conf.Network == nil {
+		return errors.New("need some network config")
+	}
+
+	client := services.NewDrynxClient(conf.Network.Client, os.Args[0])
+	for _, node := range conf.Network.Nodes {
+		node := node
+		status, err := client.SendGetStatus(&node)
+		if err != nil {
+			fmt.Printf("%v\tdown\t%v\n", node.Address, err)
+			continue
+		}
+
+		var roles []string
+		if status.Roles.ComputingNode {
+			roles = append(roles, "computing-node")
+		}
+		if status.Roles.DataProvider {
+			roles = append(roles, "data-provider")
+		}
+		if status.Roles.VerifyingNode {
+			roles = append(roles, "verifying-node")
+		}
+		fmt.Printf("%v\troles\t%v\n", node.Address, strings.Join(roles, ","))
+		fmt.Printf("%v\tuptime\t%v\n", node.Address, time.Duration(status.Uptime)*time.Second)
+		for _, ds := range status.Datasets {
+			fmt.Printf("%v\tdataset\t%v\t%v\t%v\n", node.Address, ds.Name, ds.Loader, ds.Neutralizer)
+		}
+		for _, s := range status.Surveys {
+			fmt.Printf("%v\tsurvey\t%v\t%v\t%v\n", node.Address, s.SurveyID, s.Role, s.State)
+		}
+		if status.SkipchainHead != "" {
+			fmt.Printf("%v\tskipchain\t%v\t%v\n", node.Address, status.SkipchainIndex, status.SkipchainHead)
+		}
+		fmt.Printf("%v\tdb-size\t%v\n", node.Address, status.DBSize)
+	}
+
+	return nil
+}
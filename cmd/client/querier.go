@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	kyber_util_encoding "go.dedis.ch/kyber/v3/util/encoding"
+	kyber_util_key "go.dedis.ch/kyber/v3/util/key"
+	onet_network "go.dedis.ch/onet/v3/network"
+
+	drynx_lib "github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/services"
+
+	"github.com/urfave/cli"
+)
+
+func querierNew(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errors.New("no args expected")
+	}
+
+	conf := config{Querier: kyber_util_key.NewKeyPair(drynx_lib.Suite)}
+
+	return conf.writeTo(os.Stdout)
+}
+
+func querierPublic(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errors.New("no args expected")
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	if conf.Querier == nil {
+		return errors.New("no querier defined")
+	}
+	public, err := kyber_util_encoding.PointToStringHex(drynx_lib.Suite, conf.Querier.Public)
+	if err != nil {
+		return err
+	}
+	fmt.Println(public)
+
+	return nil
+}
+
+// newClient connects to the given entry of the network, signing as the querier if defined.
+func (conf config) newClient(entry *onet_network.ServerIdentity) *services.API {
+	if conf.Querier != nil {
+		return services.NewDrynxClientWithKeys(entry, os.Args[0], conf.Querier)
+	}
+	return services.NewDrynxClient(entry, os.Args[0])
+}
@@ -3,20 +3,24 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/urfave/cli"
 
 	"github.com/ldsec/drynx/cmd"
 	libdrynx "github.com/ldsec/drynx/lib"
+	libdrynxdecryption "github.com/ldsec/drynx/lib/decryption"
 	"github.com/ldsec/drynx/lib/operations"
 	"github.com/ldsec/drynx/services"
 	_ "github.com/ldsec/drynx/services"
-	kyber "go.dedis.ch/kyber/v3"
-	onet "go.dedis.ch/onet/v3"
-	onet_network "go.dedis.ch/onet/v3/network"
 )
 
 func surveyNew(c *cli.Context) error {
@@ -31,21 +35,6 @@ func surveyNew(c *cli.Context) error {
 	return conf.writeTo(os.Stdout)
 }
 
-func getRoster(conf configNetwork) (onet.Roster, error) {
-	ids := make([]*onet_network.ServerIdentity, len(conf.Nodes))
-	for i, e := range conf.Nodes {
-		e := e
-		ids[i] = &e
-	}
-
-	rosterRaw := onet.NewRoster(ids)
-	if rosterRaw == nil {
-		return onet.Roster{}, errors.New("unable to gen roster based on config")
-	}
-
-	return *rosterRaw, nil
-}
-
 func surveySetSources(c *cli.Context) error {
 	args := c.Args()
 	if len(args) == 0 {
@@ -66,6 +55,31 @@ func surveySetSources(c *cli.Context) error {
 	return conf.writeTo(os.Stdout)
 }
 
+// parseRange parses the range flag of the operation commands, if set.
+func parseRange(c *cli.Context) (*cmd.Range, error) {
+	rawRange := c.String("range")
+	if rawRange == "" {
+		return nil, nil
+	}
+
+	splitted := strings.SplitN(rawRange, ",", 2)
+	if len(splitted) != 2 {
+		return nil, errors.New("range should be ','-separated")
+	}
+
+	min, err := strconv.ParseInt(splitted[0], 10, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := strconv.ParseInt(splitted[1], 10, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cmd.Range{Min: int(min), Max: int(max)}, nil
+}
+
 func surveySetOperation(c *cli.Context) error {
 	args := c.Args()
 	if len(args) != 1 {
@@ -73,24 +87,34 @@ func surveySetOperation(c *cli.Context) error {
 	}
 	name := args[0]
 
-	var parsedRange *cmd.Range
-	if rawRange := c.String("range"); rawRange != "" {
-		splitted := strings.SplitN(rawRange, ",", 2)
-		if len(splitted) != 2 {
-			return errors.New("range should be ','-separated")
-		}
+	parsedRange, err := parseRange(c)
+	if err != nil {
+		return err
+	}
 
-		min, err := strconv.ParseInt(splitted[0], 10, 0)
-		if err != nil {
-			return err
-		}
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
 
-		max, err := strconv.ParseInt(splitted[1], 10, 0)
-		if err != nil {
-			return err
-		}
+	conf.Survey.Operation = &cmd.Operation{
+		Name:  name,
+		Range: parsedRange,
+	}
+
+	return conf.writeTo(os.Stdout)
+}
 
-		parsedRange = &cmd.Range{Min: int(min), Max: int(max)}
+func surveyAddOperation(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need an operation")
+	}
+	name := args[0]
+
+	parsedRange, err := parseRange(c)
+	if err != nil {
+		return err
 	}
 
 	conf, err := readConfigFrom(os.Stdin)
@@ -98,11 +122,66 @@ func surveySetOperation(c *cli.Context) error {
 		return err
 	}
 
-	conf.Survey.Operation = &cmd.Operation{
+	var operations []cmd.Operation
+	if conf.Survey.Operations != nil {
+		operations = *conf.Survey.Operations
+	}
+	operations = append(operations, cmd.Operation{
 		Name:  name,
 		Range: parsedRange,
+	})
+	conf.Survey.Operations = &operations
+
+	return conf.writeTo(os.Stdout)
+}
+
+func surveySetDataset(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need a dataset")
+	}
+	dataset := args[0]
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	conf.Survey.Dataset = &dataset
+
+	return conf.writeTo(os.Stdout)
+}
+
+func surveySetLocalDiffP(c *cli.Context) error {
+	if args := c.Args(); len(args) != 0 {
+		return errors.New("no args expected")
+	}
+
+	localDiffP := libdrynx.QueryLocalDiffP{
+		Mechanism:       c.String("mechanism"),
+		Scale:           c.Float64("scale"),
+		FlipProbability: c.Float64("flip-probability"),
+	}
+	if !libdrynx.AddLocalDiffP(localDiffP) {
+		return errors.New("need a scale or a flip probability")
+	}
+	if localDiffP.Scale < 0 {
+		return errors.New("scale should be positive")
+	}
+	if localDiffP.FlipProbability < 0 || localDiffP.FlipProbability > 1 {
+		return errors.New("flip probability should be between 0 and 1")
+	}
+	if m := localDiffP.Mechanism; m != "laplace" && m != "gaussian" {
+		return fmt.Errorf("unknown mechanism: %v", m)
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
 	}
 
+	conf.Survey.LocalDiffP = &localDiffP
+
 	return conf.writeTo(os.Stdout)
 }
 
@@ -136,15 +215,15 @@ func surveyRun(c *cli.Context) error {
 	if conf.Network == nil {
 		return errors.New("need some network config")
 	}
-	roster, err := getRoster(*conf.Network)
+	client, nodes, err := conf.connect()
 	if err != nil {
 		return err
 	}
-
-	if conf.Network.Client == nil {
-		return errors.New("no client defined")
+	solver, err := decryption(c)
+	if err != nil {
+		return err
 	}
-	client := services.NewDrynxClient(conf.Network.Client, os.Args[0])
+	client.SetDecryption(solver)
 
 	if conf.Survey == nil {
 		return errors.New("need some survey config")
@@ -155,79 +234,239 @@ func surveyRun(c *cli.Context) error {
 	if conf.Survey.Sources == nil {
 		return errors.New("need some survey operation sources")
 	}
-	if conf.Survey.Operation == nil {
-		return errors.New("need a survey operation")
+	if (conf.Survey.Operation == nil) == (conf.Survey.Operations == nil) {
+		return errors.New("need either a survey operation or some survey operations")
 	}
-	opMin, opMax := 0, 0
-	if opRange := conf.Survey.Operation.Range; opRange != nil {
-		opMin, opMax = int(opRange.Min), int(opRange.Max)
-	}
-	operation := libdrynx.ChooseOperation(
-		string(conf.Survey.Operation.Name), // operation
-		opMin,                              // lower bound of range
-		opMax,                              // upper bound of range
-		len(*conf.Survey.Sources)-1,        // dimension for linear regression
-		0)                                  // "cutting factor", how much to remove of gen data[0:#/n]
-	if operation.NbrInput != len(*conf.Survey.Sources) {
-		return errors.New("Operation can't take #Sources")
-	}
-
-	CNsToDPs := make(map[string]*libdrynx.ServerIdentityList)
-	for i, cn := range roster.List {
-		var dps []onet_network.ServerIdentity
-		for j, dp := range roster.List {
-			// TODO can't query itself
-			if i != j {
-				dps = append(dps, *dp)
+	var operation libdrynx.Operation
+	var batch []libdrynx.Operation
+	if conf.Survey.Operation != nil {
+		operation, err = chooseOperation(*conf.Survey.Operation, len(*conf.Survey.Sources))
+		if err != nil {
+			return err
+		}
+	} else {
+		batch = make([]libdrynx.Operation, len(*conf.Survey.Operations))
+		for i, op := range *conf.Survey.Operations {
+			if batch[i], err = chooseOperation(op, len(*conf.Survey.Sources)); err != nil {
+				return err
 			}
 		}
-		CNsToDPs[cn.String()] = &libdrynx.ServerIdentityList{Content: dps}
-	}
-
-	nodeToPublicKeys := make(map[string]kyber.Point)
-	for _, node := range roster.List {
-		nodeToPublicKeys[node.String()] = node.Public
-	}
-
-	query := libdrynx.Query{
-		Operation:   operation,
-		Ranges:      []*libdrynx.Int64List{}, // range for each output of operation
-		Proofs:      int(0),                  // 0 == no proof, 1 == proof, 2 == optimized proof
-		Obfuscation: false,
-		DiffP: libdrynx.QueryDiffP{ // differential privacy
-			LapMean: 0.0, LapScale: 0.0, NoiseListSize: 0, Quanta: 0.0, Scale: 0},
-		IVSigs: libdrynx.QueryIVSigs{
-			InputValidationSigs: make([]*libdrynx.PublishSignatureBytesList, 0),
-		},
-		RosterVNs:     &roster,
-		CuttingFactor: 0,
-		Selector:      *conf.Survey.Sources,
-	}
-
-	_, aggregations, err := client.SendSurveyQuery(libdrynx.SurveyQuery{
-		SurveyID:      *conf.Survey.Name,
-		RosterServers: roster,
-		ServerToDP:    CNsToDPs,         // map CN to DPs
-		IDtoPublic:    nodeToPublicKeys, // map CN|DP|VN to pub key
-
-		Query: query,
-
-		Threshold:                  0,
-		AggregationProofThreshold:  0,
-		ObfuscationProofThreshold:  0,
-		RangeProofThreshold:        0,
-		KeySwitchingProofThreshold: 0,
-	})
+		if operation, err = libdrynx.NewBatchOperation(batch); err != nil {
+			return err
+		}
+	}
+
+	assigned := make(map[string]string, len(conf.Network.Assignments))
+	for _, a := range conf.Network.Assignments {
+		assigned[a.DataProvider.String()] = a.ComputingNode.String()
+	}
+
+	sq, err := client.GenerateSurveyQueryForNodes(nodes, assigned, *conf.Survey.Name, operation,
+		[]*libdrynx.Int64List{}, // range for each output of operation
+		make([]*libdrynx.PublishSignatureBytesList, 0),
+		0,     // 0 == no proof, 1 == proof, 2 == optimized proof
+		false, // obfuscation
+		[]float64{0, 0, 0, 0, 0},
+		libdrynx.QueryDiffP{}, // differential privacy
+		0)
+	if err != nil {
+		return err
+	}
+	sq.Query.Batch = batch
+	sq.ThresholdKey = conf.Network.ThresholdKey
+	sq.Query.Selector = *conf.Survey.Sources
+	if conf.Survey.Dataset != nil {
+		sq.Query.Dataset = *conf.Survey.Dataset
+	}
+	if conf.Survey.LocalDiffP != nil {
+		sq.Query.LocalDiffP = *conf.Survey.LocalDiffP
+	}
+	sq.Timeout = int64(math.Ceil(c.Duration("timeout").Seconds()))
+	sq.DPsQuorum = c.Float64("quorum")
+	if sq.DPsQuorum < 0 || sq.DPsQuorum > 1 {
+		return errors.New("quorum should be between 0 and 1")
+	}
+	format := c.String("format")
+	if format != "plain" && format != "table" {
+		return fmt.Errorf("unknown format <%v>", format)
+	}
+
+	poll := c.Duration("poll")
+	var result *services.SurveyResult
+	if poll > 0 {
+		result, err = runSurveyAsync(client, sq, poll)
+	} else {
+		result, err = client.SendSurveyQuery(sq)
+	}
 	if err != nil {
 		return err
 	}
 
-	if len(*aggregations) != 1 {
+	if format == "table" {
+		return printResult(os.Stdout, result)
+	}
+
+	if len(result.Groups) != 1 {
 		return errors.New("single group expected")
 	}
-	for _, a := range (*aggregations)[0] {
-		fmt.Println(a)
+	if batch != nil {
+		for i, op := range result.Groups[0].Operations {
+			values := make([]string, len(op.Values))
+			for j, v := range op.Values {
+				values[j] = fmt.Sprint(v)
+			}
+			fmt.Printf("%v\t%v\n", (*conf.Survey.Operations)[i].Name, strings.Join(values, " "))
+		}
+		return nil
+	}
+	for _, v := range result.Groups[0].Operations[0].Values {
+		fmt.Println(v)
 	}
 
 	return nil
 }
+
+// printResult prints the result of the survey as a table, with a row for each value of each group, after what the
+// result was computed from.
+func printResult(w io.Writer, result *services.SurveyResult) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "survey\t%v\n", result.SurveyID)
+	fmt.Fprintf(tw, "data providers\t%v\n", strings.Join(result.DPs, " "))
+	if len(result.KeySwitchers) > 0 {
+		fmt.Fprintf(tw, "key switchers\t%v\n", strings.Join(result.KeySwitchers, " "))
+	}
+	if result.Proofs != nil {
+		if result.Proofs.Error != "" {
+			fmt.Fprintf(tw, "proofs\tunknown, %v\n", result.Proofs.Error)
+		} else {
+			outcomes := make([]string, 0, len(result.Proofs.Outcomes))
+			for outcome, count := range result.Proofs.Outcomes {
+				outcomes = append(outcomes, fmt.Sprintf("%v %v", count, outcome))
+			}
+			sort.Strings(outcomes)
+			fmt.Fprintf(tw, "proofs\t%v\n", strings.Join(outcomes, ", "))
+		}
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "GROUP\tOPERATION\tOUTPUT\tVALUE")
+	for _, group := range result.Groups {
+		key := make([]string, len(group.Key))
+		for i, v := range group.Key {
+			key[i] = fmt.Sprint(v)
+		}
+		for _, op := range group.Operations {
+			for i, v := range op.Values {
+				fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", strings.Join(key, ","), op.Operation, op.Names[i], v)
+			}
+		}
+	}
+
+	return tw.Flush()
+}
+
+// decryption creates the solver of the values of the results, with a table kept in the user's cache directory so that
+// it is only computed once.
+func decryption(c *cli.Context) (*libdrynxdecryption.Solver, error) {
+	bound := c.Int64("table-bound")
+	if bound <= 0 || c.Int64("max-value") < bound {
+		return nil, errors.New("table bound should be positive and at most the max value")
+	}
+
+	var table *libdrynxdecryption.Table
+	cacheDir, err := os.UserCacheDir()
+	if err == nil {
+		path := filepath.Join(cacheDir, "drynx", fmt.Sprintf("decryption-%v.table", bound))
+		table, err = libdrynxdecryption.LoadTable(path, bound)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to keep the decryption table, computing it:", err)
+		if table, err = libdrynxdecryption.NewTable(bound); err != nil {
+			return nil, err
+		}
+	}
+
+	solver := libdrynxdecryption.NewSolver(table)
+	solver.Limit = c.Int64("max-value")
+	solver.Timeout = c.Duration("decryption-timeout")
+	return solver, nil
+}
+
+// chooseOperation creates the operation of a survey over the given number of sources.
+func chooseOperation(op cmd.Operation, sources int) (libdrynx.Operation, error) {
+	opMin, opMax := 0, 0
+	if opRange := op.Range; opRange != nil {
+		opMin, opMax = int(opRange.Min), int(opRange.Max)
+	}
+	operation, err := libdrynx.ChooseOperation(
+		string(op.Name), // operation
+		opMin,           // lower bound of range
+		opMax,           // upper bound of range
+		sources-1,       // dimension for linear regression
+		0)               // "cutting factor", how much to remove of gen data[0:#/n]
+	if err != nil {
+		return libdrynx.Operation{}, err
+	}
+	if operation.NbrInput != sources {
+		return libdrynx.Operation{}, errors.New("Operation can't take #Sources")
+	}
+	return operation, nil
+}
+
+func surveyCancel(c *cli.Context) error {
+	if args := c.Args(); len(args) != 0 {
+		return errors.New("no args expected")
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	if conf.Network == nil {
+		return errors.New("need some network config")
+	}
+	if conf.Survey == nil || conf.Survey.Name == nil {
+		return errors.New("need a survey name")
+	}
+
+	client, _, err := conf.connect()
+	if err != nil {
+		return err
+	}
+	return client.CancelSurvey(*conf.Survey.Name)
+}
+
+// runSurveyAsync submits the survey, reporting its progress on stderr until it is finished.
+func runSurveyAsync(client *services.API, sq libdrynx.SurveyQuery, poll time.Duration) (*services.SurveyResult, error) {
+	if err := waitSurvey(client, sq, poll); err != nil {
+		return nil, err
+	}
+	return client.FetchSurveyResult(sq)
+}
+
+// waitSurvey submits the survey, reporting its progress on stderr until it is done.
+func waitSurvey(client *services.API, sq libdrynx.SurveyQuery, poll time.Duration) error {
+	surveyID, err := client.SubmitSurveyQuery(sq)
+	if err != nil {
+		return err
+	}
+
+	for {
+		status, err := client.GetSurveyStatus(surveyID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%v: %v, %v/%v data providers answered\n", surveyID, status.Phase, status.NbrDPsAnswered, status.NbrDPs)
+
+		switch status.Phase {
+		case "done":
+			return nil
+		case "failed":
+			return errors.New(status.Error)
+		}
+
+		time.Sleep(poll)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"io"
 
 	kyber_encoding "go.dedis.ch/kyber/v3/util/encoding"
@@ -12,25 +14,128 @@ import (
 	"github.com/pelletier/go-toml"
 )
 
-type configDataProviderNeutralizerMinResultsSize struct {
-	MinimumResultsSize uint
+type configDataProviderNeutralizerMinCellSize struct {
+	Minimum uint
+	Merge   bool
+}
+type configDataProviderNeutralizerQuerySetOverlap struct {
+	MinimumDifference uint
+	HistorySize       uint
+	NoiseScale        float64
+	Identity          drynx_lib.ColumnID
+	// bbolt DB where to store the rows of the released queries
+	HistoryDB string
+}
+type configDataProviderNeutralizer struct {
+	// restrict to queries on these columns or operations
+	Columns    []drynx_lib.ColumnID `toml:",omitempty"`
+	Operations []string             `toml:",omitempty"`
+
+	// "clip", "drop" or "refuse" values outside of the query's range
+	RangeEnforcement string `toml:",omitempty"`
+	// where to store the number of rows outside of the range, only logged if empty
+	RangeEnforcementAudit string `toml:",omitempty"`
+	MinimumResultsSize    *uint
+	MinimumRowsCount      *uint
+	MinimumCellSize       *configDataProviderNeutralizerMinCellSize
+	QuerySetOverlap       *configDataProviderNeutralizerQuerySetOverlap
+	AllowedOperations     []string `toml:",omitempty"`
+	DeniedOperations      []string `toml:",omitempty"`
+
+	// combine with the others
+	All []configDataProviderNeutralizer `toml:",omitempty"`
+	Any []configDataProviderNeutralizer `toml:",omitempty"`
 }
 type configDataProviderFileLoader struct {
 	Path string
+	// "modtime", "checksum" or "signal" to reload the file when it changes
+	Reload string
+}
+type configDataProviderRandomUniform struct {
+	Min, Max float64
+}
+type configDataProviderRandomNormal struct {
+	Mean, StdDev float64
+}
+type configDataProviderRandomCategorical struct {
+	Values  []float64
+	Weights []float64 `toml:",omitempty"`
+}
+type configDataProviderRandomColumn struct {
+	Name drynx_lib.ColumnID
+
+	// only one distribution per column
+	Uniform     *configDataProviderRandomUniform
+	Normal      *configDataProviderRandomNormal
+	Bernoulli   *float64 // probability
+	Categorical *configDataProviderRandomCategorical
+	Poisson     *float64 // lambda
+}
+type configDataProviderRandom struct {
+	Seed    int64
+	Rows    uint
+	Columns []configDataProviderRandomColumn `toml:",omitempty"`
+	// matrix of the correlations between the columns
+	Correlations [][]float64 `toml:",omitempty"`
+}
+type configDataProviderJoinTable struct {
+	// besides the key
+	Columns []drynx_lib.ColumnID
+
+	FileLoader *configDataProviderFileLoader
+	Random     *configDataProviderRandom
+}
+type configDataProviderJoin struct {
+	Key drynx_lib.ColumnID
+	// "inner" or "left"
+	Kind string
+	// for left joins, "zero", "drop" or "refuse"
+	Missing string
+	MaxRows uint
+	Tables  []configDataProviderJoinTable `toml:",omitempty"`
+}
+type configDataProviderPrivacyBudget struct {
+	Path       string
+	Global     float64
+	PerQuerier float64
+	Noiseless  float64
 }
 type configDataProvider struct {
+	// name of the served dataset, empty for the default one
+	Dataset string
+
 	FileLoader  *configDataProviderFileLoader
-	Random      *struct{}
-	Neutralizer *configDataProviderNeutralizerMinResultsSize
+	Random      *configDataProviderRandom
+	Join        *configDataProviderJoin
+	Neutralizer *configDataProviderNeutralizer
+}
+type configComputingNode struct {
+	// how long to keep the results of submitted surveys, as "1h30m"
+	ResultsRetention string `toml:",omitempty"`
+}
+type configQuerier struct {
+	// hex encoded public key of the querier
+	PublicKey string
+
+	// allowed operations and columns, all if empty
+	Operations []string             `toml:",omitempty"`
+	Columns    []drynx_lib.ColumnID `toml:",omitempty"`
 }
 type config struct {
 	Address onet_network.Address
 	URL     string
 	Key     kyber_key.Pair
+	// where to keep the node's data, a temporary directory if empty
+	DataDir string
+	// where to serve the metrics, not served if empty
+	Metrics string
 
-	DataProvider  *configDataProvider
-	ComputingNode *struct{}
+	DataProvider  []configDataProvider
+	PrivacyBudget *configDataProviderPrivacyBudget
+	ComputingNode *configComputingNode
 	VerifyingNode *struct{}
+
+	Queriers []configQuerier
 }
 
 type keyPairStr struct {
@@ -41,10 +146,15 @@ type configStr struct {
 	Address onet_network.Address
 	URL     string
 	Key     keyPairStr
+	DataDir string `toml:",omitempty"`
+	Metrics string `toml:",omitempty"`
 
-	DataProvider  *configDataProvider
-	ComputingNode *struct{}
+	DataProvider  []configDataProvider `toml:",omitempty"`
+	PrivacyBudget *configDataProviderPrivacyBudget
+	ComputingNode *configComputingNode
 	VerifyingNode *struct{}
+
+	Queriers []configQuerier `toml:",omitempty"`
 }
 
 func keyPairToUnsafe(kp kyber_key.Pair) (keyPairStr, error) {
@@ -81,6 +191,25 @@ func (kp keyPairStr) toSafe() (kyber_key.Pair, error) {
 	}, nil
 }
 
+// lastDataProvider returns the data-provider being configured on the stream.
+func (conf config) lastDataProvider() (*configDataProvider, error) {
+	if len(conf.DataProvider) == 0 {
+		return nil, errors.New("not on data-provider stream")
+	}
+	return &conf.DataProvider[len(conf.DataProvider)-1], nil
+}
+
+// addDataProvider starts the configuration of a data-provider serving a new dataset.
+func (conf *config) addDataProvider(dataProvider configDataProvider) error {
+	for _, dp := range conf.DataProvider {
+		if dp.Dataset == dataProvider.Dataset {
+			return fmt.Errorf("data-provider for dataset %q already set", dataProvider.Dataset)
+		}
+	}
+	conf.DataProvider = append(conf.DataProvider, dataProvider)
+	return nil
+}
+
 func readConfigFrom(r io.Reader) (config, error) {
 	var conf configStr
 	err := toml.NewDecoder(r).Decode(&conf)
@@ -94,10 +223,15 @@ func readConfigFrom(r io.Reader) (config, error) {
 		conf.Address,
 		conf.URL,
 		key,
+		conf.DataDir,
+		conf.Metrics,
 
 		conf.DataProvider,
+		conf.PrivacyBudget,
 		conf.ComputingNode,
 		conf.VerifyingNode,
+
+		conf.Queriers,
 	}, nil
 }
 
@@ -111,10 +245,15 @@ func (conf config) writeTo(w io.Writer) error {
 		conf.Address,
 		conf.URL,
 		key,
+		conf.DataDir,
+		conf.Metrics,
 
 		conf.DataProvider,
+		conf.PrivacyBudget,
 		conf.ComputingNode,
 		conf.VerifyingNode,
+
+		conf.Queriers,
 	}
 
 	return toml.NewEncoder(w).Encode(conv)
@@ -1,21 +1,33 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	kyber "go.dedis.ch/kyber/v3"
 	kyber_encoding "go.dedis.ch/kyber/v3/util/encoding"
 	kyber_key "go.dedis.ch/kyber/v3/util/key"
+	onet "go.dedis.ch/onet/v3"
 	onet_app "go.dedis.ch/onet/v3/app"
 	onet_log "go.dedis.ch/onet/v3/log"
 	onet_network "go.dedis.ch/onet/v3/network"
 
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/authorization"
+	"github.com/ldsec/drynx/lib/metrics"
 	"github.com/ldsec/drynx/lib/provider"
+	"github.com/ldsec/drynx/lib/provider/accountants"
 	"github.com/ldsec/drynx/lib/provider/loaders"
 	"github.com/ldsec/drynx/lib/provider/neutralizers"
 	drynx_services "github.com/ldsec/drynx/services"
@@ -41,6 +53,28 @@ func configModNoArgs(act func(*config)) func(*cli.Context) error {
 	}
 }
 
+func computingNodeNew(c *cli.Context) error {
+	if len(c.Args()) > 0 {
+		return errors.New("need no argument")
+	}
+
+	computingNode := configComputingNode{ResultsRetention: c.String("results-retention")}
+	if computingNode.ResultsRetention != "" {
+		if _, err := time.ParseDuration(computingNode.ResultsRetention); err != nil {
+			return err
+		}
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	conf.ComputingNode = &computingNode
+
+	return conf.writeTo(os.Stdout)
+}
+
 func dataProviderNewFileLoader(c *cli.Context) error {
 	args := c.Args()
 	if len(args) != 1 {
@@ -53,11 +87,12 @@ func dataProviderNewFileLoader(c *cli.Context) error {
 		return err
 	}
 
-	if conf.DataProvider != nil {
-		return errors.New("data-provider already set")
+	if err := conf.addDataProvider(configDataProvider{
+		Dataset:    c.String("dataset"),
+		FileLoader: &configDataProviderFileLoader{Path: path, Reload: c.String("reload")},
+	}); err != nil {
+		return err
 	}
-	conf.DataProvider = &configDataProvider{
-		FileLoader: &configDataProviderFileLoader{Path: path}}
 
 	return conf.writeTo(os.Stdout)
 }
@@ -72,39 +107,475 @@ func dataProviderNewRandom(c *cli.Context) error {
 		return err
 	}
 
-	if conf.DataProvider != nil {
-		return errors.New("data-provider already set")
+	seed := c.Int64("seed")
+	if !c.IsSet("seed") {
+		// each node draws its own rows
+		if seed, err = seedOf(conf.Key.Public); err != nil {
+			return err
+		}
+	}
+
+	if err := conf.addDataProvider(configDataProvider{
+		Dataset: c.String("dataset"),
+		Random: &configDataProviderRandom{
+			Seed: seed,
+			Rows: c.Uint("rows"),
+		},
+	}); err != nil {
+		return err
 	}
-	conf.DataProvider = &configDataProvider{Random: &struct{}{}}
 
 	return conf.writeTo(os.Stdout)
 }
 
-func dataProviderSetNeutralizerMinimumResultsSize(c *cli.Context) error {
+// seedOf derives a seed from the public key of a node
+func seedOf(public kyber.Point) (int64, error) {
+	if public == nil {
+		return 0, errors.New("no key to derive the seed from, please give one")
+	}
+	raw, err := public.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.Sum256(raw)
+	return int64(binary.BigEndian.Uint64(hash[:8])), nil
+}
+
+func dataProviderNewJoin(c *cli.Context) error {
 	args := c.Args()
 	if len(args) != 1 {
-		return errors.New("need a minimum")
+		return errors.New("need a key column")
+	}
+
+	join := configDataProviderJoin{
+		Key:     libdrynx.ColumnID(args[0]),
+		Kind:    "inner",
+		Missing: c.String("missing"),
+		MaxRows: c.Uint("max-rows"),
+	}
+	if c.Bool("left") {
+		join.Kind = "left"
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	if err := conf.addDataProvider(configDataProvider{
+		Dataset: c.String("dataset"),
+		Join:    &join,
+	}); err != nil {
+		return err
+	}
+
+	return conf.writeTo(os.Stdout)
+}
+
+func dataProviderAddJoinedFile(c *cli.Context) error {
+	args := c.Args()
+	if len(args) < 2 {
+		return errors.New("need a path and the columns to provide")
+	}
+	columns := make([]libdrynx.ColumnID, len(args)-1)
+	for i, a := range args[1:] {
+		columns[i] = libdrynx.ColumnID(a)
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	dataProvider, err := conf.lastDataProvider()
+	if err != nil {
+		return err
+	}
+	if dataProvider.Join == nil {
+		return errors.New("not on join data-provider stream")
+	}
+	dataProvider.Join.Tables = append(dataProvider.Join.Tables, configDataProviderJoinTable{
+		Columns:    columns,
+		FileLoader: &configDataProviderFileLoader{Path: args[0], Reload: c.String("reload")},
+	})
+
+	return conf.writeTo(os.Stdout)
+}
+
+func parseFloats(args []string) ([]float64, error) {
+	ret := make([]float64, len(args))
+	for i, a := range args {
+		var err error
+		if ret[i], err = strconv.ParseFloat(a, 64); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func dataProviderAddRandomColumn(paramsCount int, act func([]float64, *configDataProviderRandomColumn)) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		args := c.Args()
+		if len(args) == 0 {
+			return errors.New("need a column name")
+		}
+		if paramsCount >= 0 && len(args) != paramsCount+1 {
+			return fmt.Errorf("need a column name and %v parameters", paramsCount)
+		}
+		params, err := parseFloats(args[1:])
+		if err != nil {
+			return err
+		}
+
+		column := configDataProviderRandomColumn{Name: libdrynx.ColumnID(args[0])}
+		act(params, &column)
+		if _, err := newDistribution(column); err != nil {
+			return err
+		}
+
+		conf, err := readConfigFrom(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		dataProvider, err := conf.lastDataProvider()
+		if err != nil {
+			return err
+		}
+		if dataProvider.Random == nil {
+			return errors.New("not on random data-provider stream")
+		}
+		dataProvider.Random.Columns = append(dataProvider.Random.Columns, column)
+
+		return conf.writeTo(os.Stdout)
+	}
+}
+
+func newDistribution(conf configDataProviderRandomColumn) (loaders.Distribution, error) {
+	var ret []loaders.Distribution
+	var err error
+	add := func(d loaders.Distribution, e error) {
+		ret = append(ret, d)
+		if err == nil {
+			err = e
+		}
+	}
+
+	if c := conf.Uniform; c != nil {
+		add(loaders.NewUniform(c.Min, c.Max))
+	}
+	if c := conf.Normal; c != nil {
+		add(loaders.NewNormal(c.Mean, c.StdDev))
+	}
+	if c := conf.Bernoulli; c != nil {
+		add(loaders.NewBernoulli(*c))
+	}
+	if c := conf.Categorical; c != nil {
+		add(loaders.NewCategorical(c.Values, c.Weights))
+	}
+	if c := conf.Poisson; c != nil {
+		add(loaders.NewPoisson(*c))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("column '%s': %v", conf.Name, err)
+	}
+	if len(ret) != 1 {
+		return nil, fmt.Errorf("column '%s' needs one distribution", conf.Name)
+	}
+	return ret[0], nil
+}
+
+func newRandomLoader(conf configDataProviderRandom) (provider.Loader, error) {
+	columns := make([]loaders.SyntheticColumn, len(conf.Columns))
+	for i, c := range conf.Columns {
+		distribution, err := newDistribution(c)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = loaders.SyntheticColumn{ID: c.Name, Distribution: distribution}
+	}
+
+	return loaders.NewSynthetic(conf.Seed, conf.Rows, columns, conf.Correlations)
+}
+
+func parseNeutralizerMinimum(c *cli.Context) (uint, error) {
+	args := c.Args()
+	if len(args) != 1 {
+		return 0, errors.New("need a minimum")
 	}
 	minimum, err := strconv.ParseUint(args[0], 10, 0)
 	if err != nil {
+		return 0, err
+	}
+	return uint(minimum), nil
+}
+
+func dataProviderSetNeutralizer(act func(*cli.Context, *configDataProviderNeutralizer) error) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		neutralizer := configDataProviderNeutralizer{}
+		if err := act(c, &neutralizer); err != nil {
+			return err
+		}
+
+		conf, err := readConfigFrom(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		dataProvider, err := conf.lastDataProvider()
+		if err != nil {
+			return err
+		}
+		dataProvider.Neutralizer = &neutralizer
+
+		return conf.writeTo(os.Stdout)
+	}
+}
+
+func dataProviderSetNeutralizerMinimumResultsSize(c *cli.Context, conf *configDataProviderNeutralizer) error {
+	minimum, err := parseNeutralizerMinimum(c)
+	conf.MinimumResultsSize = &minimum
+	return err
+}
+
+func dataProviderSetNeutralizerMinimumRowsCount(c *cli.Context, conf *configDataProviderNeutralizer) error {
+	minimum, err := parseNeutralizerMinimum(c)
+	conf.MinimumRowsCount = &minimum
+	return err
+}
+
+func dataProviderSetNeutralizerMinimumCellSize(c *cli.Context, conf *configDataProviderNeutralizer) error {
+	minimum, err := parseNeutralizerMinimum(c)
+	conf.MinimumCellSize = &configDataProviderNeutralizerMinCellSize{
+		Minimum: minimum,
+		Merge:   c.Bool("merge"),
+	}
+	return err
+}
+
+func dataProviderSetNeutralizerQuerySetOverlap(c *cli.Context, conf *configDataProviderNeutralizer) error {
+	minimum, err := parseNeutralizerMinimum(c)
+	conf.QuerySetOverlap = &configDataProviderNeutralizerQuerySetOverlap{
+		MinimumDifference: minimum,
+		HistorySize:       c.Uint("history"),
+		NoiseScale:        c.Float64("noise-scale"),
+		Identity:          libdrynx.ColumnID(c.String("identity")),
+		HistoryDB:         c.String("history-db"),
+	}
+	return err
+}
+
+func dataProviderSetNeutralizerRangeEnforcement(c *cli.Context, conf *configDataProviderNeutralizer) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need a policy")
+	}
+	if _, err := parseRangePolicy(args[0]); err != nil {
 		return err
 	}
+	conf.RangeEnforcement = args[0]
+	conf.RangeEnforcementAudit = c.String("audit")
+	return nil
+}
+
+func dataProviderSetNeutralizerFromFile(c *cli.Context, conf *configDataProviderNeutralizer) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need a path")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := toml.NewDecoder(file).Decode(conf); err != nil {
+		return err
+	}
+	_, err = newNeutralizer(*conf)
+	return err
+}
+
+func newNeutralizers(confs []configDataProviderNeutralizer) ([]provider.Neutralizer, error) {
+	ret := make([]provider.Neutralizer, len(confs))
+	for i, c := range confs {
+		var err error
+		if ret[i], err = newNeutralizer(c); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func parseRangePolicy(policy string) (neutralizers.RangePolicy, error) {
+	switch policy {
+	case "clip":
+		return neutralizers.ClipValues, nil
+	case "drop":
+		return neutralizers.DropRows, nil
+	case "refuse":
+		return neutralizers.RefuseOutOfRange, nil
+	}
+	return 0, fmt.Errorf("unknown range policy: %v", policy)
+}
+
+// newNeutralizer creates the neutralizer vetting only when all configured ones vet.
+func newNeutralizer(conf configDataProviderNeutralizer) (provider.Neutralizer, error) {
+	var chain []provider.Neutralizer
+
+	// first, so that the others see the enforced values
+	if conf.RangeEnforcement != "" {
+		policy, err := parseRangePolicy(conf.RangeEnforcement)
+		if err != nil {
+			return nil, err
+		}
+		neutralizer, err := neutralizers.NewRangeEnforcement(policy, conf.RangeEnforcementAudit)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, neutralizer)
+	}
+	if conf.MinimumResultsSize != nil {
+		chain = append(chain, neutralizers.NewMinimumResultsSize(*conf.MinimumResultsSize))
+	}
+	if conf.MinimumRowsCount != nil {
+		chain = append(chain, neutralizers.NewMinimumRowsCount(*conf.MinimumRowsCount))
+	}
+	if c := conf.MinimumCellSize; c != nil {
+		policy := neutralizers.SuppressCells
+		if c.Merge {
+			policy = neutralizers.MergeCells
+		}
+		neutralizer, err := neutralizers.NewMinimumCellSize(c.Minimum, policy)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, neutralizer)
+	}
+	if c := conf.QuerySetOverlap; c != nil {
+		neutralizer, err := neutralizers.NewQuerySetOverlap(c.MinimumDifference, c.HistorySize, c.NoiseScale, c.Identity, c.HistoryDB)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, neutralizer)
+	}
+	if conf.AllowedOperations != nil {
+		chain = append(chain, neutralizers.NewAllowedOperations(conf.AllowedOperations))
+	}
+	if conf.DeniedOperations != nil {
+		chain = append(chain, neutralizers.NewDeniedOperations(conf.DeniedOperations))
+	}
+
+	if conf.All != nil {
+		allOf, err := newNeutralizers(conf.All)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, neutralizers.NewAll(allOf...))
+	}
+	if conf.Any != nil {
+		anyOf, err := newNeutralizers(conf.Any)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, neutralizers.NewAny(anyOf...))
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("empty neutralizer config")
+	}
+
+	var neutralizer provider.Neutralizer
+	if len(chain) == 1 {
+		neutralizer = chain[0]
+	} else {
+		neutralizer = neutralizers.NewAll(chain...)
+	}
+
+	if conf.Columns != nil || conf.Operations != nil {
+		neutralizer = neutralizers.NewScoped(conf.Columns, conf.Operations, neutralizer)
+	}
+
+	return neutralizer, nil
+}
+
+func dataProviderSetPrivacyBudget(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need a path")
+	}
 
 	conf, err := readConfigFrom(os.Stdin)
 	if err != nil {
 		return err
 	}
 
-	if conf.DataProvider == nil {
-		return errors.New("not on data-provider stream")
+	if _, err := conf.lastDataProvider(); err != nil {
+		return err
+	}
+	conf.PrivacyBudget = &configDataProviderPrivacyBudget{
+		Path:       args[0],
+		Global:     c.Float64("global"),
+		PerQuerier: c.Float64("per-querier"),
+		Noiseless:  c.Float64("noiseless"),
+	}
+
+	return conf.writeTo(os.Stdout)
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+func allowQuerier(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need a public key")
+	}
+	if _, err := kyber_encoding.StringHexToPoint(libdrynx.Suite, args[0]); err != nil {
+		return fmt.Errorf("public key: %v", err)
+	}
+
+	querier := configQuerier{PublicKey: args[0], Operations: splitList(c.String("operations"))}
+	for _, column := range splitList(c.String("columns")) {
+		querier.Columns = append(querier.Columns, libdrynx.ColumnID(column))
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
 	}
-	conf.DataProvider.Neutralizer = &configDataProviderNeutralizerMinResultsSize{
-		MinimumResultsSize: uint(minimum),
+
+	for i, q := range conf.Queriers {
+		if q.PublicKey == querier.PublicKey {
+			conf.Queriers = append(conf.Queriers[:i], conf.Queriers[i+1:]...)
+			break
+		}
 	}
+	conf.Queriers = append(conf.Queriers, querier)
 
 	return conf.writeTo(os.Stdout)
 }
 
+func newQueriersPolicy(queriers []configQuerier) (*authorization.Policy, error) {
+	policy := authorization.NewPolicy()
+	for _, q := range queriers {
+		public, err := kyber_encoding.StringHexToPoint(libdrynx.Suite, q.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("querier %q: %v", q.PublicKey, err)
+		}
+		if err := policy.Allow(public, authorization.Rule{Operations: q.Operations, Columns: q.Columns}); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
 func gen(c *cli.Context) error {
 	args := c.Args()
 	if len(args) != 2 {
@@ -119,11 +590,128 @@ func gen(c *cli.Context) error {
 		Address: address,
 		URL:     addrClient,
 		Key:     *kp,
+		DataDir: c.String("data-dir"),
+		Metrics: c.String("metrics"),
 	}
 
 	return conf.writeTo(os.Stdout)
 }
 
+// newLoader creates the loader of the only source configured.
+func newLoader(fileLoader *configDataProviderFileLoader, random *configDataProviderRandom, join *configDataProviderJoin) (provider.Loader, error) {
+	var loader provider.Loader
+	var err error
+	count := 0
+	if c := random; c != nil {
+		count++
+		loader, err = newRandomLoader(*c)
+	}
+	if c := fileLoader; c != nil {
+		count++
+		loader, err = newFileLoader(*c)
+	}
+	if c := join; c != nil {
+		count++
+		loader, err = newJoinLoader(*c)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if count != 1 {
+		return nil, errors.New("need one loader")
+	}
+	return loader, nil
+}
+
+func newFileLoader(conf configDataProviderFileLoader) (provider.Loader, error) {
+	var detection loaders.ChangeDetection
+	switch conf.Reload {
+	case "":
+		return loaders.NewFileLoader(conf.Path)
+	case "modtime":
+		detection = loaders.ByModTime
+	case "checksum":
+		detection = loaders.ByChecksum
+	case "signal":
+		detection = loaders.OnReload
+	default:
+		return nil, fmt.Errorf("unknown reload detection: %v", conf.Reload)
+	}
+
+	return loaders.NewReloading(conf.Path, detection, loaders.NewFileLoader)
+}
+
+// reloadOnSignal reloads the loaders supporting it when the node receives SIGHUP.
+func reloadOnSignal(toReload []provider.Loader) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			for _, l := range toReload {
+				if r, ok := l.(provider.Reloader); ok {
+					if err := r.Reload(); err != nil {
+						onet_log.Errorf("unable to reload dataset: %v", err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+func newJoinLoader(conf configDataProviderJoin) (provider.Loader, error) {
+	var kind loaders.JoinKind
+	switch conf.Kind {
+	case "", "inner":
+		kind = loaders.InnerJoin
+	case "left":
+		kind = loaders.LeftJoin
+	default:
+		return nil, fmt.Errorf("unknown join kind: %v", conf.Kind)
+	}
+
+	var missing loaders.MissingPolicy
+	switch conf.Missing {
+	case "", "zero":
+		missing = loaders.FillZero
+	case "drop":
+		missing = loaders.DropRow
+	case "refuse":
+		missing = loaders.RefuseQuery
+	default:
+		return nil, fmt.Errorf("unknown missing values policy: %v", conf.Missing)
+	}
+
+	tables := make([]loaders.JoinedTable, len(conf.Tables))
+	for i, t := range conf.Tables {
+		loader, err := newLoader(t.FileLoader, t.Random, nil)
+		if err != nil {
+			return nil, fmt.Errorf("joined table %v: %v", i, err)
+		}
+		tables[i] = loaders.JoinedTable{Loader: loader, Columns: t.Columns}
+	}
+
+	return loaders.NewJoin(conf.Key, kind, missing, conf.MaxRows, tables)
+}
+
+func newDataset(conf configDataProvider) (provider.Loader, provider.Neutralizer, error) {
+	loader, err := newLoader(conf.FileLoader, conf.Random, conf.Join)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var neutralizer provider.Neutralizer
+	if c := conf.Neutralizer; c != nil {
+		neutralizer, err = newNeutralizer(*c)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return loader, neutralizer, nil
+}
+
 func run(c *cli.Context) error {
 	if len(c.Args()) > 0 {
 		return errors.New("need no argument")
@@ -134,36 +722,64 @@ func run(c *cli.Context) error {
 		return err
 	}
 
-	if conf.DataProvider == nil ||
-		conf.ComputingNode == nil ||
+	if conf.DataProvider == nil &&
+		conf.ComputingNode == nil &&
 		conf.VerifyingNode == nil {
-		return errors.New("currently, we don't support server specialization, please set all types")
+		return errors.New("no role configured, please set at least one type")
+	}
+	if conf.DataProvider == nil && conf.PrivacyBudget != nil {
+		return errors.New("privacy budget set without data-provider")
+	}
+	if conf.PrivacyBudget != nil && conf.PrivacyBudget.PerQuerier > 0 && len(conf.Queriers) == 0 {
+		// anyone could query under a new key each time
+		return errors.New("privacy budget per querier set without allowed queriers")
 	}
 
-	var loader provider.Loader
-	if c := conf.DataProvider.Random; c != nil {
-		loader, err = loaders.NewRandom(1, 1, 10) // TODO allow custom setting
+	builder := drynx_services.NewBuilder()
+	if conf.DataDir != "" {
+		builder = builder.WithDataDir(conf.DataDir)
+	}
+	if c := conf.ComputingNode; c != nil {
+		builder = builder.WithComputingNode()
+		if c.ResultsRetention != "" {
+			retention, err := time.ParseDuration(c.ResultsRetention)
+			if err != nil {
+				return err
+			}
+			builder = builder.WithResultsRetention(retention)
+		}
+	}
+	if conf.VerifyingNode != nil {
+		builder = builder.WithVerifyingNode()
+	}
+	if len(conf.Queriers) > 0 {
+		policy, err := newQueriersPolicy(conf.Queriers)
 		if err != nil {
 			return err
 		}
+		builder = builder.WithQueriers(policy)
 	}
-	if c := conf.DataProvider.FileLoader; c != nil {
-		loader, err = loaders.NewFileLoader(c.Path)
+
+	datasetsLoaders := make([]provider.Loader, len(conf.DataProvider))
+	for i, dp := range conf.DataProvider {
+		loader, neutralizer, err := newDataset(dp)
 		if err != nil {
-			return err
+			return fmt.Errorf("dataset %q: %v", dp.Dataset, err)
 		}
+		builder = builder.WithDataset(dp.Dataset, loader, neutralizer)
+		datasetsLoaders[i] = loader
 	}
+	reloadOnSignal(datasetsLoaders)
 
-	var neutralizer provider.Neutralizer
-	if c := conf.DataProvider.Neutralizer; c != nil {
-		neutralizer = neutralizers.NewMinimumResultsSize(c.MinimumResultsSize)
+	if c := conf.PrivacyBudget; c != nil {
+		accountant, err := accountants.NewEpsilonBudget(c.Path, c.Global, c.PerQuerier, c.Noiseless)
+		if err != nil {
+			return err
+		}
+		builder = builder.WithAccountant(accountant)
 	}
 
-	drynx_services.NewBuilder().
-		WithComputingNode().
-		WithDataProvider(loader, neutralizer).
-		WithVerifyingNode().
-		Start()
+	builder.Start()
 
 	pub, err := kyber_encoding.PointToStringHex(libdrynx.Suite, conf.Key.Public)
 	if err != nil {
@@ -196,11 +812,44 @@ func run(c *cli.Context) error {
 		return err
 	}
 
-	onet_app.RunServer(configFile.Name())
+	_, server, err := onet_app.ParseCothority(configFile.Name())
+	if err != nil {
+		return err
+	}
+	if conf.Metrics != "" {
+		if err := serveMetrics(conf.Metrics, server); err != nil {
+			return err
+		}
+	}
+	server.Start()
+
+	return nil
+}
+
+// serveMetrics serves the metrics of the node over HTTP, including the bytes exchanged by the server.
+func serveMetrics(address string, server *onet.Server) error {
+	metrics.NewCounterFunc("drynx_network_received_bytes_total",
+		"Bytes received from the other nodes.", func() float64 { return float64(server.Rx()) })
+	metrics.NewCounterFunc("drynx_network_sent_bytes_total",
+		"Bytes sent to the other nodes.", func() float64 { return float64(server.Tx()) })
 
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	go func() {
+		onet_log.Error("metrics:", http.Serve(listener, mux))
+	}()
 	return nil
 }
 
+var (
+	datasetFlag = cli.StringFlag{Name: "dataset", Usage: "name of the dataset to serve, empty for the default one"}
+	reloadFlag  = cli.StringFlag{Name: "reload", Usage: "reload the file when it changes, detected by modtime or checksum, or on SIGHUP with signal"}
+)
+
 func main() {
 	app := cli.NewApp()
 	app.Usage = "configure and start a Drynx node"
@@ -210,49 +859,188 @@ func main() {
 	if you want to generate a server config, use something like
 		%[1]s new {1,2}.drynx.c4dt.org |
 			%[1]s data-provider new file-loader $my_data |
-				%[1]s data-provider set-neutralizer minimum-results-size 3 |
+				%[1]s data-provider set-neutralizer minimum-rows-count 3 |
 			%[1]s computing-node new |
 			%[1]s verifying-node new >
 			$my_node_config
+	a data-provider can serve other datasets, to be selected by the queries
+		%[1]s data-provider new file-loader --dataset visits $my_visits |
+			%[1]s data-provider set-neutralizer minimum-rows-count 3
+	instead of a file, a data-provider can generate reproducible random data
+		%[1]s data-provider new random --seed 42 --rows 100 |
+			%[1]s data-provider add-random-column normal age 40 12 |
+			%[1]s data-provider add-random-column bernoulli smoker 0.2
+	a node can also take only some of the roles, such as a data-provider only
+		%[1]s new {1,2}.drynx.c4dt.org |
+			%[1]s data-provider new file-loader $my_data >
+			$my_node_config
+	by default, anyone can query; to only run the surveys signed by known queriers
+		%[1]s allow-querier --operations sum,mean --columns age $querier_public_key
+	to monitor the node, serve its metrics for Prometheus under /metrics
+		%[1]s new --metrics localhost:9100 {1,2}.drynx.c4dt.org
 	then, you can run the given server
 		cat $my_node_config | %[1]s run
 	`, "\t", "   ", -1)), os.Args[0])
 
 	app.Commands = []cli.Command{{
-		Name:   "new",
-		Usage:  "generate a server config, start of a server config stream",
+		Name:      "new",
+		ArgsUsage: "host:node-port host:client-port",
+		Usage:     "generate a server config, start of a server config stream",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "data-dir", Usage: "where to keep the node's data, such as precomputations reused across surveys"},
+			cli.StringFlag{Name: "metrics", Usage: "host:port where to serve the metrics of the node in the Prometheus text format, such as localhost:9100"},
+		},
 		Action: gen,
 	}, {
 		Name:   "run",
 		Usage:  "sink of a server config, run the node as configured",
 		Action: run,
+	}, {
+		Name:      "allow-querier",
+		ArgsUsage: "public-key",
+		Usage:     "on a server config stream, only run the surveys signed by the allowed queriers",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "operations", Usage: "comma separated operations the querier may run, all if empty"},
+			cli.StringFlag{Name: "columns", Usage: "comma separated columns the querier may select, all if empty"},
+		},
+		Action: allowQuerier,
 	}, {
 		Name:  "computing-node",
 		Usage: "computing-node configuration",
 		Subcommands: []cli.Command{{
-			Name:   "new",
-			Usage:  "on a server config stream, generate a computing-node config, start a computing-node config stream",
-			Action: configModNoArgs(func(conf *config) { conf.ComputingNode = new(struct{}) }),
+			Name:  "new",
+			Usage: "on a server config stream, generate a computing-node config, start a computing-node config stream",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "results-retention", Usage: "how long to keep the results of submitted surveys, such as 1h30m"},
+			},
+			Action: computingNodeNew,
 		}}}, {
 		Name:  "data-provider",
 		Usage: "data-provider configuration",
 		Subcommands: []cli.Command{{
 			Name:  "new",
-			Usage: "on a server config stream, generate a data-provider config with the given loader, start a data-provider config stream",
+			Usage: "on a server config stream, generate a data-provider config with the given loader, start a data-provider config stream; repeat to serve multiple datasets",
 			Subcommands: []cli.Command{{
-				Name:   "file-loader",
-				Action: dataProviderNewFileLoader,
+				Name:      "file-loader",
+				ArgsUsage: "path",
+				Flags:     []cli.Flag{datasetFlag, reloadFlag},
+				Action:    dataProviderNewFileLoader,
 			}, {
-				Name:   "random",
+				Name:  "random",
+				Usage: "generate rows of random columns, to be added with add-random-column",
+				Flags: []cli.Flag{
+					datasetFlag,
+					cli.Int64Flag{Name: "seed", Usage: "seed of the generator, same seed gives same rows, derived from the node's key by default"},
+					cli.UintFlag{Name: "rows", Value: 10, Usage: "number of rows to generate"},
+				},
 				Action: dataProviderNewRandom,
+			}, {
+				Name:      "join",
+				ArgsUsage: "key-column",
+				Usage:     "link the rows of tables on the key column, to be added with add-joined-file",
+				Flags: []cli.Flag{
+					datasetFlag,
+					cli.BoolFlag{Name: "left", Usage: "keep the rows of the first table without match in the others"},
+					cli.StringFlag{Name: "missing", Value: "zero", Usage: "for left joins, what to do on missing values: zero, drop or refuse"},
+					cli.UintFlag{Name: "max-rows", Usage: "refuse queries reading or joining more rows, zero for unlimited"},
+				},
+				Action: dataProviderNewJoin,
+			}},
+		}, {
+			Name:      "add-joined-file",
+			ArgsUsage: "path column...",
+			Flags:     []cli.Flag{reloadFlag},
+			Usage:     "on a join data-provider config stream, add a file providing the given columns besides the key",
+			Action:    dataProviderAddJoinedFile,
+		}, {
+			Name:  "add-random-column",
+			Usage: "on a random data-provider config stream, add a column drawn from the given distribution",
+			Subcommands: []cli.Command{{
+				Name:      "uniform",
+				ArgsUsage: "name min max",
+				Action: dataProviderAddRandomColumn(2, func(p []float64, c *configDataProviderRandomColumn) {
+					c.Uniform = &configDataProviderRandomUniform{Min: p[0], Max: p[1]}
+				}),
+			}, {
+				Name:      "normal",
+				ArgsUsage: "name mean standard-deviation",
+				Action: dataProviderAddRandomColumn(2, func(p []float64, c *configDataProviderRandomColumn) {
+					c.Normal = &configDataProviderRandomNormal{Mean: p[0], StdDev: p[1]}
+				}),
+			}, {
+				Name:      "bernoulli",
+				ArgsUsage: "name probability",
+				Action: dataProviderAddRandomColumn(1, func(p []float64, c *configDataProviderRandomColumn) {
+					c.Bernoulli = &p[0]
+				}),
+			}, {
+				Name:      "categorical",
+				ArgsUsage: "name value...",
+				Usage:     "draw equally likely values, give weights with the config file",
+				Action: dataProviderAddRandomColumn(-1, func(p []float64, c *configDataProviderRandomColumn) {
+					c.Categorical = &configDataProviderRandomCategorical{Values: p}
+				}),
+			}, {
+				Name:      "poisson",
+				ArgsUsage: "name lambda",
+				Action: dataProviderAddRandomColumn(1, func(p []float64, c *configDataProviderRandomColumn) {
+					c.Poisson = &p[0]
+				}),
 			}},
 		}, {
 			Name:  "set-neutralizer",
 			Usage: "on a data-provider config stream, set the neutralizer to use",
 			Subcommands: []cli.Command{{
-				Name:   "minimum-results-size",
-				Action: dataProviderSetNeutralizerMinimumResultsSize,
+				Name:      "minimum-results-size",
+				ArgsUsage: "minimum",
+				Usage:     "refuse results with less than the given number of columns",
+				Action:    dataProviderSetNeutralizer(dataProviderSetNeutralizerMinimumResultsSize),
+			}, {
+				Name:      "minimum-rows-count",
+				ArgsUsage: "minimum",
+				Usage:     "refuse results computed on less than the given number of rows",
+				Action:    dataProviderSetNeutralizer(dataProviderSetNeutralizerMinimumRowsCount),
+			}, {
+				Name:      "minimum-cell-size",
+				ArgsUsage: "minimum",
+				Usage:     "suppress, or merge, the cells computed on less than the given number of rows",
+				Flags:     []cli.Flag{cli.BoolFlag{Name: "merge", Usage: "merge small cells into the nearest one instead of suppressing them"}},
+				Action:    dataProviderSetNeutralizer(dataProviderSetNeutralizerMinimumCellSize),
+			}, {
+				Name:      "query-set-overlap",
+				ArgsUsage: "minimum",
+				Usage:     "refuse, or add noise to, queries on rows differing from a recent query by less than the given number of rows",
+				Flags: []cli.Flag{
+					cli.UintFlag{Name: "history", Value: 100, Usage: "how many queries to remember"},
+					cli.Float64Flag{Name: "noise-scale", Usage: "scale of the noise to add to overlapping queries, 0 to refuse them"},
+					cli.StringFlag{Name: "identity", Usage: "column identifying the individual of each row"},
+					cli.StringFlag{Name: "history-db", Usage: "bbolt DB where to store the rows of the released queries, next to the privacy budget one"},
+				},
+				Action: dataProviderSetNeutralizer(dataProviderSetNeutralizerQuerySetOverlap),
+			}, {
+				Name:      "range-enforcement",
+				ArgsUsage: "clip|drop|refuse",
+				Usage:     "clip the values outside of the query's range to its bounds, drop their rows, or refuse the query",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "audit", Usage: "bbolt DB where to store the number of rows outside of the range"},
+				},
+				Action: dataProviderSetNeutralizer(dataProviderSetNeutralizerRangeEnforcement),
+			}, {
+				Name:      "from-file",
+				ArgsUsage: "neutralizer.toml",
+				Usage:     "use the neutralizers chain described in the given file",
+				Action:    dataProviderSetNeutralizer(dataProviderSetNeutralizerFromFile),
 			}},
+		}, {
+			Name:      "set-privacy-budget",
+			ArgsUsage: "db-path",
+			Usage:     "on a data-provider config stream, track the differential privacy budget in the given DB",
+			Flags: []cli.Flag{
+				cli.Float64Flag{Name: "global", Usage: "maximum epsilon released over all queriers, 0 for unlimited"},
+				cli.Float64Flag{Name: "per-querier", Usage: "maximum epsilon released to each allowed querier, 0 for unlimited"},
+				cli.Float64Flag{Name: "noiseless", Usage: "epsilon charged for results released without noise, refused if not set"},
+			},
+			Action: dataProviderSetPrivacyBudget,
 		}}}, {
 		Name:  "verifying-node",
 		Usage: "verifying-node configuration",
@@ -0,0 +1,63 @@
+package libdrynx
+
+import (
+	"fmt"
+
+	"go.dedis.ch/onet/v3/network"
+)
+
+// AssignDataProviders spreads the data providers evenly over the computing nodes, a node never being its own data
+// provider. The computing node of some data providers can be fixed with assigned, from the data provider to its
+// computing node, both identified by their String(); the data providers assigned to an unknown computing node, such as
+// one which is down, are spread as the others.
+// Computing nodes without data provider are left out of the returned map.
+func AssignDataProviders(cns, dps []network.ServerIdentity, assigned map[string]string) (map[string]*ServerIdentityList, error) {
+	assignment := make(map[string]*ServerIdentityList)
+	add := func(cn string, dp network.ServerIdentity) {
+		if _, ok := assignment[cn]; !ok {
+			assignment[cn] = &ServerIdentityList{}
+		}
+		assignment[cn].Content = append(assignment[cn].Content, dp)
+	}
+	load := func(cn string) int {
+		if dps, ok := assignment[cn]; ok {
+			return len(dps.Content)
+		}
+		return 0
+	}
+
+	isCN := make(map[string]bool, len(cns))
+	for _, cn := range cns {
+		isCN[cn.String()] = true
+	}
+
+	var toSpread []network.ServerIdentity
+	for _, dp := range dps {
+		if cn, ok := assigned[dp.String()]; ok && isCN[cn] && cn != dp.String() {
+			add(cn, dp)
+		} else {
+			toSpread = append(toSpread, dp)
+		}
+	}
+
+	for i, dp := range toSpread {
+		// the least loaded, scanned from a different node each time, so that the nodes which are also data providers
+		// aren't left with only themselves
+		chosen := ""
+		for j := range cns {
+			cn := cns[(i+j)%len(cns)]
+			if cn.String() == dp.String() {
+				continue
+			}
+			if chosen == "" || load(cn.String()) < load(chosen) {
+				chosen = cn.String()
+			}
+		}
+		if chosen == "" {
+			return nil, fmt.Errorf("no computing node for data provider %v", dp.String())
+		}
+		add(chosen, dp)
+	}
+
+	return assignment, nil
+}
@@ -0,0 +1,58 @@
+package libdrynx_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3/network"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+func generateIdentities(count int) []network.ServerIdentity {
+	ids := make([]network.ServerIdentity, count)
+	for i := range ids {
+		address := network.NewTCPAddress(fmt.Sprintf("127.0.0.1:%v", 2000+2*i))
+		ids[i] = *network.NewServerIdentity(key.NewKeyPair(libdrynx.Suite).Public, address)
+	}
+	return ids
+}
+
+func TestAssignDataProviders(t *testing.T) {
+	ids := generateIdentities(5)
+	cns, dps := ids[:2], ids[2:]
+
+	assignment, err := libdrynx.AssignDataProviders(cns, dps, nil)
+	require.NoError(t, err)
+	assert.Len(t, assignment[cns[0].String()].Content, 2)
+	assert.Len(t, assignment[cns[1].String()].Content, 1)
+
+	// fixed, even if unbalanced
+	assigned := map[string]string{dps[0].String(): cns[1].String(), dps[1].String(): cns[1].String()}
+	assignment, err = libdrynx.AssignDataProviders(cns, dps, assigned)
+	require.NoError(t, err)
+	assert.Equal(t, dps[:2], assignment[cns[1].String()].Content)
+	assert.Equal(t, dps[2:], assignment[cns[0].String()].Content)
+
+	// to a computing node which is down
+	assignment, err = libdrynx.AssignDataProviders(cns[:1], dps, assigned)
+	require.NoError(t, err)
+	assert.Equal(t, dps, assignment[cns[0].String()].Content)
+}
+
+func TestAssignDataProvidersNotToThemselves(t *testing.T) {
+	ids := generateIdentities(3)
+
+	assignment, err := libdrynx.AssignDataProviders(ids, ids, nil)
+	require.NoError(t, err)
+	for _, cn := range ids {
+		require.Len(t, assignment[cn.String()].Content, 1)
+		assert.NotEqual(t, cn.String(), assignment[cn.String()].Content[0].String())
+	}
+
+	_, err = libdrynx.AssignDataProviders(ids[:1], ids[:1], nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package authorization
+
+import (
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/encoding"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+// Rule restricts the surveys a querier may run. An empty list of operations or columns allows all of them.
+type Rule struct {
+	Operations []string
+	Columns    []libdrynx.ColumnID
+}
+
+// Policy is the queriers a node runs surveys for, with what each of them may run.
+type Policy struct {
+	rules map[string]Rule
+}
+
+// NewPolicy creates a Policy allowing no querier.
+func NewPolicy() *Policy {
+	return &Policy{rules: make(map[string]Rule)}
+}
+
+func querierID(querier kyber.Point) (string, error) {
+	return encoding.PointToStringHex(libdrynx.Suite, querier)
+}
+
+// Allow lets the querier owning the given public key run the surveys matching the rule, replacing its previous rule.
+func (p *Policy) Allow(querier kyber.Point, rule Rule) error {
+	id, err := querierID(querier)
+	if err != nil {
+		return err
+	}
+	p.rules[id] = rule
+	return nil
+}
+
+// Authorize checks that the survey query was signed by one of the allowed queriers, and that its rule matches the query.
+func (p *Policy) Authorize(sq libdrynx.SurveyQuery) error {
+	if err := sq.VerifySignature(); err != nil {
+		return fmt.Errorf("unauthenticated querier: %v", err)
+	}
+
+	id, err := querierID(sq.ClientPubKey)
+	if err != nil {
+		return err
+	}
+	rule, ok := p.rules[id]
+	if !ok {
+		return errors.New("unknown querier")
+	}
+
+	if len(rule.Operations) > 0 {
+		// each operation of a batch has to be allowed
+		queries, err := sq.Query.SubQueries()
+		if err != nil {
+			return err
+		}
+		for _, q := range queries {
+			if !contains(rule.Operations, q.Operation.NameOp) {
+				return fmt.Errorf("querier not allowed to run %q, only %v", q.Operation.NameOp, rule.Operations)
+			}
+		}
+	}
+	if len(rule.Columns) > 0 {
+		allowed := make([]string, len(rule.Columns))
+		for i, c := range rule.Columns {
+			allowed[i] = string(c)
+		}
+		for _, selected := range sq.Query.Selector {
+			if !contains(allowed, string(selected)) {
+				return fmt.Errorf("querier not allowed to select %q, only %v", selected, rule.Columns)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AuthorizeKeyGeneration checks that the key generation was signed by one of the allowed queriers.
+func (p *Policy) AuthorizeKeyGeneration(sd libdrynx.StartDKG) error {
+	if err := sd.VerifySignature(); err != nil {
+		return fmt.Errorf("unauthenticated querier: %v", err)
+	}
+
+	id, err := querierID(sd.Querier)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.rules[id]; !ok {
+		return errors.New("unknown querier")
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package authorization_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/util/key"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/authorization"
+)
+
+func signedSurveyQuery(t *testing.T, keys *key.Pair, operation string, columns ...libdrynx.ColumnID) libdrynx.SurveyQuery {
+	sq := libdrynx.SurveyQuery{
+		SurveyID:     "test-authorization",
+		ClientPubKey: keys.Public,
+		Query: libdrynx.Query{
+			Operation: libdrynx.Operation{NameOp: operation},
+			Selector:  columns,
+		},
+	}
+	require.NoError(t, sq.Sign(keys.Private))
+	return sq
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	querier := key.NewKeyPair(libdrynx.Suite)
+	stranger := key.NewKeyPair(libdrynx.Suite)
+
+	policy := authorization.NewPolicy()
+	require.NoError(t, policy.Allow(querier.Public, authorization.Rule{
+		Operations: []string{"mean", "frequencyCount"},
+		Columns:    []libdrynx.ColumnID{"A", "B"},
+	}))
+
+	assert.NoError(t, policy.Authorize(signedSurveyQuery(t, querier, "mean", "A")))
+	assert.Error(t, policy.Authorize(signedSurveyQuery(t, querier, "sum", "A")))
+	assert.Error(t, policy.Authorize(signedSurveyQuery(t, querier, "mean", "A", "C")))
+	assert.Error(t, policy.Authorize(signedSurveyQuery(t, stranger, "mean", "A")))
+
+	tampered := signedSurveyQuery(t, querier, "mean", "A")
+	tampered.Query.Selector = []libdrynx.ColumnID{"B"}
+	assert.Error(t, policy.Authorize(tampered))
+
+	unsigned := signedSurveyQuery(t, querier, "mean", "A")
+	unsigned.Signature = nil
+	assert.Error(t, policy.Authorize(unsigned))
+
+	// the routing flag is set by the computing nodes, after signature
+	routed := signedSurveyQuery(t, querier, "mean", "A")
+	routed.IntraMessage = true
+	assert.NoError(t, policy.Authorize(routed))
+}
+
+func TestPolicyAuthorizeBatch(t *testing.T) {
+	querier := key.NewKeyPair(libdrynx.Suite)
+
+	policy := authorization.NewPolicy()
+	require.NoError(t, policy.Allow(querier.Public, authorization.Rule{Operations: []string{"sum", "mean"}}))
+
+	batch := func(operations ...string) libdrynx.SurveyQuery {
+		ops := make([]libdrynx.Operation, len(operations))
+		for i, name := range operations {
+			ops[i] = libdrynx.Operation{NameOp: name, NbrInput: 1, NbrOutput: 1}
+		}
+		batchOp, err := libdrynx.NewBatchOperation(ops)
+		require.NoError(t, err)
+
+		sq := signedSurveyQuery(t, querier, batchOp.NameOp, "A")
+		sq.Query.Operation, sq.Query.Batch = batchOp, ops
+		require.NoError(t, sq.Sign(querier.Private))
+		return sq
+	}
+
+	assert.NoError(t, policy.Authorize(batch("sum", "mean")))
+	assert.Error(t, policy.Authorize(batch("sum", "variance")))
+}
+
+func TestPolicyAuthorizeKeyGeneration(t *testing.T) {
+	querier := key.NewKeyPair(libdrynx.Suite)
+	stranger := key.NewKeyPair(libdrynx.Suite)
+
+	policy := authorization.NewPolicy()
+	require.NoError(t, policy.Allow(querier.Public, authorization.Rule{}))
+
+	start := libdrynx.StartDKG{Threshold: 2}
+	require.NoError(t, start.Sign(querier.Private))
+	assert.NoError(t, policy.AuthorizeKeyGeneration(start))
+
+	tampered := start
+	tampered.Threshold = 1
+	assert.Error(t, policy.AuthorizeKeyGeneration(tampered))
+
+	assert.Error(t, policy.AuthorizeKeyGeneration(libdrynx.StartDKG{Threshold: 2}))
+
+	foreign := libdrynx.StartDKG{Threshold: 2}
+	require.NoError(t, foreign.Sign(stranger.Private))
+	assert.Error(t, policy.AuthorizeKeyGeneration(foreign))
+}
@@ -0,0 +1,78 @@
+package libdrynx
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BatchOperationName is the name of the operation of a batch query, whose outputs are the ones of its operations,
+// concatenated in order
+const BatchOperationName = "batch"
+
+// NewBatchOperation creates the operation of a batch query, to be run with the given operations as Query.Batch.
+// The operations take the same columns; logistic regression can't be part of a batch.
+func NewBatchOperation(operations []Operation) (Operation, error) {
+	if len(operations) == 0 {
+		return Operation{}, errors.New("empty batch")
+	}
+
+	batch := Operation{NameOp: BatchOperationName, NbrInput: operations[0].NbrInput}
+	for _, op := range operations {
+		switch op.NameOp {
+		case BatchOperationName, "logistic regression":
+			return Operation{}, fmt.Errorf("operation <%v> can't be part of a batch", op.NameOp)
+		}
+		if op.NbrInput != batch.NbrInput {
+			return Operation{}, fmt.Errorf("operation <%v> takes %v columns, not %v as the others of the batch", op.NameOp, op.NbrInput, batch.NbrInput)
+		}
+		batch.NbrOutput += op.NbrOutput
+	}
+	return batch, nil
+}
+
+// SubQueries splits a batch query in a query for each of its operations, with their part of the ranges and input
+// validation signatures; any other query is returned as is.
+func (q Query) SubQueries() ([]Query, error) {
+	if q.Operation.NameOp != BatchOperationName {
+		return []Query{q}, nil
+	}
+
+	batch, err := NewBatchOperation(q.Batch)
+	if err != nil {
+		return nil, err
+	}
+	if batch.NbrOutput != q.Operation.NbrOutput {
+		return nil, fmt.Errorf("batch of %v outputs, operations giving %v", q.Operation.NbrOutput, batch.NbrOutput)
+	}
+	if len(q.Ranges) != 0 && len(q.Ranges) != batch.NbrOutput {
+		return nil, fmt.Errorf("batch of %v outputs, with %v ranges", batch.NbrOutput, len(q.Ranges))
+	}
+	for _, sigs := range q.IVSigs.InputValidationSigs {
+		if len(sigs.Content) != batch.NbrOutput {
+			return nil, fmt.Errorf("batch of %v outputs, with %v signatures", batch.NbrOutput, len(sigs.Content))
+		}
+	}
+
+	subQueries := make([]Query, len(q.Batch))
+	offset := 0
+	for i, op := range q.Batch {
+		sub := q
+		sub.Operation = op
+		sub.Batch = nil
+		next := offset + op.NbrOutput
+
+		if len(q.Ranges) != 0 {
+			sub.Ranges = q.Ranges[offset:next]
+		}
+		if q.IVSigs.InputValidationSigs != nil {
+			sub.IVSigs.InputValidationSigs = make([]*PublishSignatureBytesList, len(q.IVSigs.InputValidationSigs))
+			for j, sigs := range q.IVSigs.InputValidationSigs {
+				sub.IVSigs.InputValidationSigs[j] = &PublishSignatureBytesList{Content: sigs.Content[offset:next]}
+			}
+		}
+
+		subQueries[i] = sub
+		offset = next
+	}
+	return subQueries, nil
+}
@@ -0,0 +1,66 @@
+package libdrynx_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+func TestNewBatchOperation(t *testing.T) {
+	sum, err := libdrynx.ChooseOperation("sum", 0, 0, 0, 0)
+	require.NoError(t, err)
+	freqCount, err := libdrynx.ChooseOperation("frequencyCount", 0, 4, 0, 0)
+	require.NoError(t, err)
+	cosim, err := libdrynx.ChooseOperation("cosim", 0, 0, 0, 0)
+	require.NoError(t, err)
+
+	batch, err := libdrynx.NewBatchOperation([]libdrynx.Operation{sum, freqCount})
+	require.NoError(t, err)
+	assert.Equal(t, libdrynx.BatchOperationName, batch.NameOp)
+	assert.Equal(t, 1, batch.NbrInput)
+	assert.Equal(t, 1+5, batch.NbrOutput)
+
+	_, err = libdrynx.NewBatchOperation(nil)
+	assert.Error(t, err)
+	_, err = libdrynx.NewBatchOperation([]libdrynx.Operation{sum, cosim})
+	assert.Error(t, err, "different columns")
+	_, err = libdrynx.NewBatchOperation([]libdrynx.Operation{sum, batch})
+	assert.Error(t, err, "nested batch")
+}
+
+func TestSubQueries(t *testing.T) {
+	sum, err := libdrynx.ChooseOperation("sum", 0, 0, 0, 0)
+	require.NoError(t, err)
+	mean, err := libdrynx.ChooseOperation("mean", 0, 0, 0, 0)
+	require.NoError(t, err)
+	batch, err := libdrynx.NewBatchOperation([]libdrynx.Operation{sum, mean})
+	require.NoError(t, err)
+
+	single := libdrynx.Query{Operation: sum, Selector: []libdrynx.ColumnID{"A"}}
+	queries, err := single.SubQueries()
+	require.NoError(t, err)
+	assert.Equal(t, []libdrynx.Query{single}, queries)
+
+	ranges := []*libdrynx.Int64List{{Content: []int64{1, 1}}, {Content: []int64{2, 2}}, {Content: []int64{3, 3}}}
+	query := libdrynx.Query{Operation: batch, Batch: []libdrynx.Operation{sum, mean}, Ranges: ranges, Selector: []libdrynx.ColumnID{"A"}}
+	queries, err = query.SubQueries()
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, sum, queries[0].Operation)
+	assert.Equal(t, ranges[:1], queries[0].Ranges)
+	assert.Equal(t, mean, queries[1].Operation)
+	assert.Equal(t, ranges[1:], queries[1].Ranges)
+	assert.Equal(t, query.Selector, queries[1].Selector)
+	assert.Nil(t, queries[1].Batch)
+
+	query.Ranges = ranges[:2]
+	_, err = query.SubQueries()
+	assert.Error(t, err, "ranges not matching the outputs")
+
+	query.Ranges, query.Batch = nil, []libdrynx.Operation{sum}
+	_, err = query.SubQueries()
+	assert.Error(t, err, "operations not matching the outputs")
+}
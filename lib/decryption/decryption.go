@@ -0,0 +1,229 @@
+// Package libdrynxdecryption solves the discrete logarithms of the points encrypted by the computing nodes, for the
+// querier to decode results too large for a table of all the possible values: a table of the small values is looked
+// up for each giant step taken from the point (baby-step giant-step), within a time limit.
+package libdrynxdecryption
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ldsec/drynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+// DefaultTableBound is the bound of the values in the table used by default.
+const DefaultTableBound = int64(1 << 16)
+
+// DefaultLimit is the bound of the values solved by default.
+const DefaultLimit = int64(1 << 40)
+
+// DefaultTimeout is how long solving a value takes at most by default.
+const DefaultTimeout = 30 * time.Second
+
+// Table maps the points of the values between -Bound and Bound to these values.
+type Table struct {
+	Bound  int64
+	values map[string]int64
+}
+
+// NewTable computes the table of the values between -bound and bound.
+func NewTable(bound int64) (*Table, error) {
+	if bound < 0 {
+		return nil, fmt.Errorf("negative table bound: %v", bound)
+	}
+
+	t := &Table{Bound: bound, values: make(map[string]int64, 2*bound+1)}
+	base := libdrynx.Suite.Point().Base()
+	point := libdrynx.Suite.Point().Null()
+	for m := int64(0); m <= bound; m++ {
+		if err := t.add(point, m); err != nil {
+			return nil, err
+		}
+		if m > 0 {
+			if err := t.add(libdrynx.Suite.Point().Neg(point), -m); err != nil {
+				return nil, err
+			}
+		}
+		point.Add(point, base)
+	}
+	return t, nil
+}
+
+func (t *Table) add(point kyber.Point, m int64) error {
+	key, err := point.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	t.values[string(key)] = m
+	return nil
+}
+
+func (t *Table) lookup(point kyber.Point) (int64, bool, error) {
+	key, err := point.MarshalBinary()
+	if err != nil {
+		return 0, false, err
+	}
+	m, ok := t.values[string(key)]
+	return m, ok, nil
+}
+
+// WriteTo writes the table as its bound followed by the points of its values, from -Bound to Bound.
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	points := make([][]byte, 2*t.Bound+1)
+	for key, m := range t.values {
+		points[m+t.Bound] = []byte(key)
+	}
+
+	buf := bytes.Buffer{}
+	if err := binary.Write(&buf, binary.BigEndian, t.Bound); err != nil {
+		return 0, err
+	}
+	for _, point := range points {
+		buf.Write(point)
+	}
+	return buf.WriteTo(w)
+}
+
+// ReadTable reads a table written by Table.WriteTo, checking its bounds against the points of -Bound and Bound.
+func ReadTable(r io.Reader) (*Table, error) {
+	var bound int64
+	if err := binary.Read(r, binary.BigEndian, &bound); err != nil {
+		return nil, err
+	}
+	if bound < 0 {
+		return nil, fmt.Errorf("negative table bound: %v", bound)
+	}
+
+	pointLen := int64(libdrynx.Suite.PointLen())
+	points, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(points)) != (2*bound+1)*pointLen {
+		return nil, fmt.Errorf("table of bound %v with %v bytes of points", bound, len(points))
+	}
+
+	t := &Table{Bound: bound, values: make(map[string]int64, 2*bound+1)}
+	for m := -bound; m <= bound; m++ {
+		offset := (m + bound) * pointLen
+		t.values[string(points[offset:offset+pointLen])] = m
+	}
+
+	top := libdrynx.Suite.Point().Mul(libdrynx.Suite.Scalar().SetInt64(bound), nil)
+	for _, check := range []struct {
+		point kyber.Point
+		value int64
+	}{{top, bound}, {libdrynx.Suite.Point().Neg(top), -bound}} {
+		if m, ok, err := t.lookup(check.point); err != nil {
+			return nil, err
+		} else if !ok || m != check.value {
+			return nil, fmt.Errorf("table of bound %v without the point of %v", bound, check.value)
+		}
+	}
+	return t, nil
+}
+
+// LoadTable reads the table of the given bound from the file, computing and writing it if it is missing or invalid,
+// so that the table is only computed once.
+func LoadTable(path string, bound int64) (*Table, error) {
+	if file, err := os.Open(path); err == nil {
+		t, err := ReadTable(file)
+		file.Close()
+		if err == nil && t.Bound == bound {
+			return t, nil
+		}
+	}
+
+	t, err := NewTable(bound)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	if _, err := t.WriteTo(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Solver finds the values of the points, between -Limit and Limit, in at most Timeout if set.
+type Solver struct {
+	Table   *Table
+	Limit   int64
+	Timeout time.Duration
+}
+
+// NewSolver creates a solver with the given table, with the default limit and timeout.
+func NewSolver(table *Table) *Solver {
+	return &Solver{Table: table, Limit: DefaultLimit, Timeout: DefaultTimeout}
+}
+
+// Solve returns the value m of the point mG, failing if it is out of the limit or isn't found in time.
+func (s *Solver) Solve(point kyber.Point) (int64, error) {
+	if s.Table == nil {
+		return 0, errors.New("no table to solve with")
+	}
+
+	if m, ok, err := s.Table.lookup(point); err != nil {
+		return 0, err
+	} else if ok {
+		return s.check(m)
+	}
+
+	// each giant step moves the point by the size of the table, up and down
+	stride := 2*s.Table.Bound + 1
+	giant := libdrynx.Suite.Point().Mul(libdrynx.Suite.Scalar().SetInt64(stride), nil)
+	up, down := point.Clone(), point.Clone()
+
+	var deadline time.Time
+	if s.Timeout > 0 {
+		deadline = time.Now().Add(s.Timeout)
+	}
+	for j := int64(1); j*stride-s.Table.Bound <= s.Limit; j++ {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return 0, fmt.Errorf("value not found in %v, beyond %v", s.Timeout, (j-1)*stride+s.Table.Bound)
+		}
+
+		up.Sub(up, giant)
+		if m, ok, err := s.Table.lookup(up); err != nil {
+			return 0, err
+		} else if ok {
+			return s.check(j*stride + m)
+		}
+		down.Add(down, giant)
+		if m, ok, err := s.Table.lookup(down); err != nil {
+			return 0, err
+		} else if ok {
+			return s.check(m - j*stride)
+		}
+	}
+	return 0, fmt.Errorf("value out of [%v, %v]", -s.Limit, s.Limit)
+}
+
+func (s *Solver) check(m int64) (int64, error) {
+	if m > s.Limit || m < -s.Limit {
+		return 0, fmt.Errorf("value out of [%v, %v]", -s.Limit, s.Limit)
+	}
+	return m, nil
+}
@@ -0,0 +1,104 @@
+package libdrynxdecryption_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/decryption"
+)
+
+func valuePoint(m int64) kyber.Point {
+	return libdrynx.Suite.Point().Mul(libdrynx.Suite.Scalar().SetInt64(m), nil)
+}
+
+func TestSolve(t *testing.T) {
+	table, err := libdrynxdecryption.NewTable(100)
+	require.NoError(t, err)
+	solver := libdrynxdecryption.NewSolver(table)
+	solver.Limit = 1000000
+
+	for _, m := range []int64{0, 1, -1, 100, -100, 101, -101, 12345, -54321, 1000000, -1000000} {
+		solved, err := solver.Solve(valuePoint(m))
+		require.NoError(t, err, "value %v", m)
+		assert.Equal(t, m, solved)
+	}
+
+	// decrypted with the querier's key
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	solved, err := solver.Solve(libunlynx.DecryptPoint(keys.Private, *libunlynx.EncryptInt(keys.Public, -20000)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-20000), solved)
+
+	for _, m := range []int64{1000001, -1000001, 5000000} {
+		_, err := solver.Solve(valuePoint(m))
+		assert.Error(t, err, "value %v", m)
+	}
+}
+
+func TestSolveTimeout(t *testing.T) {
+	table, err := libdrynxdecryption.NewTable(10)
+	require.NoError(t, err)
+	solver := libdrynxdecryption.NewSolver(table)
+	solver.Timeout = time.Millisecond
+
+	_, err = solver.Solve(valuePoint(1 << 39))
+	assert.Error(t, err)
+}
+
+func TestReadWriteTable(t *testing.T) {
+	table, err := libdrynxdecryption.NewTable(50)
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	_, err = table.WriteTo(&buf)
+	require.NoError(t, err)
+	read, err := libdrynxdecryption.ReadTable(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), read.Bound)
+
+	solved, err := libdrynxdecryption.NewSolver(read).Solve(valuePoint(-4242))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-4242), solved)
+
+	_, err = libdrynxdecryption.ReadTable(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Error(t, err)
+}
+
+func TestLoadTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drynx-decryption")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "table")
+
+	_, err = libdrynxdecryption.LoadTable(path, 20)
+	require.NoError(t, err)
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	// a table of another bound is replaced
+	table, err := libdrynxdecryption.LoadTable(path, 30)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), table.Bound)
+	replaced, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotEqual(t, written, replaced)
+
+	// a corrupted table is recomputed
+	require.NoError(t, ioutil.WriteFile(path, replaced[:len(replaced)/2], 0600))
+	table, err = libdrynxdecryption.LoadTable(path, 30)
+	require.NoError(t, err)
+	solved, err := libdrynxdecryption.NewSolver(table).Solve(valuePoint(30))
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), solved)
+}
@@ -228,6 +228,61 @@ func Decode(ciphers []libunlynx.CipherText, secKey kyber.Scalar, operation libdr
 	}
 }
 
+// DecodeClear is Decode on the outputs already decrypted, such as solved by the querier's decryption. The bits of
+// the operations aggregated by counting them are set if their output isn't zero.
+func DecodeClear(values []int64, operation libdrynx.Operation) []float64 {
+	switch operation.NameOp {
+	case "sum":
+		return []float64{float64(values[0])}
+	case "cosim":
+		return []float64{ExecuteCosimOnClient(values)}
+	case "mean":
+		return []float64{float64(values[0]) / float64(values[1])}
+	case "variance":
+		mean := float64(values[0]) / float64(values[1])
+		return []float64{float64(values[2])/float64(values[1]) - mean*mean}
+	case "lin_reg":
+		return ExecuteLinearRegressionDimsOnClient(values)
+	case "min", "max":
+		// index of the first set bit for min, of the first unset one for max
+		for i, v := range values {
+			if (v != 0) == (operation.NameOp == "min") {
+				return []float64{float64(int64(i) + operation.QueryMin)}
+			}
+		}
+		return []float64{0}
+	case "bool_AND", "inter":
+		result := make([]float64, len(values))
+		for i, v := range values {
+			if v == 0 {
+				result[i] = 1
+			}
+		}
+		return result
+	case "bool_OR", "union":
+		result := make([]float64, len(values))
+		for i, v := range values {
+			if v != 0 {
+				result[i] = 1
+			}
+		}
+		return result
+	case "logistic regression":
+		return ExecuteLogisticRegressionOnClient(values, operation.LRParameters)
+	case "MLeval":
+		return []float64{ExecuteModelEvaluationOnClient(values)}
+	}
+
+	if operation.NameOp != "frequencyCount" {
+		log.Info("no such operation:", operation)
+	}
+	result := make([]float64, len(values))
+	for i, v := range values {
+		result[i] = float64(v)
+	}
+	return result
+}
+
 // EncodeForFloat encodes floating points
 func EncodeForFloat(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point,
 	signatures [][]libdrynx.PublishSignature, ranges []*libdrynx.Int64List, operation string) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
@@ -0,0 +1,37 @@
+package libdrynxencoding_test
+
+import (
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+// TestDecodeClear tests that decoding the decrypted outputs gives the same result as decoding the ciphertexts
+func TestDecodeClear(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+
+	for _, c := range []struct {
+		operation libdrynx.Operation
+		values    []int64
+	}{
+		{libdrynx.Operation{NameOp: "sum"}, []int64{-7}},
+		{libdrynx.Operation{NameOp: "mean"}, []int64{12, 4}},
+		{libdrynx.Operation{NameOp: "variance"}, []int64{12, 4, 40}},
+		{libdrynx.Operation{NameOp: "frequencyCount"}, []int64{3, 0, 5}},
+		{libdrynx.Operation{NameOp: "min", QueryMin: 2}, []int64{0, 0, 3, 1}},
+		{libdrynx.Operation{NameOp: "max", QueryMin: 2}, []int64{2, 0, 0, 1}},
+		{libdrynx.Operation{NameOp: "bool_AND"}, []int64{0}},
+		{libdrynx.Operation{NameOp: "bool_OR"}, []int64{0}},
+		{libdrynx.Operation{NameOp: "union"}, []int64{0, 2, 1}},
+		{libdrynx.Operation{NameOp: "inter"}, []int64{0, 2, 1}},
+		{libdrynx.Operation{NameOp: "MLeval"}, []int64{4, 10, 30, 2}},
+	} {
+		encrypted := libunlynx.EncryptIntVector(keys.Public, c.values)
+		assert.Equal(t, libdrynxencoding.Decode(*encrypted, keys.Private, c.operation),
+			libdrynxencoding.DecodeClear(c.values, c.operation), c.operation.NameOp)
+	}
+}
@@ -108,8 +108,17 @@ func EncodeLinearRegressionDimsWithProofs(input1 [][]int64, input2 []int64, pubK
 //DecodeLinearRegressionDims implements a d-dimensional linear regression algorithm, in this encoding, we assume the system to have a perfect solution
 //TODO least-square computation and not equality
 func DecodeLinearRegressionDims(result []libunlynx.CipherText, secKey kyber.Scalar) []float64 {
-	//get the the number of dimensions by solving the equation: d^2 + 5d + 4 = 2*len(result)
-	posSol, _ := quadratic.Solve(1, 5, complex128(complex(float32(4-2*len(result)), 0)))
+	values := make([]int64, len(result))
+	for i, ct := range result {
+		values[i] = libunlynx.DecryptIntWithNeg(secKey, ct)
+	}
+	return ExecuteLinearRegressionDimsOnClient(values)
+}
+
+// ExecuteLinearRegressionDimsOnClient computes the coefficients from the decrypted outputs
+func ExecuteLinearRegressionDimsOnClient(values []int64) []float64 {
+	//get the the number of dimensions by solving the equation: d^2 + 5d + 4 = 2*len(values)
+	posSol, _ := quadratic.Solve(1, 5, complex128(complex(float32(4-2*len(values)), 0)))
 	d := int(real(posSol))
 
 	matrixAugmented := make([][]int64, d+1, d+2)
@@ -122,22 +131,22 @@ func DecodeLinearRegressionDims(result []libunlynx.CipherText, secKey kyber.Scal
 	l := d + 1
 	k := d + 1
 	i := 0
-	for j := 0; j < len(result)-d-1; j++ {
+	for j := 0; j < len(values)-d-1; j++ {
 		if j == l {
 			k--
 			l = l + k
 			i++
 			s = 0
 		}
-		matrixAugmented[i][i+s] = libunlynx.DecryptIntWithNeg(secKey, result[j])
+		matrixAugmented[i][i+s] = values[j]
 		if i != i+s {
-			matrixAugmented[i+s][i] = libunlynx.DecryptIntWithNeg(secKey, result[j])
+			matrixAugmented[i+s][i] = values[j]
 		}
 		s++
 	}
 
-	for j := len(result) - d - 1; j < len(result); j++ {
-		matrixAugmented[j-len(result)+d+1][d+1] = libunlynx.DecryptIntWithNeg(secKey, result[j])
+	for j := len(values) - d - 1; j < len(values); j++ {
+		matrixAugmented[j-len(values)+d+1][d+1] = values[j]
 	}
 
 	matrixRational := make([][]rational.Rational, d+1, d+2)
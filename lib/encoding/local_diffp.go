@@ -0,0 +1,122 @@
+package libdrynxencoding
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+// Note: with local differential privacy, each DP perturbs its own encoded output before encrypting it, so that
+// nobody, not even the CNs colluding with the querier, learns its exact contribution.
+
+// IsBitOperation returns whether the encoded output of an operation is a vector of bits, aggregated by counting them.
+func IsBitOperation(operation string) bool {
+	switch operation {
+	case "bool_AND", "bool_OR", "min", "max", "union", "inter":
+		return true
+	}
+	return false
+}
+
+// cryptoSource draws from crypto/rand, as the noise of a DP has to be unpredictable to the others, whatever the
+// seeding of the global math/rand source.
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var buffer [8]byte
+	if _, err := crand.Read(buffer[:]); err != nil {
+		panic("unable to read random bytes: " + err.Error())
+	}
+	return binary.BigEndian.Uint64(buffer[:])
+}
+
+func (s cryptoSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (cryptoSource) Seed(int64) {}
+
+// PerturbLocally perturbs the clear encoded output of a DP. Bits are randomized: with the flip probability, a bit is
+// replaced by a uniformly random one. Other values receive additive noise, following a laplace or gaussian
+// distribution of the given scale, rounded to the nearest integer.
+func PerturbLocally(clear []int64, operation string, params libdrynx.QueryLocalDiffP) ([]int64, error) {
+	perturbed := make([]int64, len(clear))
+	random := rand.New(cryptoSource{})
+
+	if IsBitOperation(operation) {
+		if params.FlipProbability < 0 || params.FlipProbability > 1 {
+			return nil, fmt.Errorf("flip probability %v not in [0, 1]", params.FlipProbability)
+		}
+		for i, v := range clear {
+			bit := v != 0
+			if random.Float64() < params.FlipProbability {
+				bit = random.Intn(2) == 1
+			}
+			if bit {
+				perturbed[i] = 1
+			}
+		}
+		return perturbed, nil
+	}
+
+	if params.Scale < 0 {
+		return nil, fmt.Errorf("negative noise scale %v", params.Scale)
+	}
+	var noise func() float64
+	switch params.Mechanism {
+	case "", "laplace":
+		noise = func() float64 {
+			if random.Intn(2) == 0 {
+				return -random.ExpFloat64() * params.Scale
+			}
+			return random.ExpFloat64() * params.Scale
+		}
+	case "gaussian":
+		noise = func() float64 { return random.NormFloat64() * params.Scale }
+	default:
+		return nil, fmt.Errorf("unknown mechanism %q", params.Mechanism)
+	}
+
+	for i, v := range clear {
+		perturbed[i] = v + int64(math.Round(noise()))
+	}
+	return perturbed, nil
+}
+
+// NewLocalDiffPCalibration computes what the querier needs to debias the aggregation of nbrDPs perturbed outputs.
+func NewLocalDiffPCalibration(params libdrynx.QueryLocalDiffP, nbrDPs int) libdrynx.LocalDiffPCalibration {
+	variance := 0.0
+	switch params.Mechanism {
+	case "", "laplace":
+		variance = 2 * params.Scale * params.Scale * float64(nbrDPs)
+	case "gaussian":
+		variance = params.Scale * params.Scale * float64(nbrDPs)
+	}
+
+	return libdrynx.LocalDiffPCalibration{
+		NbrDPs:          nbrDPs,
+		FlipProbability: params.FlipProbability,
+		Variance:        variance,
+	}
+}
+
+// DebiasLocally estimates, for each aggregated bit, how many DPs really set it. As each of them reported a one with
+// probability (1-p)*bit + p/2, the estimation is (count - n*p/2) / (1-p), rounded and bounded to [0, n].
+func DebiasLocally(aggregated []int64, calibration libdrynx.LocalDiffPCalibration) []int64 {
+	p, n := calibration.FlipProbability, float64(calibration.NbrDPs)
+	if p >= 1 {
+		// nothing to learn from random bits
+		return make([]int64, len(aggregated))
+	}
+
+	debiased := make([]int64, len(aggregated))
+	for i, count := range aggregated {
+		estimation := math.Round((float64(count) - n*p/2) / (1 - p))
+		debiased[i] = int64(math.Max(0, math.Min(n, estimation)))
+	}
+	return debiased
+}
@@ -0,0 +1,61 @@
+package libdrynxencoding_test
+
+import (
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPerturbLocallyBits tests the randomized response on bits
+func TestPerturbLocallyBits(t *testing.T) {
+	clear := []int64{0, 42, 0, 1}
+
+	perturbed, err := libdrynxencoding.PerturbLocally(clear, "bool_OR", libdrynx.QueryLocalDiffP{FlipProbability: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 1, 0, 1}, perturbed)
+
+	perturbed, err = libdrynxencoding.PerturbLocally(clear, "union", libdrynx.QueryLocalDiffP{FlipProbability: 1})
+	assert.NoError(t, err)
+	for _, v := range perturbed {
+		assert.True(t, v == 0 || v == 1)
+	}
+
+	_, err = libdrynxencoding.PerturbLocally(clear, "min", libdrynx.QueryLocalDiffP{FlipProbability: 2})
+	assert.Error(t, err)
+}
+
+// TestPerturbLocallyValues tests the additive noise on values
+func TestPerturbLocallyValues(t *testing.T) {
+	clear := make([]int64, 10000)
+	for _, mechanism := range []string{"laplace", "gaussian"} {
+		perturbed, err := libdrynxencoding.PerturbLocally(clear, "sum", libdrynx.QueryLocalDiffP{Mechanism: mechanism, Scale: 2})
+		assert.NoError(t, err)
+
+		sum, changed := int64(0), 0
+		for _, v := range perturbed {
+			sum += v
+			if v != 0 {
+				changed++
+			}
+		}
+		assert.InDelta(t, 0, float64(sum)/float64(len(clear)), 0.2, mechanism)
+		assert.True(t, changed > len(clear)/2, mechanism)
+	}
+
+	_, err := libdrynxencoding.PerturbLocally(clear, "sum", libdrynx.QueryLocalDiffP{Mechanism: "unknown", Scale: 2})
+	assert.Error(t, err)
+}
+
+// TestDebiasLocally tests the estimation of the real bits count
+func TestDebiasLocally(t *testing.T) {
+	calibration := libdrynxencoding.NewLocalDiffPCalibration(libdrynx.QueryLocalDiffP{FlipProbability: 0.5}, 10)
+	assert.Equal(t, 10, calibration.NbrDPs)
+
+	// 10 DPs: 2.5 expected ones from the noise
+	assert.Equal(t, []int64{0, 0, 1, 10, 10}, libdrynxencoding.DebiasLocally([]int64{0, 2, 3, 8, 10}, calibration))
+
+	calibration = libdrynxencoding.NewLocalDiffPCalibration(libdrynx.QueryLocalDiffP{Scale: 3}, 4)
+	assert.Equal(t, 72.0, calibration.Variance)
+	assert.Equal(t, []int64{0, 3}, libdrynxencoding.DebiasLocally([]int64{0, 3}, calibration))
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/montanaflynn/stats"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/onet/v3/log"
-	"go.dedis.ch/onet/v3/network"
 	"gonum.org/v1/gonum/integrate"
 	"gonum.org/v1/gonum/stat"
 	"gonum.org/v1/gonum/stat/combin"
@@ -30,9 +29,6 @@ var MinAreaCoefficients = []float64{-0.714761, -0.5, -0.0976419}
 // PolyApproxCoefficients is the number of approximated coefficients
 var PolyApproxCoefficients = MinAreaCoefficients
 
-// NumDps is the number of DPs
-var NumDps = 10
-
 // -------------------------
 // UnLynx framework specific
 // -------------------------
@@ -206,6 +202,20 @@ func EncodeLogisticRegressionWithProofs(xData [][]float64, yData []int64, lrPara
 func DecodeLogisticRegression(result []libunlynx.CipherText, privKey kyber.Scalar,
 	lrParameters libdrynx.LogisticRegressionParameters) []float64 {
 
+	approxCoefficientsPacked := make([]int64, len(result))
+
+	decryption := libunlynx.StartTimer("Decryption")
+	// decrypt the encrypted aggregated approximation coefficients
+	for i := 0; i < len(result); i++ {
+		approxCoefficientsPacked[i] = libunlynx.DecryptIntWithNeg(privKey, result[i])
+	}
+	libunlynx.EndTimer(decryption)
+
+	return ExecuteLogisticRegressionOnClient(approxCoefficientsPacked, lrParameters)
+}
+
+// ExecuteLogisticRegressionOnClient computes the weights from the decrypted approximation coefficients
+func ExecuteLogisticRegressionOnClient(approxCoefficientsPacked []int64, lrParameters libdrynx.LogisticRegressionParameters) []float64 {
 	N := lrParameters.NbrRecords
 	d := lrParameters.NbrFeatures
 
@@ -217,16 +227,6 @@ func DecodeLogisticRegression(result []libunlynx.CipherText, privKey kyber.Scala
 	step := lrParameters.Step
 	maxIterations := lrParameters.MaxIterations
 
-	nbrApproxCoefficients := len(result)
-	approxCoefficientsPacked := make([]int64, nbrApproxCoefficients)
-
-	decryption := libunlynx.StartTimer("Decryption")
-	// decrypt the encrypted aggregated approximation coefficients
-	for i := 0; i < len(result); i++ {
-		approxCoefficientsPacked[i] = libunlynx.DecryptIntWithNeg(privKey, result[i])
-	}
-	libunlynx.EndTimer(decryption)
-
 	gradientDescent := libunlynx.StartTimer("GradientDescent")
 	// unpack the aggregated approximation coefficients
 	approxCoefficients := make([][]int64, k)
@@ -1533,27 +1533,6 @@ func PartitionDataset(X [][]float64, y []int64, ratio float64, shuffle bool, see
 	return XTrain, yTrain, XTest, yTest
 }
 
-// GetDataForDataProvider returns data records from a file for a given data provider based on its id
-func GetDataForDataProvider(datasetName, filename string, dataProviderIdentity network.ServerIdentity) ([][]float64, []int64) {
-	var xForDP [][]float64
-	var yForDP []int64
-	X, y := LoadData(datasetName, filename)
-	dataProviderID := dataProviderIdentity.String()
-	dpID, err := strconv.Atoi(dataProviderID[len(dataProviderID)-2 : len(dataProviderID)-1])
-
-	if err == nil {
-		for i := 0; i < len(X); i++ {
-			if i%NumDps == dpID {
-				xForDP = append(xForDP, X[i])
-				yForDP = append(yForDP, y[i])
-			}
-		}
-		fmt.Println("DP", dataProviderIdentity.String(), " has:", len(xForDP), "records")
-	}
-
-	return xForDP, yForDP
-}
-
 // -----------------
 // Utility functions
 // -----------------
@@ -79,17 +79,26 @@ func EncodeModelEvaluationWithProofs(inputY []int64, inputPreds []int64, pubKey
 
 // DecodeModelEvaluation decrypts and computes the R-score statistic
 func DecodeModelEvaluation(result []libunlynx.CipherText, secKey kyber.Scalar) float64 {
+	values := make([]int64, len(result))
+	for i, ct := range result {
+		values[i] = libunlynx.DecryptIntWithNeg(secKey, ct)
+	}
+	return ExecuteModelEvaluationOnClient(values)
+}
+
+// ExecuteModelEvaluationOnClient computes the R-score statistic from the decrypted outputs
+func ExecuteModelEvaluationOnClient(values []int64) float64 {
 	//get the number of data samples
-	N := libunlynx.DecryptIntWithNeg(secKey, result[0])
+	N := values[0]
 
 	//get the sum of Ys
-	sumY := libunlynx.DecryptIntWithNeg(secKey, result[1])
+	sumY := values[1]
 
 	//get the sum of squares of Xs
-	sumYSquare := libunlynx.DecryptIntWithNeg(secKey, result[2])
+	sumYSquare := values[2]
 
 	//get the sum of Ys
-	sumDiffSquare := libunlynx.DecryptIntWithNeg(secKey, result[3])
+	sumDiffSquare := values[3]
 
 	B := float64(sumYSquare) - float64(sumY*sumY/N)
 	return float64(1) - float64(sumDiffSquare)/B
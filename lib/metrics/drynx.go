@@ -0,0 +1,30 @@
+package metrics
+
+import "time"
+
+// PhaseBuckets are the upper bounds, in seconds, of the durations of the phases, from encoding a few values to
+// verifying the proofs of large surveys.
+var PhaseBuckets = []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+var (
+	// PhaseDuration is the duration of the phases of the surveys, as measured by Phase.
+	PhaseDuration = NewHistogramVec("drynx_phase_duration_seconds",
+		"Duration of the phases of the surveys.", PhaseBuckets, "phase")
+	// Proofs counts the proofs handled by a verifying node, by type and outcome.
+	Proofs = NewCounterVec("drynx_proofs_total",
+		"Proofs handled by the verifying node, by type and outcome.", "type", "outcome")
+	// NeutralizedResponses counts the responses refused by the neutralizer of a data provider.
+	NeutralizedResponses = NewCounterVec("drynx_neutralized_responses_total",
+		"Responses refused by the neutralizer of the data provider, by dataset.", "dataset")
+	// Surveys counts the surveys run from an entry computing node, by operation and outcome.
+	Surveys = NewCounterVec("drynx_surveys_total",
+		"Surveys run from the computing node, by operation and outcome.", "operation", "outcome")
+)
+
+// Phase starts measuring a phase of a survey, observed in PhaseDuration by calling the returned function.
+func Phase(name string) func() {
+	start := time.Now()
+	return func() {
+		PhaseDuration.Observe(time.Since(start).Seconds(), name)
+	}
+}
@@ -0,0 +1,282 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is exposed in the Prometheus text format.
+type metric interface {
+	name() string
+	write(w io.Writer) error
+}
+
+var registry = struct {
+	sync.Mutex
+	metrics map[string]metric
+}{metrics: make(map[string]metric)}
+
+func register(m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.metrics[m.name()]; ok {
+		panic("metric already registered: " + m.name())
+	}
+	registry.metrics[m.name()] = m
+}
+
+// WriteTo writes every registered metric in the Prometheus text format, sorted by name.
+func WriteTo(w io.Writer) error {
+	registry.Lock()
+	metrics := make([]metric, 0, len(registry.metrics))
+	for _, m := range registry.metrics {
+		metrics = append(metrics, m)
+	}
+	registry.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	buffered := bufio.NewWriter(w)
+	for _, m := range metrics {
+		if err := m.write(buffered); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// Handler serves the registered metrics, to be scraped by Prometheus.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := WriteTo(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// vec holds the values of a metric for each combination of its labels' values.
+type vec struct {
+	metricName string
+	help       string
+	labels     []string
+
+	sync.Mutex
+	values map[string]interface{}
+	keys   map[string][]string
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{metricName: name, help: help, labels: labels,
+		values: make(map[string]interface{}), keys: make(map[string][]string)}
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+// get returns the value for the labels' values, created by create if new; the vec has to be locked.
+func (v *vec) get(labelValues []string, create func() interface{}) interface{} {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %v: %v label values for labels %v", v.metricName, len(labelValues), v.labels))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	value, ok := v.values[key]
+	if !ok {
+		value = create()
+		v.values[key] = value
+		v.keys[key] = append([]string{}, labelValues...)
+	}
+	return value
+}
+
+// sortedKeys returns the keys of the values, in a stable order; the vec has to be locked.
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *vec) writeHeader(w io.Writer, kind string) error {
+	_, err := fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", v.metricName, escape(v.help, false), v.metricName, kind)
+	return err
+}
+
+// labelPairs formats the labels with their values, followed by the extra pairs already formatted.
+func labelPairs(labels, values []string, extra ...string) string {
+	pairs := make([]string, 0, len(labels)+len(extra))
+	for i, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%v=\"%v\"", l, escape(values[i], true)))
+	}
+	pairs = append(pairs, extra...)
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(s string, quoted bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if quoted {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+	return s
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// CounterVec counts events, for each combination of its labels' values.
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec registers a counter.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, labels)}
+	register(c)
+	return c
+}
+
+// Add adds a positive delta to the counter of the labels' values.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metric " + c.metricName + ": counters can't decrease")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	value := c.get(labelValues, func() interface{} { return new(float64) }).(*float64)
+	*value += delta
+}
+
+// Inc adds one to the counter of the labels' values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.writeHeader(w, "counter"); err != nil {
+		return err
+	}
+	for _, k := range c.sortedKeys() {
+		value := *c.values[k].(*float64)
+		if _, err := fmt.Fprintf(w, "%v%v %v\n", c.metricName, labelPairs(c.labels, c.keys[k]), formatFloat(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CounterFunc is a counter kept elsewhere, read when exposed.
+type CounterFunc struct {
+	metricName string
+	help       string
+	read       func() float64
+}
+
+// NewCounterFunc registers a counter read with the given function.
+func NewCounterFunc(name, help string, read func() float64) *CounterFunc {
+	c := &CounterFunc{name, help, read}
+	register(c)
+	return c
+}
+
+func (c *CounterFunc) name() string {
+	return c.metricName
+}
+
+func (c *CounterFunc) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v counter\n%v %v\n",
+		c.metricName, escape(c.help, false), c.metricName, c.metricName, formatFloat(c.read()))
+	return err
+}
+
+type histogram struct {
+	counts []uint64 // for each bucket, not cumulated
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec counts the observed values in buckets, for each combination of its labels' values.
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds of its buckets, in increasing order.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metric " + name + ": buckets not sorted")
+	}
+
+	h := &HistogramVec{newVec(name, help, labels), buckets}
+	register(h)
+	return h
+}
+
+// Observe adds a value to the histogram of the labels' values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.Lock()
+	defer h.Unlock()
+
+	hist := h.get(labelValues, func() interface{} {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	}).(*histogram)
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		hist.counts[i]++
+	}
+	hist.sum += value
+	hist.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.Lock()
+	defer h.Unlock()
+
+	if err := h.writeHeader(w, "histogram"); err != nil {
+		return err
+	}
+	for _, k := range h.sortedKeys() {
+		hist := h.values[k].(*histogram)
+		labelValues := h.keys[k]
+
+		cumulated := uint64(0)
+		for i, upper := range h.buckets {
+			cumulated += hist.counts[i]
+			le := fmt.Sprintf("le=\"%v\"", formatFloat(upper))
+			if _, err := fmt.Fprintf(w, "%v_bucket%v %v\n", h.metricName, labelPairs(h.labels, labelValues, le), cumulated); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%v_bucket%v %v\n%v_sum%v %v\n%v_count%v %v\n",
+			h.metricName, labelPairs(h.labels, labelValues, `le="+Inf"`), hist.count,
+			h.metricName, labelPairs(h.labels, labelValues), formatFloat(hist.sum),
+			h.metricName, labelPairs(h.labels, labelValues), hist.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ldsec/drynx/lib/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exposed(t *testing.T) string {
+	var buf bytes.Buffer
+	require.NoError(t, metrics.WriteTo(&buf))
+	return buf.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	counter := metrics.NewCounterVec("test_counter_total", "Counted \"things\".", "kind")
+	counter.Inc("a")
+	counter.Add(2, "a")
+	counter.Inc(`b"c`)
+
+	assert.Contains(t, exposed(t), "# HELP test_counter_total Counted \"things\".\n"+
+		"# TYPE test_counter_total counter\n"+
+		"test_counter_total{kind=\"a\"} 3\n"+
+		"test_counter_total{kind=\"b\\\"c\"} 1\n")
+
+	assert.Panics(t, func() { counter.Inc() })
+	assert.Panics(t, func() { counter.Add(-1, "a") })
+	assert.Panics(t, func() { metrics.NewCounterVec("test_counter_total", "Again.") })
+}
+
+func TestCounterFunc(t *testing.T) {
+	value := 41.0
+	metrics.NewCounterFunc("test_counter_func_total", "Read.", func() float64 { value++; return value })
+
+	assert.Contains(t, exposed(t), "# TYPE test_counter_func_total counter\ntest_counter_func_total 42\n")
+}
+
+func TestHistogramVec(t *testing.T) {
+	histogram := metrics.NewHistogramVec("test_histogram_seconds", "Observed.", []float64{1, 5}, "phase")
+	for _, v := range []float64{0.5, 1, 3, 10} {
+		histogram.Observe(v, "p")
+	}
+
+	assert.Contains(t, exposed(t), "# TYPE test_histogram_seconds histogram\n"+
+		"test_histogram_seconds_bucket{phase=\"p\",le=\"1\"} 2\n"+
+		"test_histogram_seconds_bucket{phase=\"p\",le=\"5\"} 3\n"+
+		"test_histogram_seconds_bucket{phase=\"p\",le=\"+Inf\"} 4\n"+
+		"test_histogram_seconds_sum{phase=\"p\"} 14.5\n"+
+		"test_histogram_seconds_count{phase=\"p\"} 4\n")
+
+	assert.Panics(t, func() { metrics.NewHistogramVec("test_unsorted", "Unsorted.", []float64{5, 1}) })
+}
+
+func TestHandler(t *testing.T) {
+	metrics.Phase("test")()
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, "text/plain; version=0.0.4", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "drynx_phase_duration_seconds_count{phase=\"test\"} 1\n")
+}
@@ -3,6 +3,7 @@ package drynxproof
 import (
 	"errors"
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/metrics"
 	"github.com/ldsec/drynx/lib/obfuscation"
 	"github.com/ldsec/drynx/lib/range"
 	"github.com/ldsec/unlynx/lib"
@@ -26,6 +27,18 @@ const ProofTrue = int64(1)
 const proofReceived = int64(2)
 const proofFalseSign = int64(4)
 
+// Outcome describes the result of the verification of a proof, as put in the bitmap
+func Outcome(verificationResult int64) string {
+	switch verificationResult {
+	case ProofTrue:
+		return "verified"
+	case proofReceived:
+		// not sampled for verification
+		return "received"
+	}
+	return "failed"
+}
+
 //----------------------------------------------------------------------------------------------------------------------
 // PROOFs' Structs
 //----------------------------------------------------------------------------------------------------------------------
@@ -135,6 +148,7 @@ func NewRangeProofRequest(proof *libdrynxrange.RangeProofList, ID, senderID, dif
 func (rpr *RangeProofRequest) VerifyProof(source network.ServerIdentity, sq libdrynx.SurveyQuery) (int64, error) {
 	log.Lvl2("VN", source.String(), "handles range proof")
 	time := libunlynx.StartTimer(source.String() + "_VerifyRange")
+	defer metrics.Phase("verify_range")()
 	verifSign := int64(0)
 	err := error(nil)
 	wg := libunlynx.StartParallelize(1)
@@ -213,6 +227,7 @@ func NewAggregationProofRequest(proofs *libunlynxaggr.PublishedAggregationListPr
 func (apr *AggregationProofRequest) VerifyProof(source network.ServerIdentity, sq libdrynx.SurveyQuery) (int64, error) {
 	log.Lvl2("VN", source.String(), "handles aggregation proof")
 	time := libunlynx.StartTimer(source.String() + "_VerifyAggregation")
+	defer metrics.Phase("verify_aggregation")()
 
 	verifSign := int64(0)
 	err := error(nil)
@@ -290,6 +305,7 @@ func NewObfuscationProofRequest(proof *libdrynxobfuscation.PublishedListObfuscat
 func (apr *ObfuscationProofRequest) VerifyProof(source network.ServerIdentity, sq libdrynx.SurveyQuery) (int64, error) {
 	log.Lvl2("VN", source.String(), "handles obfuscation proof")
 	//time := libunlynx.StartTimer(source.String() + "_VerifyObfuscation")
+	defer metrics.Phase("verify_obfuscation")()
 
 	verifSign := int64(0)
 	err := error(nil)
@@ -366,6 +382,7 @@ func NewShuffleProofRequest(proof *libunlynxshuffle.PublishedShufflingProof, ID,
 func (spr *ShuffleProofRequest) VerifyProof(source network.ServerIdentity, sq libdrynx.SurveyQuery) (int64, error) {
 	log.Lvl2("VN", source.String(), "handles shuffle proof")
 	//time := libunlynx.StartTimer(source.String() + "_VerifyShuffle")
+	defer metrics.Phase("verify_shuffle")()
 
 	verifSign := int64(0)
 	err := error(nil)
@@ -444,6 +461,7 @@ func NewKeySwitchProofRequest(proof *libunlynxkeyswitch.PublishedKSListProof, ID
 func (kpr *KeySwitchProofRequest) VerifyProof(source network.ServerIdentity, sq libdrynx.SurveyQuery) (int64, error) {
 	log.Lvl2("VN", source.String(), "handles key switch proof")
 	timeRange := libunlynx.StartTimer(source.String() + "_VerifyKeySwitch")
+	defer metrics.Phase("verify_key_switch")()
 
 	verifSign := int64(0)
 	err := error(nil)
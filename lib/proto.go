@@ -75,6 +75,23 @@ type SurveyQuery struct {
 	RangeProofThreshold float64
 	// optional
 	KeySwitchingProofThreshold float64
+
+	// seconds given to the data providers to answer, zero to wait for all of them
+	// optional
+	Timeout int64
+	// fraction of the data providers of each computing node which must have answered when the timeout expires, all
+	// of them if zero
+	// optional
+	DPsQuorum float64
+
+	// collective key generated by the computing nodes, see StartDKG, encrypting the data instead of the aggregate
+	// key of RosterServers, so that the results are switched by any threshold of them
+	// optional
+	ThresholdKey kyber.Point
+
+	// querier's signature of the other fields, required by the nodes authorizing queriers
+	// optional
+	Signature []byte
 }
 
 // ResponseDP contains the data provider's response to be sent to the server.
@@ -82,6 +99,29 @@ type ResponseDP struct {
 	// group -> value(s)
 	// optional
 	Data map[string]*CipherVector
+
+	// set if the DPs perturbed their outputs
+	// optional
+	LocalDiffP *LocalDiffPCalibration
+
+	// data providers whose responses were aggregated
+	// optional
+	DPs []string
+
+	// computing nodes which switched the result to the querier's key, set for a survey under a threshold key
+	// optional
+	KeySwitchers []string
+}
+
+// LocalDiffPCalibration contains what the querier needs to debias a result perturbed by the DPs
+type LocalDiffPCalibration struct {
+	// optional
+	NbrDPs int
+	// optional
+	FlipProbability float64
+	// variance of the noise in each aggregated value
+	// optional
+	Variance float64
 }
 
 //PublishSignatureBytes is the same as PublishSignature but the signatures are in bytes
@@ -111,6 +151,18 @@ type QueryDiffP struct {
 	Limit float64
 }
 
+// QueryLocalDiffP contains the parameters of the perturbation done by each DP on its own output
+type QueryLocalDiffP struct {
+	// "laplace" (default) or "gaussian", for the noise added to values
+	// optional
+	Mechanism string
+	// optional
+	Scale float64
+	// probability to replace a bit by a random one
+	// optional
+	FlipProbability float64
+}
+
 // PublishSignatureBytesList wraps []PublishSignatureBytes.
 type PublishSignatureBytesList struct {
 	// optional
@@ -142,6 +194,8 @@ type Query struct {
 	Obfuscation bool
 	// optional
 	DiffP QueryDiffP
+	// optional
+	LocalDiffP QueryLocalDiffP
 
 	// identity skipchain simulation
 	// optional
@@ -156,6 +210,14 @@ type Query struct {
 	// allow to select which column to compute operation on
 	// optional
 	Selector []ColumnID
+
+	// dataset of the DPs to compute operation on, empty for the default one
+	// optional
+	Dataset string
+
+	// operations of a batch, run over the selected columns in a single data collection
+	// optional
+	Batch []Operation
 }
 
 // Operation defines the operation in the query
@@ -180,8 +242,6 @@ type LogisticRegressionParameters struct {
 	// optional
 	DatasetName string
 	// optional
-	FilePath string
-	// optional
 	NbrRecords int64
 	// optional
 	NbrFeatures int64
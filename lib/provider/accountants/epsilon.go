@@ -0,0 +1,229 @@
+package accountants
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"go.dedis.ch/onet/v3/log"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+const (
+	bucketSpent = "spent"
+	bucketAudit = "audit"
+	keyGlobal   = "global"
+)
+
+type epsilonBudget struct {
+	db                            *bbolt.DB
+	global, perQuerier, noiseless float64
+}
+
+// NewEpsilonBudget creates an Accountant storing the spent epsilons in the bbolt DB at the given path.
+// It refuses queries making the total spent epsilon exceed global, or the one of a querier exceed perQuerier;
+// a budget of zero is unlimited. Results released without noise are charged noiseless if it is positive, else
+// refused as soon as a budget is set.
+// Each decision is logged and stored in the DB for auditing.
+//
+// A querier is known by the public key of its query, which anyone can generate anew for each query: the budget per
+// querier only holds if the queriers are restricted to known ones.
+func NewEpsilonBudget(path string, global, perQuerier, noiseless float64) (provider.Accountant, error) {
+	if global < 0 || perQuerier < 0 {
+		return nil, errors.New("negative budget")
+	}
+	if noiseless < 0 {
+		return nil, errors.New("negative cost of the results without noise")
+	}
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return epsilonBudget{db, global, perQuerier, noiseless}, nil
+}
+
+// Sensitivity returns the L1 sensitivity of the encoded output of an operation, that is how much it can change
+// when a row is added or removed, bounded by the operation's range.
+func Sensitivity(op libdrynx.Operation) (float64, error) {
+	bound := math.Max(math.Abs(float64(op.QueryMin)), math.Abs(float64(op.QueryMax)))
+
+	switch op.NameOp {
+	case "frequencyCount", "bool_AND", "bool_OR":
+		return 1, nil
+	case "min", "max", "union", "inter":
+		return float64(op.NbrOutput), nil
+	case "sum", "mean", "variance":
+		if bound == 0 {
+			return 0, fmt.Errorf("operation %q needs a range to bound its sensitivity", op.NameOp)
+		}
+	}
+
+	switch op.NameOp {
+	case "sum":
+		return bound, nil
+	case "mean":
+		return bound + 1, nil
+	case "variance":
+		return bound*bound + bound + 1, nil
+	}
+
+	return 0, fmt.Errorf("unknown sensitivity for operation %q", op.NameOp)
+}
+
+// Epsilon returns the privacy loss of releasing the results of the query, computed for the Laplace mechanism
+// as the sensitivity of the operation over the noise scale; results without noise have an infinite loss.
+// With both the noise of the computing nodes and the local one of the data providers, the smallest loss holds.
+func Epsilon(query libdrynx.Query) (float64, error) {
+	// the operations of a batch are released together, their losses add up
+	if query.Operation.NameOp == libdrynx.BatchOperationName {
+		queries, err := query.SubQueries()
+		if err != nil {
+			return 0, err
+		}
+		total := 0.0
+		for _, q := range queries {
+			epsilon, err := Epsilon(q)
+			if err != nil {
+				return 0, err
+			}
+			total += epsilon
+		}
+		return total, nil
+	}
+
+	epsilon := math.Inf(1)
+
+	if libdrynx.AddDiffP(query.DiffP) {
+		if query.DiffP.LapScale <= 0 {
+			return 0, errors.New("non positive laplace scale")
+		}
+		sensitivity, err := Sensitivity(query.Operation)
+		if err != nil {
+			return 0, err
+		}
+		epsilon = sensitivity / query.DiffP.LapScale
+	}
+
+	if libdrynx.AddLocalDiffP(query.LocalDiffP) {
+		local, err := localEpsilon(query)
+		if err != nil {
+			return 0, err
+		}
+		epsilon = math.Min(epsilon, local)
+	}
+
+	return epsilon, nil
+}
+
+// localEpsilon returns the privacy loss of the perturbation of the output by each data provider: randomized bits,
+// kept with probability 1-p/2 and flipped with p/2, each lose log((2-p)/p), and noised values as for Laplace.
+func localEpsilon(query libdrynx.Query) (float64, error) {
+	ldp := query.LocalDiffP
+
+	if libdrynxencoding.IsBitOperation(query.Operation.NameOp) {
+		switch p := ldp.FlipProbability; {
+		case p < 0 || p > 1:
+			return 0, fmt.Errorf("flip probability %v not in [0, 1]", p)
+		case p == 0:
+			return math.Inf(1), nil
+		default:
+			return float64(query.Operation.NbrOutput) * math.Log((2-p)/p), nil
+		}
+	}
+
+	switch {
+	case ldp.Scale < 0:
+		return 0, fmt.Errorf("negative noise scale %v", ldp.Scale)
+	case ldp.Scale == 0:
+		return math.Inf(1), nil
+	case ldp.Mechanism != "" && ldp.Mechanism != "laplace":
+		return 0, fmt.Errorf("no epsilon for the %q mechanism", ldp.Mechanism)
+	}
+	sensitivity, err := Sensitivity(query.Operation)
+	if err != nil {
+		return 0, err
+	}
+	return sensitivity / ldp.Scale, nil
+}
+
+func getFloat(b *bbolt.Bucket, key string) float64 {
+	raw := b.Get([]byte(key))
+	if len(raw) != 8 {
+		return 0
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(raw))
+}
+
+func putFloat(b *bbolt.Bucket, key string, value float64) error {
+	raw := make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, math.Float64bits(value))
+	return b.Put([]byte(key), raw)
+}
+
+func (eb epsilonBudget) Spend(surveyID, querier string, query libdrynx.Query) error {
+	epsilon, refusal := Epsilon(query)
+	noiseless := refusal == nil && math.IsInf(epsilon, 1)
+	if noiseless {
+		switch {
+		case eb.noiseless > 0:
+			epsilon = eb.noiseless
+		case eb.global > 0 || eb.perQuerier > 0:
+			refusal = errors.New("results without noise not allowed by the budget")
+		}
+	}
+
+	err := eb.db.Update(func(tx *bbolt.Tx) error {
+		spent, err := tx.CreateBucketIfNotExists([]byte(bucketSpent))
+		if err != nil {
+			return err
+		}
+		audit, err := tx.CreateBucketIfNotExists([]byte(bucketAudit))
+		if err != nil {
+			return err
+		}
+
+		querierKey := "querier/" + querier
+		spentGlobal, spentQuerier := getFloat(spent, keyGlobal)+epsilon, getFloat(spent, querierKey)+epsilon
+		if refusal == nil && eb.global > 0 && spentGlobal > eb.global {
+			refusal = fmt.Errorf("global budget exceeded: %v > %v", spentGlobal, eb.global)
+		}
+		if refusal == nil && eb.perQuerier > 0 && spentQuerier > eb.perQuerier {
+			refusal = fmt.Errorf("querier budget exceeded: %v > %v", spentQuerier, eb.perQuerier)
+		}
+
+		decision := "accepted"
+		if refusal != nil {
+			decision = "refused: " + refusal.Error()
+		}
+		if noiseless {
+			decision = "without noise, " + decision
+		}
+		record := fmt.Sprintf("survey %v by %v, operation %v, epsilon %v, %v", surveyID, querier, query.Operation.NameOp, epsilon, decision)
+		log.Info("[ACCOUNTANT]", record)
+
+		key := time.Now().UTC().Format(time.RFC3339Nano) + "/" + surveyID
+		if err := audit.Put([]byte(key), []byte(record)); err != nil {
+			return err
+		}
+
+		if refusal != nil {
+			return nil
+		}
+		if err := putFloat(spent, keyGlobal, spentGlobal); err != nil {
+			return err
+		}
+		return putFloat(spent, querierKey, spentQuerier)
+	})
+	if err != nil {
+		return err
+	}
+
+	return refusal
+}
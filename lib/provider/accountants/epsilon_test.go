@@ -0,0 +1,105 @@
+package accountants_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider/accountants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sumQuery(lapScale float64) libdrynx.Query {
+	return libdrynx.Query{
+		Operation: libdrynx.Operation{NameOp: "sum", NbrInput: 1, NbrOutput: 1, QueryMin: 0, QueryMax: 10},
+		DiffP:     libdrynx.QueryDiffP{LapMean: 0, LapScale: lapScale, NoiseListSize: 10, Quanta: 1, Scale: 1, Limit: 10},
+	}
+}
+
+func TestEpsilon(t *testing.T) {
+	epsilon, err := accountants.Epsilon(sumQuery(20))
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, epsilon)
+
+	epsilon, err = accountants.Epsilon(libdrynx.Query{Operation: libdrynx.Operation{NameOp: "sum", QueryMax: 10}})
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(epsilon, 1))
+
+	query := sumQuery(20)
+	query.Operation.QueryMax = 0
+	_, err = accountants.Epsilon(query)
+	assert.Error(t, err)
+
+	// perturbed by the data providers
+	query = libdrynx.Query{
+		Operation:  libdrynx.Operation{NameOp: "sum", NbrInput: 1, NbrOutput: 1, QueryMin: 0, QueryMax: 10},
+		LocalDiffP: libdrynx.QueryLocalDiffP{Scale: 5},
+	}
+	epsilon, err = accountants.Epsilon(query)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, epsilon)
+
+	query.DiffP = sumQuery(20).DiffP
+	epsilon, err = accountants.Epsilon(query)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, epsilon)
+
+	epsilon, err = accountants.Epsilon(libdrynx.Query{
+		Operation:  libdrynx.Operation{NameOp: "bool_OR", NbrOutput: 1},
+		LocalDiffP: libdrynx.QueryLocalDiffP{FlipProbability: 0.5},
+	})
+	require.NoError(t, err)
+	assert.InDelta(t, math.Log(3), epsilon, 1e-9)
+
+	query.LocalDiffP.Mechanism = "gaussian"
+	_, err = accountants.Epsilon(query)
+	assert.Error(t, err)
+	// a batch costs the sum of its operations
+	sum := sumQuery(20)
+	batch, err := libdrynx.NewBatchOperation([]libdrynx.Operation{sum.Operation, sum.Operation})
+	require.NoError(t, err)
+	sum.Operation, sum.Batch = batch, []libdrynx.Operation{sum.Operation, sum.Operation}
+	epsilon, err = accountants.Epsilon(sum)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, epsilon)
+}
+
+func TestEpsilonBudget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accountant")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	accountant, err := accountants.NewEpsilonBudget(filepath.Join(dir, "budget.db"), 2, 1, 0)
+	require.NoError(t, err)
+
+	assert.NoError(t, accountant.Spend("s1", "alice", sumQuery(20)))
+	assert.NoError(t, accountant.Spend("s2", "alice", sumQuery(20)))
+	assert.Error(t, accountant.Spend("s3", "alice", sumQuery(20)), "querier budget exceeded")
+
+	assert.NoError(t, accountant.Spend("s4", "bob", sumQuery(10)))
+	assert.Error(t, accountant.Spend("s5", "carol", sumQuery(20)), "global budget exceeded")
+
+	// released without noise, refused as no cost was set for it
+	assert.Error(t, accountant.Spend("s6", "bob", libdrynx.Query{Operation: libdrynx.Operation{NameOp: "sum", QueryMax: 10}}))
+}
+
+func TestEpsilonBudgetNoiseless(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accountant")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = accountants.NewEpsilonBudget(filepath.Join(dir, "negative.db"), 0, 0, -1)
+	assert.Error(t, err)
+
+	accountant, err := accountants.NewEpsilonBudget(filepath.Join(dir, "budget.db"), 0, 1, 1)
+	require.NoError(t, err)
+
+	noiseless := libdrynx.Query{Operation: libdrynx.Operation{NameOp: "sum", QueryMax: 10}}
+	assert.NoError(t, accountant.Spend("s1", "alice", noiseless))
+	assert.Error(t, accountant.Spend("s2", "alice", noiseless), "querier budget exceeded")
+	assert.NoError(t, accountant.Spend("s3", "bob", noiseless))
+}
@@ -11,6 +11,50 @@ type Loader interface {
 
 // Neutralizer decides to release or not the results of a query.
 type Neutralizer interface {
-	// Vet checks if the results can be safely released.
-	Vet(libdrynx.Query, [][]float64) bool
+	// Vet checks if the results can be safely released, returning the reason of the refusal if not.
+	Vet(libdrynx.Query, [][]float64) error
+}
+
+// Suppressor alters the provided rows before they are vetted and encoded.
+// A Neutralizer can implement it to hide rows instead of refusing the whole query.
+// The columns past the selected ones, such as the identities of the rows, are kept along with their rows, unaltered.
+type Suppressor interface {
+	// Suppress returns the rows to release, derived from the provided ones.
+	Suppress(libdrynx.Query, [][]float64) [][]float64
+}
+
+// Identifier is implemented by Neutralizers telling the rows apart by the individual they are about. Their
+// identities are then provided along with the selected columns, by the same Loader call, and kept in line with the
+// rows by the Suppressors.
+type Identifier interface {
+	// IdentityColumn is the column identifying the individual of each row, empty if the rows aren't identified.
+	IdentityColumn() libdrynx.ColumnID
+	// VetIdentified is Vet, also given the identity of each row.
+	VetIdentified(query libdrynx.Query, results [][]float64, identities []float64) error
+	// Record notes that the rows of the given identities were released for the query.
+	Record(query libdrynx.Query, identities []float64)
+}
+
+// Accountant keeps track of the privacy loss caused by the results released to queriers.
+type Accountant interface {
+	// Spend charges the query to the querier's budget, returning the reason of the refusal if it is exceeded.
+	Spend(surveyID, querier string, query libdrynx.Query) error
+}
+
+// Versioned is implemented by Loaders whose data can change over time.
+type Versioned interface {
+	// ProvideWithVersion is Provide, also returning the version of the data it was taken from.
+	ProvideWithVersion(libdrynx.Query) ([][]float64, string, error)
+}
+
+// Bounded is implemented by Loaders able to stop reading once a number of rows is exceeded.
+type Bounded interface {
+	// ProvideAtMost is ProvideWithVersion, failing as soon as more than maxRows rows are read; zero is unlimited.
+	ProvideAtMost(query libdrynx.Query, maxRows uint) ([][]float64, string, error)
+}
+
+// Reloader is implemented by Loaders able to reload their data on demand.
+type Reloader interface {
+	// Reload swaps in the current data, keeping the previous one on failure.
+	Reload() error
 }
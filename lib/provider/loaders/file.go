@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 
@@ -25,6 +26,17 @@ func NewFileLoader(path string) (provider.Loader, error) {
 }
 
 func (f fileLoader) Provide(query libdrynx.Query) ([][]float64, error) {
+	data, _, err := f.ProvideAtMost(query, 0)
+	return data, err
+}
+
+// ProvideAtMost stops reading the file at the first row past maxRows; the file is not versioned.
+func (f fileLoader) ProvideAtMost(query libdrynx.Query, maxRows uint) ([][]float64, string, error) {
+	data, err := f.provide(query, maxRows)
+	return data, "", err
+}
+
+func (f fileLoader) provide(query libdrynx.Query, maxRows uint) ([][]float64, error) {
 	if query.Operation.NbrInput != len(query.Selector) {
 		return nil, errors.New("malformed query")
 	}
@@ -58,25 +70,42 @@ func (f fileLoader) Provide(query libdrynx.Query) ([][]float64, error) {
 		}
 	}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
-	}
-
+	// only keep the selected columns in memory
+	reader.ReuseRecord = true
 	ret := make([][]float64, query.Operation.NbrInput)
-	for i, index := range selectorIndexes {
-		arr := make([]float64, len(records))
-		for j, r := range records {
+	for rows := uint(0); ; rows++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if maxRows != 0 && rows == maxRows {
+			return nil, fmt.Errorf("more than %v rows", maxRows)
+		}
+
+		for i, index := range selectorIndexes {
 			// TODO default value is zero then, which might be incorrect
-			if r[index] != "" {
-				arr[j], err = strconv.ParseFloat(r[index], 64)
+			var value float64
+			if record[index] != "" {
+				value, err = strconv.ParseFloat(record[index], 64)
 				if err != nil {
 					return nil, err
 				}
 			}
+			ret[i] = append(ret[i], value)
 		}
-		ret[i] = arr
 	}
 
+	for i := range ret {
+		if ret[i] == nil {
+			ret[i] = []float64{}
+		}
+	}
 	return ret, nil
 }
+
+func (f fileLoader) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,243 @@
+package loaders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.dedis.ch/onet/v3/log"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+// JoinKind is how the rows of the tables are linked.
+type JoinKind int
+
+const (
+	// InnerJoin keeps only the rows having a match in every table.
+	InnerJoin JoinKind = iota
+	// LeftJoin keeps every row of the first table, handling the missing matches with a MissingPolicy.
+	LeftJoin
+)
+
+// MissingPolicy is what to do with the values missing in a left join.
+type MissingPolicy int
+
+const (
+	// FillZero replaces the missing values by zeros.
+	FillZero MissingPolicy = iota
+	// DropRow removes the rows with missing values, as an inner join.
+	DropRow
+	// RefuseQuery fails to provide anything.
+	RefuseQuery
+)
+
+// JoinedTable is a table of a join, providing the given columns besides the key.
+type JoinedTable struct {
+	Loader  provider.Loader
+	Columns []libdrynx.ColumnID
+}
+
+type join struct {
+	key     libdrynx.ColumnID
+	kind    JoinKind
+	missing MissingPolicy
+	maxRows uint
+	tables  []JoinedTable
+}
+
+// NewJoin creates a Loader linking the rows of the tables having the same value in the key column; the first
+// table is the one kept in a left join. Only the selected columns are loaded from the tables and, if maxRows is not
+// zero, queries reading more rows from a table, or joining in more rows, are refused, bounding the memory used: the
+// tables' loaders then have to be provider.Bounded, stopping to read past maxRows.
+// The first table is walked through row by row, only the others are indexed on their key.
+func NewJoin(key libdrynx.ColumnID, kind JoinKind, missing MissingPolicy, maxRows uint, tables []JoinedTable) (provider.Loader, error) {
+	if len(tables) < 2 {
+		return nil, errors.New("need at least two tables to join")
+	}
+
+	seen := map[libdrynx.ColumnID]bool{key: true}
+	for i, t := range tables {
+		if t.Loader == nil {
+			return nil, errors.New("table without loader")
+		}
+		if _, ok := t.Loader.(provider.Bounded); maxRows != 0 && !ok {
+			return nil, fmt.Errorf("table %v can't bound the rows it reads", i)
+		}
+		for _, c := range t.Columns {
+			if seen[c] {
+				return nil, fmt.Errorf("column '%s' provided twice", c)
+			}
+			seen[c] = true
+		}
+	}
+
+	return join{key, kind, missing, maxRows, tables}, nil
+}
+
+// loadedTable is the subset of a table needed by a query.
+type loadedTable struct {
+	keys    []float64
+	columns [][]float64
+	index   map[float64][]int // key -> rows, nil for the walked table
+	version string
+}
+
+func (j join) load(query libdrynx.Query, table JoinedTable, columns []libdrynx.ColumnID, indexed bool) (loadedTable, error) {
+	subQuery := query
+	subQuery.Selector = append([]libdrynx.ColumnID{j.key}, columns...)
+	subQuery.Operation.NbrInput = len(subQuery.Selector)
+
+	var data [][]float64
+	var version string
+	var err error
+	if b, ok := table.Loader.(provider.Bounded); ok && j.maxRows != 0 {
+		data, version, err = b.ProvideAtMost(subQuery, j.maxRows)
+	} else if v, ok := table.Loader.(provider.Versioned); ok {
+		data, version, err = v.ProvideWithVersion(subQuery)
+	} else {
+		data, err = table.Loader.Provide(subQuery)
+	}
+	if err != nil {
+		return loadedTable{}, err
+	}
+	if len(data) != len(subQuery.Selector) {
+		return loadedTable{}, errors.New("table provided unexpected columns")
+	}
+
+	loaded := loadedTable{keys: data[0], columns: data[1:], version: version}
+	if !indexed {
+		return loaded, nil
+	}
+	loaded.index = make(map[float64][]int)
+	for i, k := range loaded.keys {
+		loaded.index[k] = append(loaded.index[k], i)
+	}
+	return loaded, nil
+}
+
+// Reload reloads the tables supporting it.
+func (j join) Reload() error {
+	for _, t := range j.tables {
+		if r, ok := t.Loader.(provider.Reloader); ok {
+			if err := r.Reload(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (j join) Provide(query libdrynx.Query) ([][]float64, error) {
+	data, _, err := j.ProvideWithVersion(query)
+	return data, err
+}
+
+// ProvideWithVersion returns the versions of the tables, comma-separated and empty for the unversioned ones.
+func (j join) ProvideWithVersion(query libdrynx.Query) ([][]float64, string, error) {
+	// where to find each selected column: table, then column in it; the key is at table -1
+	type location struct{ table, column int }
+	locations := make([]location, len(query.Selector))
+	needed := make([][]libdrynx.ColumnID, len(j.tables))
+	for i, s := range query.Selector {
+		locations[i] = location{-1, -1}
+		if s == j.key {
+			continue
+		}
+		for t, table := range j.tables {
+			for _, c := range table.Columns {
+				if c == s {
+					locations[i] = location{t, len(needed[t])}
+					needed[t] = append(needed[t], c)
+				}
+			}
+		}
+		if locations[i].table == -1 {
+			return nil, "", fmt.Errorf("unable to find '%s' in joined tables", s)
+		}
+	}
+
+	loaded := make([]loadedTable, len(j.tables))
+	for t, table := range j.tables {
+		var err error
+		if loaded[t], err = j.load(query, table, needed[t], t != 0); err != nil {
+			return nil, "", err
+		}
+	}
+
+	ret := make([][]float64, len(query.Selector))
+	rowsCount := uint(0)
+	// rows of each table forming the current joined row, -1 if missing
+	current := make([]int, len(j.tables))
+
+	var emit func(key float64, t int) error
+	emit = func(key float64, t int) error {
+		if t == len(j.tables) {
+			rowsCount++
+			if j.maxRows != 0 && rowsCount > j.maxRows {
+				return fmt.Errorf("join exceeds %v rows", j.maxRows)
+			}
+			for i, l := range locations {
+				value := key
+				if l.table != -1 {
+					value = 0
+					if row := current[l.table]; row != -1 {
+						value = loaded[l.table].columns[l.column][row]
+					}
+				}
+				ret[i] = append(ret[i], value)
+			}
+			return nil
+		}
+
+		rows := loaded[t].index[key]
+		if len(rows) == 0 {
+			if j.kind == InnerJoin {
+				return nil
+			}
+			switch j.missing {
+			case DropRow:
+				return nil
+			case RefuseQuery:
+				// the key can identify someone, it isn't sent back to the querier
+				log.Lvl2("no match in joined table", t, "for key", key)
+				return errors.New("unmatched key in join")
+			}
+			current[t] = -1
+			return emit(key, t+1)
+		}
+
+		for _, row := range rows {
+			current[t] = row
+			if err := emit(key, t+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for row, key := range loaded[0].keys {
+		current[0] = row
+		if err := emit(key, 1); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for i := range ret {
+		if ret[i] == nil {
+			ret[i] = []float64{}
+		}
+	}
+
+	versions := make([]string, len(loaded))
+	versioned := false
+	for t, l := range loaded {
+		versions[t] = l.version
+		versioned = versioned || l.version != ""
+	}
+	if !versioned {
+		return ret, "", nil
+	}
+	return ret, strings.Join(versions, ","), nil
+}
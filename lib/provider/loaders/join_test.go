@@ -0,0 +1,111 @@
+package loaders_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+	"github.com/ldsec/drynx/lib/provider/loaders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type table map[libdrynx.ColumnID][]float64
+
+func (t table) Provide(query libdrynx.Query) ([][]float64, error) {
+	ret := make([][]float64, len(query.Selector))
+	for i, s := range query.Selector {
+		column, ok := t[s]
+		if !ok {
+			return nil, fmt.Errorf("no column '%s'", s)
+		}
+		ret[i] = column
+	}
+	return ret, nil
+}
+
+// ProvideAtMost refuses the tables having more than maxRows rows, as if it stopped reading them.
+func (t table) ProvideAtMost(query libdrynx.Query, maxRows uint) ([][]float64, string, error) {
+	for _, column := range t {
+		if maxRows != 0 && uint(len(column)) > maxRows {
+			return nil, "", fmt.Errorf("more than %v rows", maxRows)
+		}
+	}
+	data, err := t.Provide(query)
+	return data, "", err
+}
+
+// unbounded is a table which can't stop reading.
+type unbounded struct{ t table }
+
+func (u unbounded) Provide(query libdrynx.Query) ([][]float64, error) {
+	return u.t.Provide(query)
+}
+
+var (
+	demographics = table{"id": {1, 2, 3}, "age": {30, 40, 50}}
+	labs         = table{"id": {1, 1, 3, 4}, "glucose": {5, 6, 7, 8}}
+)
+
+func newJoin(t *testing.T, kind loaders.JoinKind, missing loaders.MissingPolicy, maxRows uint) provider.Loader {
+	loader, err := loaders.NewJoin("id", kind, missing, maxRows, []loaders.JoinedTable{
+		{Loader: demographics, Columns: []libdrynx.ColumnID{"age"}},
+		{Loader: labs, Columns: []libdrynx.ColumnID{"glucose"}},
+	})
+	require.NoError(t, err)
+	return loader
+}
+
+func TestInnerJoin(t *testing.T) {
+	loader := newJoin(t, loaders.InnerJoin, loaders.FillZero, 0)
+
+	data, err := loader.Provide(selecting("age", "glucose"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{30, 30, 50}, {5, 6, 7}}, data)
+
+	data, err = loader.Provide(selecting("id"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 1, 3}}, data)
+
+	_, err = loader.Provide(selecting("unknown"))
+	assert.Error(t, err)
+}
+
+func TestLeftJoin(t *testing.T) {
+	data, err := newJoin(t, loaders.LeftJoin, loaders.FillZero, 0).Provide(selecting("age", "glucose"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{30, 30, 40, 50}, {5, 6, 0, 7}}, data)
+
+	data, err = newJoin(t, loaders.LeftJoin, loaders.DropRow, 0).Provide(selecting("glucose"))
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{5, 6, 7}}, data)
+
+	// the key of the unmatched row isn't told
+	_, err = newJoin(t, loaders.LeftJoin, loaders.RefuseQuery, 0).Provide(selecting("glucose"))
+	assert.EqualError(t, err, "unmatched key in join")
+}
+
+func TestJoinMaxRows(t *testing.T) {
+	_, err := newJoin(t, loaders.InnerJoin, loaders.FillZero, 2).Provide(selecting("age"))
+	assert.Error(t, err)
+
+	// only three joined rows, but four read from labs
+	_, err = newJoin(t, loaders.InnerJoin, loaders.FillZero, 3).Provide(selecting("age"))
+	assert.Error(t, err)
+
+	_, err = newJoin(t, loaders.InnerJoin, loaders.FillZero, 4).Provide(selecting("age"))
+	assert.NoError(t, err)
+
+	_, err = loaders.NewJoin("id", loaders.InnerJoin, loaders.FillZero, 0, []loaders.JoinedTable{
+		{Loader: demographics, Columns: []libdrynx.ColumnID{"age"}},
+		{Loader: labs, Columns: []libdrynx.ColumnID{"age"}},
+	})
+	assert.Error(t, err)
+
+	_, err = loaders.NewJoin("id", loaders.InnerJoin, loaders.FillZero, 4, []loaders.JoinedTable{
+		{Loader: demographics, Columns: []libdrynx.ColumnID{"age"}},
+		{Loader: unbounded{labs}, Columns: []libdrynx.ColumnID{"glucose"}},
+	})
+	assert.Error(t, err)
+}
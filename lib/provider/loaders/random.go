@@ -2,6 +2,7 @@ package loaders
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
 
 	"github.com/ldsec/drynx/lib"
@@ -21,6 +22,15 @@ func NewRandom(min, max float64, rows uint) (provider.Loader, error) {
 	return random{min, max, rows}, nil
 }
 
+// ProvideAtMost refuses before drawing anything if more than maxRows rows would be drawn.
+func (r random) ProvideAtMost(query libdrynx.Query, maxRows uint) ([][]float64, string, error) {
+	if maxRows != 0 && r.rows > maxRows {
+		return nil, "", fmt.Errorf("more than %v rows", maxRows)
+	}
+	data, err := r.Provide(query)
+	return data, "", err
+}
+
 func (r random) Provide(query libdrynx.Query) ([][]float64, error) {
 	ret := make([][]float64, len(query.Selector))
 
@@ -0,0 +1,136 @@
+package loaders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.dedis.ch/onet/v3/log"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+// ChangeDetection is how a reloading Loader notices that its file changed.
+type ChangeDetection int
+
+const (
+	// ByModTime reloads when the modification time or the size of the file changes.
+	ByModTime ChangeDetection = iota
+	// ByChecksum reloads when the content of the file changes, reading it fully for each query.
+	ByChecksum
+	// OnReload reloads only when asked to, such as on a signal.
+	OnReload
+)
+
+type reloading struct {
+	path      string
+	detection ChangeDetection
+	open      func(string) (provider.Loader, error)
+
+	mutex   sync.Mutex
+	loader  provider.Loader
+	version string
+}
+
+// NewReloading creates a Loader opening the file at the given path with open, and opening it again when it
+// changes. The swap happens between queries: a query is always answered with the data of a single version, which
+// is also returned via provider.Versioned. To avoid loading a partially written file, replace it by renaming.
+func NewReloading(path string, detection ChangeDetection, open func(string) (provider.Loader, error)) (provider.Loader, error) {
+	r := &reloading{path: path, detection: detection, open: open}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// stamp identifies the current content of the file.
+func (r *reloading) stamp() (string, error) {
+	if r.detection == ByChecksum {
+		file, err := os.Open(r.path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, file); err != nil {
+			return "", err
+		}
+		return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v/%v", info.ModTime().UTC().Format(time.RFC3339Nano), info.Size()), nil
+}
+
+// swap loads the file if its stamp differs from the loaded one; mutex must be held.
+func (r *reloading) swap(force bool) error {
+	version, err := r.stamp()
+	if err != nil {
+		return err
+	}
+	if !force && version == r.version {
+		return nil
+	}
+
+	loader, err := r.open(r.path)
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := r.loader.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Warnf("unable to close previous version of %v: %v", r.path, err)
+		}
+	}
+	r.loader, r.version = loader, version
+	log.Lvl2("loaded version", version, "of", r.path)
+
+	return nil
+}
+
+func (r *reloading) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.swap(true)
+}
+
+func (r *reloading) ProvideWithVersion(query libdrynx.Query) ([][]float64, string, error) {
+	return r.ProvideAtMost(query, 0)
+}
+
+// ProvideAtMost bounds the rows read if the opened loader supports it, else checks them once provided.
+func (r *reloading) ProvideAtMost(query libdrynx.Query, maxRows uint) ([][]float64, string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.detection != OnReload {
+		if err := r.swap(false); err != nil {
+			log.Warnf("unable to reload %v, keeping version %v: %v", r.path, r.version, err)
+		}
+	}
+
+	if b, ok := r.loader.(provider.Bounded); ok {
+		data, _, err := b.ProvideAtMost(query, maxRows)
+		return data, r.version, err
+	}
+	data, err := r.loader.Provide(query)
+	if err == nil && maxRows != 0 && len(data) > 0 && uint(len(data[0])) > maxRows {
+		return nil, "", fmt.Errorf("more than %v rows", maxRows)
+	}
+	return data, r.version, err
+}
+
+func (r *reloading) Provide(query libdrynx.Query) ([][]float64, error) {
+	data, _, err := r.ProvideWithVersion(query)
+	return data, err
+}
@@ -0,0 +1,60 @@
+package loaders_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ldsec/drynx/lib/provider"
+	"github.com/ldsec/drynx/lib/provider/loaders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replace atomically writes a new version of the file.
+func replace(t *testing.T, path, content string, modTime time.Time) {
+	tmp := path + ".tmp"
+	require.NoError(t, ioutil.WriteFile(tmp, []byte(content), 0600))
+	require.NoError(t, os.Chtimes(tmp, modTime, modTime))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestReloading(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loaders")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data.csv")
+	start := time.Now()
+
+	for _, detection := range []loaders.ChangeDetection{loaders.ByModTime, loaders.ByChecksum, loaders.OnReload} {
+		replace(t, path, "column\n1\n", start)
+		loader, err := loaders.NewReloading(path, detection, loaders.NewFileLoader)
+		require.NoError(t, err)
+
+		data, firstVersion, err := loader.(provider.Versioned).ProvideWithVersion(selecting("column"))
+		require.NoError(t, err)
+		assert.Equal(t, [][]float64{{1}}, data)
+
+		replace(t, path, "column\n2\n", start.Add(time.Second))
+		if detection == loaders.OnReload {
+			data, err = loader.Provide(selecting("column"))
+			require.NoError(t, err)
+			assert.Equal(t, [][]float64{{1}}, data)
+			require.NoError(t, loader.(provider.Reloader).Reload())
+		}
+
+		data, secondVersion, err := loader.(provider.Versioned).ProvideWithVersion(selecting("column"))
+		require.NoError(t, err)
+		assert.Equal(t, [][]float64{{2}}, data)
+		assert.NotEqual(t, firstVersion, secondVersion)
+
+		// keep serving the last version if the file disappears
+		require.NoError(t, os.Remove(path))
+		data, thirdVersion, err := loader.(provider.Versioned).ProvideWithVersion(selecting("column"))
+		require.NoError(t, err)
+		assert.Equal(t, [][]float64{{2}}, data)
+		assert.Equal(t, secondVersion, thirdVersion)
+	}
+}
@@ -0,0 +1,259 @@
+package loaders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+// Distribution describes how the values of a synthetic column are drawn.
+type Distribution interface {
+	// Quantile returns the value under which a proportion p of the drawn values falls.
+	Quantile(p float64) float64
+}
+
+type uniform struct{ min, max float64 }
+type normal struct{ mean, stdDev float64 }
+type bernoulli struct{ probability float64 }
+type categorical struct{ values, cumulated []float64 }
+type poisson struct{ lambda float64 }
+
+// NewUniform creates a Distribution of reals evenly spread in [min, max].
+func NewUniform(min, max float64) (Distribution, error) {
+	if min > max {
+		return nil, errors.New("minimum > maximum")
+	}
+	return uniform{min, max}, nil
+}
+
+// NewNormal creates a gaussian Distribution.
+func NewNormal(mean, stdDev float64) (Distribution, error) {
+	if stdDev < 0 {
+		return nil, errors.New("negative standard deviation")
+	}
+	return normal{mean, stdDev}, nil
+}
+
+// NewBernoulli creates a Distribution drawing one with the given probability, zero otherwise.
+func NewBernoulli(probability float64) (Distribution, error) {
+	if probability < 0 || probability > 1 {
+		return nil, errors.New("probability not in [0, 1]")
+	}
+	return bernoulli{probability}, nil
+}
+
+// NewCategorical creates a Distribution drawing one of the values, proportionally to its weight;
+// without weights, all values are equally likely.
+func NewCategorical(values, weights []float64) (Distribution, error) {
+	if len(values) == 0 {
+		return nil, errors.New("no values")
+	}
+	if weights == nil {
+		weights = make([]float64, len(values))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != len(values) {
+		return nil, errors.New("weights and values count differs")
+	}
+
+	cumulated := make([]float64, len(weights))
+	total := 0.0
+	for i, w := range weights {
+		if w < 0 {
+			return nil, errors.New("negative weight")
+		}
+		total += w
+		cumulated[i] = total
+	}
+	if total == 0 {
+		return nil, errors.New("null weights")
+	}
+	for i := range cumulated {
+		cumulated[i] /= total
+	}
+
+	return categorical{values, cumulated}, nil
+}
+
+// NewPoisson creates a Distribution of counts happening at the given rate.
+func NewPoisson(lambda float64) (Distribution, error) {
+	if lambda <= 0 {
+		return nil, errors.New("non positive rate")
+	}
+	return poisson{lambda}, nil
+}
+
+func (d uniform) Quantile(p float64) float64 {
+	return d.min + p*(d.max-d.min)
+}
+
+func (d normal) Quantile(p float64) float64 {
+	return d.mean + d.stdDev*math.Sqrt2*math.Erfinv(2*p-1)
+}
+
+func (d bernoulli) Quantile(p float64) float64 {
+	if p >= 1-d.probability {
+		return 1
+	}
+	return 0
+}
+
+func (d categorical) Quantile(p float64) float64 {
+	for i, c := range d.cumulated {
+		if p <= c {
+			return d.values[i]
+		}
+	}
+	return d.values[len(d.values)-1]
+}
+
+func (d poisson) Quantile(p float64) float64 {
+	// far enough in the tail to stop searching
+	limit := d.lambda + 20*math.Sqrt(d.lambda) + 20
+
+	cumulated := 0.0
+	k := 0.0
+	for ; k < limit; k++ {
+		logFactorial, _ := math.Lgamma(k + 1)
+		cumulated += math.Exp(k*math.Log(d.lambda) - d.lambda - logFactorial)
+		if p <= cumulated {
+			break
+		}
+	}
+	return k
+}
+
+// SyntheticColumn is a column generated by a synthetic Loader.
+type SyntheticColumn struct {
+	ID           libdrynx.ColumnID
+	Distribution Distribution
+}
+
+type synthetic struct {
+	columns map[libdrynx.ColumnID][]float64
+}
+
+// cholesky returns the lower triangular matrix L such as L*L^T is the given correlation matrix.
+func cholesky(correlations [][]float64) ([][]float64, error) {
+	size := len(correlations)
+	for _, row := range correlations {
+		if len(row) != size {
+			return nil, errors.New("correlations is not a square matrix")
+		}
+	}
+	for i, row := range correlations {
+		if row[i] != 1 {
+			return nil, errors.New("correlation of a column with itself should be one")
+		}
+		for j, c := range row {
+			if c != correlations[j][i] {
+				return nil, errors.New("correlations is not symmetric")
+			}
+			if c < -1 || c > 1 {
+				return nil, fmt.Errorf("correlation %v not in [-1, 1]", c)
+			}
+		}
+	}
+
+	lower := make([][]float64, size)
+	for i := range lower {
+		lower[i] = make([]float64, size)
+		for j := 0; j <= i; j++ {
+			sum := correlations[i][j]
+			for k := 0; k < j; k++ {
+				sum -= lower[i][k] * lower[j][k]
+			}
+
+			if i == j {
+				if sum < -1e-9 {
+					return nil, errors.New("correlations are inconsistent")
+				}
+				lower[i][i] = math.Sqrt(math.Max(sum, 0))
+			} else if lower[j][j] != 0 {
+				lower[i][j] = sum / lower[j][j]
+			}
+		}
+	}
+	return lower, nil
+}
+
+// NewSynthetic creates a Loader of rows drawn from the given distributions, using a random generator seeded with
+// seed so that the same data is provided for each query and for each run. If not nil, correlations is the matrix of
+// correlations between the columns, applied on a gaussian copula before drawing each value.
+func NewSynthetic(seed int64, rows uint, columns []SyntheticColumn, correlations [][]float64) (provider.Loader, error) {
+	if correlations == nil {
+		correlations = make([][]float64, len(columns))
+		for i := range correlations {
+			correlations[i] = make([]float64, len(columns))
+			correlations[i][i] = 1
+		}
+	}
+	if len(correlations) != len(columns) {
+		return nil, errors.New("correlations and columns count differs")
+	}
+	lower, err := cholesky(correlations)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[libdrynx.ColumnID][]float64, len(columns))
+	for _, c := range columns {
+		if _, ok := values[c.ID]; ok {
+			return nil, fmt.Errorf("column '%s' defined twice", c.ID)
+		}
+		values[c.ID] = make([]float64, rows)
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	independent := make([]float64, len(columns))
+	for j := uint(0); j < rows; j++ {
+		for i := range independent {
+			independent[i] = random.NormFloat64()
+		}
+
+		for i, c := range columns {
+			correlated := 0.0
+			for k := 0; k <= i; k++ {
+				correlated += lower[i][k] * independent[k]
+			}
+
+			// gaussian CDF, avoiding infinite quantiles
+			p := math.Erfc(-correlated/math.Sqrt2) / 2
+			p = math.Max(1e-12, math.Min(1-1e-12, p))
+
+			values[c.ID][j] = c.Distribution.Quantile(p)
+		}
+	}
+
+	return synthetic{values}, nil
+}
+
+// ProvideAtMost refuses before copying anything if the columns have more than maxRows rows.
+func (s synthetic) ProvideAtMost(query libdrynx.Query, maxRows uint) ([][]float64, string, error) {
+	for _, column := range s.columns {
+		if maxRows != 0 && uint(len(column)) > maxRows {
+			return nil, "", fmt.Errorf("more than %v rows", maxRows)
+		}
+	}
+	data, err := s.Provide(query)
+	return data, "", err
+}
+
+func (s synthetic) Provide(query libdrynx.Query) ([][]float64, error) {
+	ret := make([][]float64, len(query.Selector))
+	for i, id := range query.Selector {
+		column, ok := s.columns[id]
+		if !ok {
+			return nil, fmt.Errorf("unable to find '%s' in synthetic columns", id)
+		}
+		ret[i] = make([]float64, len(column))
+		copy(ret[i], column)
+	}
+	return ret, nil
+}
@@ -0,0 +1,110 @@
+package loaders_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider/loaders"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selecting(columns ...libdrynx.ColumnID) libdrynx.Query {
+	return libdrynx.Query{Operation: libdrynx.Operation{NbrInput: len(columns)}, Selector: columns}
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func TestSyntheticDistributions(t *testing.T) {
+	normal, err := loaders.NewNormal(10, 2)
+	require.NoError(t, err)
+	bernoulli, err := loaders.NewBernoulli(0.3)
+	require.NoError(t, err)
+	categorical, err := loaders.NewCategorical([]float64{1, 5}, []float64{3, 1})
+	require.NoError(t, err)
+	poisson, err := loaders.NewPoisson(4)
+	require.NoError(t, err)
+
+	loader, err := loaders.NewSynthetic(42, 10000, []loaders.SyntheticColumn{
+		{ID: "normal", Distribution: normal},
+		{ID: "bernoulli", Distribution: bernoulli},
+		{ID: "categorical", Distribution: categorical},
+		{ID: "poisson", Distribution: poisson},
+	}, nil)
+	require.NoError(t, err)
+
+	data, err := loader.Provide(selecting("normal", "bernoulli", "categorical", "poisson"))
+	require.NoError(t, err)
+	require.Len(t, data, 4)
+
+	assert.InDelta(t, 10, mean(data[0]), 0.1)
+	assert.InDelta(t, 0.3, mean(data[1]), 0.02)
+	assert.InDelta(t, 2, mean(data[2]), 0.1)
+	assert.InDelta(t, 4, mean(data[3]), 0.1)
+
+	for _, v := range data[2] {
+		assert.True(t, v == 1 || v == 5)
+	}
+
+	_, err = loader.Provide(selecting("unknown"))
+	assert.Error(t, err)
+}
+
+func TestSyntheticReproducible(t *testing.T) {
+	uniform, err := loaders.NewUniform(0, 100)
+	require.NoError(t, err)
+	columns := []loaders.SyntheticColumn{{ID: "column", Distribution: uniform}}
+
+	first, err := loaders.NewSynthetic(1, 10, columns, nil)
+	require.NoError(t, err)
+	second, err := loaders.NewSynthetic(1, 10, columns, nil)
+	require.NoError(t, err)
+	other, err := loaders.NewSynthetic(2, 10, columns, nil)
+	require.NoError(t, err)
+
+	firstData, err := first.Provide(selecting("column"))
+	require.NoError(t, err)
+	secondData, err := second.Provide(selecting("column"))
+	require.NoError(t, err)
+	otherData, err := other.Provide(selecting("column"))
+	require.NoError(t, err)
+	againData, err := first.Provide(selecting("column"))
+	require.NoError(t, err)
+
+	assert.Equal(t, firstData, secondData)
+	assert.Equal(t, firstData, againData)
+	assert.NotEqual(t, firstData, otherData)
+}
+
+func TestSyntheticCorrelations(t *testing.T) {
+	normal, err := loaders.NewNormal(0, 1)
+	require.NoError(t, err)
+	columns := []loaders.SyntheticColumn{{ID: "a", Distribution: normal}, {ID: "b", Distribution: normal}}
+
+	loader, err := loaders.NewSynthetic(3, 10000, columns, [][]float64{{1, -0.8}, {-0.8, 1}})
+	require.NoError(t, err)
+	data, err := loader.Provide(selecting("a", "b"))
+	require.NoError(t, err)
+
+	covariance, varianceA, varianceB := 0.0, 0.0, 0.0
+	for j := range data[0] {
+		covariance += data[0][j] * data[1][j]
+		varianceA += data[0][j] * data[0][j]
+		varianceB += data[1][j] * data[1][j]
+	}
+	assert.InDelta(t, -0.8, covariance/math.Sqrt(varianceA*varianceB), 0.05)
+
+	_, err = loaders.NewSynthetic(3, 10, columns, [][]float64{{1, 0.5}, {0.4, 1}})
+	assert.Error(t, err)
+	_, err = loaders.NewSynthetic(3, 10, columns, [][]float64{{1}})
+	assert.Error(t, err)
+	_, err = loaders.NewSynthetic(3, 10, columns, [][]float64{{1, 0.5}, {}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package neutralizers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+// CellPolicy is how to handle cells computed on too few rows.
+type CellPolicy int
+
+const (
+	// SuppressCells drops the rows of small cells.
+	SuppressCells CellPolicy = iota
+	// MergeCells moves the rows of small cells to the nearest big enough cell, dropping them if there is none.
+	MergeCells
+)
+
+// operations releasing one cell per value in [QueryMin, QueryMax]
+var cellsOperations = map[string]bool{
+	"frequencyCount": true,
+	"min":            true,
+	"max":            true,
+	"union":          true,
+	"inter":          true,
+}
+
+type minimumCellSize struct {
+	minimum uint
+	policy  CellPolicy
+}
+
+// NewMinimumCellSize creates a Neutralizer ensuring that each released cell is computed on at least minimum rows.
+// For operations releasing a cell per value, such as frequencyCount, each value of the first selected column, the
+// only one these operations encode, is a cell and it also implements provider.Suppressor, handling small cells
+// following the given policy; for others, the whole group is one cell.
+// The cells are not keyed on the group columns: the data providers release a single group, as queries can't group
+// by columns, so a cell is never split further. Grouped queries would need their cells keyed on the group values.
+func NewMinimumCellSize(minimum uint, policy CellPolicy) (provider.Neutralizer, error) {
+	if policy != SuppressCells && policy != MergeCells {
+		return nil, errors.New("unknown cell policy")
+	}
+	return minimumCellSize{minimum, policy}, nil
+}
+
+func (cs minimumCellSize) Vet(query libdrynx.Query, results [][]float64) error {
+	if !cellsOperations[query.Operation.NameOp] {
+		if count := rowsCount(results); count < cs.minimum {
+			return fmt.Errorf("only %v rows in cell, need at least %v", count, cs.minimum)
+		}
+		return nil
+	}
+
+	for value, size := range cellsSizes(results) {
+		if size < cs.minimum {
+			return fmt.Errorf("only %v rows in cell %v, need at least %v", size, value, cs.minimum)
+		}
+	}
+	return nil
+}
+
+func (cs minimumCellSize) Suppress(query libdrynx.Query, results [][]float64) [][]float64 {
+	if !cellsOperations[query.Operation.NameOp] || len(results) == 0 {
+		return results
+	}
+	rows := rowsCount(results)
+	sizes := cellsSizes(results)
+
+	// map each value to its released value, dropping it if absent
+	released := make(map[int64]int64, len(sizes))
+	for value, size := range sizes {
+		if size >= cs.minimum {
+			released[value] = value
+		}
+	}
+	if cs.policy == MergeCells {
+		for value, size := range sizes {
+			if size >= cs.minimum {
+				continue
+			}
+			if nearest, ok := nearestValue(value, sizes, cs.minimum); ok {
+				released[value] = nearest
+			}
+		}
+	}
+
+	ret := make([][]float64, len(results))
+	for i := range ret {
+		ret[i] = make([]float64, 0, rows)
+	}
+	for j := uint(0); j < rows; j++ {
+		value, ok := released[int64(results[0][j])]
+		if !ok {
+			continue
+		}
+		ret[0] = append(ret[0], float64(value))
+		for i := 1; i < len(results); i++ {
+			ret[i] = append(ret[i], results[i][j])
+		}
+	}
+	return ret
+}
+
+// cellsSizes returns the number of rows for each value of the first column, the whole rows being in a single group.
+func cellsSizes(results [][]float64) map[int64]uint {
+	sizes := make(map[int64]uint)
+	if len(results) == 0 {
+		return sizes
+	}
+
+	for _, v := range results[0][:rowsCount(results)] {
+		sizes[int64(v)]++
+	}
+	return sizes
+}
+
+// nearestValue returns the closest value to the given one having a size of at least minimum, the lowest one on ties.
+func nearestValue(value int64, sizes map[int64]uint, minimum uint) (int64, bool) {
+	var nearest, distance int64
+	found := false
+	for candidate, size := range sizes {
+		if size < minimum {
+			continue
+		}
+		d := candidate - value
+		if d < 0 {
+			d = -d
+		}
+		if !found || d < distance || (d == distance && candidate < nearest) {
+			nearest, distance, found = candidate, d, true
+		}
+	}
+	return nearest, found
+}
@@ -0,0 +1,51 @@
+package neutralizers_test
+
+import (
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+	"github.com/ldsec/drynx/lib/provider/neutralizers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func frequencyCountQuery() libdrynx.Query {
+	return libdrynx.Query{Operation: libdrynx.Operation{NameOp: "frequencyCount", NbrInput: 1, QueryMin: 0, QueryMax: 4}}
+}
+
+func TestMinimumCellSizeSuppress(t *testing.T) {
+	neutralizer, err := neutralizers.NewMinimumCellSize(2, neutralizers.SuppressCells)
+	require.NoError(t, err)
+	query := frequencyCountQuery()
+
+	data := [][]float64{{0, 1, 1, 2, 3, 3, 3}}
+	assert.Error(t, neutralizer.Vet(query, data))
+
+	suppressed := neutralizer.(provider.Suppressor).Suppress(query, data)
+	assert.Equal(t, [][]float64{{1, 1, 3, 3, 3}}, suppressed)
+	assert.NoError(t, neutralizer.Vet(query, suppressed))
+}
+
+func TestMinimumCellSizeMerge(t *testing.T) {
+	neutralizer, err := neutralizers.NewMinimumCellSize(2, neutralizers.MergeCells)
+	require.NoError(t, err)
+	query := frequencyCountQuery()
+
+	merged := neutralizer.(provider.Suppressor).Suppress(query, [][]float64{{0, 1, 1, 2, 3, 3, 4}})
+	assert.Equal(t, [][]float64{{1, 1, 1, 1, 3, 3, 3}}, merged)
+
+	dropped := neutralizer.(provider.Suppressor).Suppress(query, [][]float64{{0, 1, 2}})
+	assert.Equal(t, [][]float64{{}}, dropped)
+}
+
+func TestMinimumCellSizeWholeGroup(t *testing.T) {
+	neutralizer, err := neutralizers.NewMinimumCellSize(3, neutralizers.SuppressCells)
+	require.NoError(t, err)
+	query := libdrynx.Query{Operation: libdrynx.Operation{NameOp: "sum", NbrInput: 1}}
+
+	data := [][]float64{{1, 2}}
+	assert.Equal(t, data, neutralizer.(provider.Suppressor).Suppress(query, data))
+	assert.Error(t, neutralizer.Vet(query, data))
+	assert.NoError(t, neutralizer.Vet(query, [][]float64{{1, 2, 3}}))
+}
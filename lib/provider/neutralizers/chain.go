@@ -0,0 +1,141 @@
+package neutralizers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+type allOf struct {
+	neutralizers []provider.Neutralizer
+}
+
+// NewAll creates a Neutralizer vetting only when every given one vets.
+// It also suppresses rows with each given provider.Suppressor, in order, and identifies the rows as the first
+// given provider.Identifier.
+func NewAll(neutralizers ...provider.Neutralizer) provider.Neutralizer {
+	return allOf{neutralizers}
+}
+
+func (a allOf) Vet(query libdrynx.Query, results [][]float64) error {
+	for _, n := range a.neutralizers {
+		if err := n.Vet(query, results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a allOf) Suppress(query libdrynx.Query, results [][]float64) [][]float64 {
+	for _, n := range a.neutralizers {
+		if s, ok := n.(provider.Suppressor); ok {
+			results = s.Suppress(query, results)
+		}
+	}
+	return results
+}
+
+func (a allOf) IdentityColumn() libdrynx.ColumnID {
+	return identityColumn(a.neutralizers)
+}
+
+func (a allOf) VetIdentified(query libdrynx.Query, results [][]float64, identities []float64) error {
+	column := a.IdentityColumn()
+	for _, n := range a.neutralizers {
+		if err := vetIdentified(n, column, query, results, identities); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a allOf) Record(query libdrynx.Query, identities []float64) {
+	record(a.neutralizers, a.IdentityColumn(), query, identities)
+}
+
+type anyOf struct {
+	neutralizers []provider.Neutralizer
+}
+
+// NewAny creates a Neutralizer vetting when at least one of the given ones vets.
+// As it is not known beforehand which one will vet, rows are not suppressed; they are identified as by the first
+// given provider.Identifier.
+func NewAny(neutralizers ...provider.Neutralizer) provider.Neutralizer {
+	return anyOf{neutralizers}
+}
+
+func (a anyOf) Vet(query libdrynx.Query, results [][]float64) error {
+	if len(a.neutralizers) == 0 {
+		return errors.New("no neutralizer to vet")
+	}
+
+	reasons := make([]string, len(a.neutralizers))
+	for i, n := range a.neutralizers {
+		err := n.Vet(query, results)
+		if err == nil {
+			return nil
+		}
+		reasons[i] = err.Error()
+	}
+	return fmt.Errorf("none vetted: %v", strings.Join(reasons, "; "))
+}
+
+func (a anyOf) IdentityColumn() libdrynx.ColumnID {
+	return identityColumn(a.neutralizers)
+}
+
+func (a anyOf) VetIdentified(query libdrynx.Query, results [][]float64, identities []float64) error {
+	if len(a.neutralizers) == 0 {
+		return errors.New("no neutralizer to vet")
+	}
+
+	column := a.IdentityColumn()
+	reasons := make([]string, len(a.neutralizers))
+	for i, n := range a.neutralizers {
+		err := vetIdentified(n, column, query, results, identities)
+		if err == nil {
+			return nil
+		}
+		reasons[i] = err.Error()
+	}
+	return fmt.Errorf("none vetted: %v", strings.Join(reasons, "; "))
+}
+
+func (a anyOf) Record(query libdrynx.Query, identities []float64) {
+	record(a.neutralizers, a.IdentityColumn(), query, identities)
+}
+
+// identityColumn returns the column identifying the rows for the first provider.Identifier using one.
+func identityColumn(neutralizers []provider.Neutralizer) libdrynx.ColumnID {
+	for _, n := range neutralizers {
+		if i, ok := n.(provider.Identifier); ok && i.IdentityColumn() != "" {
+			return i.IdentityColumn()
+		}
+	}
+	return ""
+}
+
+// identifiedBy returns the neutralizer as a provider.Identifier if it identifies the rows with the given column.
+func identifiedBy(n provider.Neutralizer, column libdrynx.ColumnID) (provider.Identifier, bool) {
+	i, ok := n.(provider.Identifier)
+	return i, ok && column != "" && i.IdentityColumn() == column
+}
+
+// vetIdentified vets with the identities of the rows if the neutralizer identifies them with the given column.
+func vetIdentified(n provider.Neutralizer, column libdrynx.ColumnID, query libdrynx.Query, results [][]float64, identities []float64) error {
+	if i, ok := identifiedBy(n, column); ok {
+		return i.VetIdentified(query, results, identities)
+	}
+	return n.Vet(query, results)
+}
+
+func record(neutralizers []provider.Neutralizer, column libdrynx.ColumnID, query libdrynx.Query, identities []float64) {
+	for _, n := range neutralizers {
+		if i, ok := identifiedBy(n, column); ok {
+			i.Record(query, identities)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package neutralizers_test
+
+import (
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+	"github.com/ldsec/drynx/lib/provider/neutralizers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queryOn(operation string, columns ...libdrynx.ColumnID) libdrynx.Query {
+	return libdrynx.Query{
+		Operation: libdrynx.Operation{NameOp: operation, NbrInput: len(columns), QueryMin: 0, QueryMax: 4},
+		Selector:  columns,
+	}
+}
+
+func TestAllAndAny(t *testing.T) {
+	atLeastTwo := neutralizers.NewMinimumRowsCount(2)
+	atLeastFour := neutralizers.NewMinimumRowsCount(4)
+	data := [][]float64{{1, 2, 3}}
+	query := queryOn("sum", "a")
+
+	assert.NoError(t, neutralizers.NewAll().Vet(query, data))
+	assert.NoError(t, neutralizers.NewAll(atLeastTwo).Vet(query, data))
+	assert.Error(t, neutralizers.NewAll(atLeastTwo, atLeastFour).Vet(query, data))
+
+	assert.Error(t, neutralizers.NewAny().Vet(query, data))
+	assert.NoError(t, neutralizers.NewAny(atLeastTwo, atLeastFour).Vet(query, data))
+	assert.Error(t, neutralizers.NewAny(atLeastFour, atLeastFour).Vet(query, data))
+}
+
+func TestAllSuppresses(t *testing.T) {
+	cellSize, err := neutralizers.NewMinimumCellSize(2, neutralizers.SuppressCells)
+	require.NoError(t, err)
+	chain := neutralizers.NewAll(neutralizers.NewMinimumRowsCount(0), cellSize)
+
+	suppressed := chain.(provider.Suppressor).Suppress(queryOn("frequencyCount", "a"), [][]float64{{0, 1, 1}})
+	assert.Equal(t, [][]float64{{1, 1}}, suppressed)
+}
+
+func TestScopedOperations(t *testing.T) {
+	// never allow min/max on salary, only frequencyCount on diagnosis
+	chain := neutralizers.NewAll(
+		neutralizers.NewScoped([]libdrynx.ColumnID{"salary"}, nil, neutralizers.NewDeniedOperations([]string{"min", "max"})),
+		neutralizers.NewScoped([]libdrynx.ColumnID{"diagnosis"}, nil, neutralizers.NewAllowedOperations([]string{"frequencyCount"})),
+	)
+	data := [][]float64{{1, 2, 3}}
+
+	assert.Error(t, chain.Vet(queryOn("min", "salary"), data))
+	assert.NoError(t, chain.Vet(queryOn("sum", "salary"), data))
+	assert.NoError(t, chain.Vet(queryOn("min", "age"), data))
+	assert.Error(t, chain.Vet(queryOn("sum", "diagnosis"), data))
+	assert.NoError(t, chain.Vet(queryOn("frequencyCount", "diagnosis"), data))
+}
+
+func TestScopedByOperation(t *testing.T) {
+	scoped := neutralizers.NewScoped(nil, []string{"mean"}, neutralizers.NewMinimumRowsCount(5))
+	data := [][]float64{{1, 2, 3}}
+
+	assert.Error(t, scoped.Vet(queryOn("mean", "a"), data))
+	assert.NoError(t, scoped.Vet(queryOn("sum", "a"), data))
+}
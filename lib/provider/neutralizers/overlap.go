@@ -0,0 +1,301 @@
+package neutralizers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"go.dedis.ch/onet/v3/log"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+const bucketHistory = "history"
+
+// fingerprint identifies the rows used by a released query.
+type fingerprint struct {
+	Operation string
+	Dataset   string
+	Selector  []libdrynx.ColumnID
+	Rows      []uint64 // sorted hashes of the identity of each row
+	setHash   uint64
+}
+
+type querySetOverlap struct {
+	minimumDifference uint
+	historySize       uint
+	noiseScale        float64
+	identity          libdrynx.ColumnID
+	path              string
+
+	mutex   sync.Mutex
+	history []fingerprint
+	random  *rand.Rand
+}
+
+// NewQuerySetOverlap creates a Neutralizer protecting against differencing attacks: it remembers the rows used
+// by the last historySize released queries and detects a query using a set of rows differing from a previous one,
+// on the same dataset and columns, by less than minimumDifference rows. Rows are told apart by the individual they
+// are about, given by the identity column, as a provider.Identifier; the history is kept in the bbolt DB at the
+// given path, so that it outlives restarts.
+// If noiseScale is zero, such query is refused; otherwise it also implements provider.Suppressor, adding laplacian
+// noise of the given scale to each selected value of the rows.
+func NewQuerySetOverlap(minimumDifference, historySize uint, noiseScale float64, identity libdrynx.ColumnID, path string) (provider.Neutralizer, error) {
+	if historySize == 0 {
+		return nil, errors.New("empty history")
+	}
+	if noiseScale < 0 {
+		return nil, errors.New("negative noise scale")
+	}
+	if identity == "" {
+		return nil, errors.New("no identity column")
+	}
+
+	history, err := loadHistory(path, historySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &querySetOverlap{
+		minimumDifference: minimumDifference,
+		historySize:       historySize,
+		noiseScale:        noiseScale,
+		identity:          identity,
+		path:              path,
+
+		history: history,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// openHistory opens the history DB, only while loading or recording it, as the audit one.
+func openHistory(path string) (*bbolt.DB, error) {
+	if path == "" {
+		return nil, errors.New("no history DB")
+	}
+	return bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+}
+
+// loadHistory reads the last historySize fingerprints stored.
+func loadHistory(path string, historySize uint) ([]fingerprint, error) {
+	db, err := openHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var history []fingerprint
+	err = db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketHistory))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, raw []byte) error {
+			var f fingerprint
+			if err := json.Unmarshal(raw, &f); err != nil {
+				return fmt.Errorf("unable to read the history: %v", err)
+			}
+			f.setHash = setHash(f.Rows)
+			history = append(history, f)
+			return nil
+		})
+	})
+	if uint(len(history)) > historySize {
+		history = history[uint(len(history))-historySize:]
+	}
+	return history, err
+}
+
+func setHash(rows []uint64) uint64 {
+	buffer := make([]byte, 8)
+	hash := fnv.New64a()
+	for _, row := range rows {
+		binary.BigEndian.PutUint64(buffer, row)
+		hash.Write(buffer)
+	}
+	return hash.Sum64()
+}
+
+func newFingerprint(query libdrynx.Query, identities []float64) fingerprint {
+	rows := make([]uint64, len(identities))
+	buffer := make([]byte, 8)
+	for j, identity := range identities {
+		hash := fnv.New64a()
+		binary.BigEndian.PutUint64(buffer, math.Float64bits(identity))
+		hash.Write(buffer)
+		rows[j] = hash.Sum64()
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i] < rows[j] })
+
+	selector := make([]libdrynx.ColumnID, len(query.Selector))
+	copy(selector, query.Selector)
+
+	return fingerprint{query.Operation.NameOp, query.Dataset, selector, rows, setHash(rows)}
+}
+
+func (f fingerprint) sameData(other fingerprint) bool {
+	if f.Dataset != other.Dataset || len(f.Selector) != len(other.Selector) {
+		return false
+	}
+	for i, c := range f.Selector {
+		if other.Selector[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// difference returns the size of the symmetric difference of the rows.
+func (f fingerprint) difference(other fingerprint) uint {
+	if f.setHash == other.setHash && len(f.Rows) == len(other.Rows) {
+		return 0
+	}
+
+	var diff uint
+	i, j := 0, 0
+	for i < len(f.Rows) && j < len(other.Rows) {
+		switch {
+		case f.Rows[i] == other.Rows[j]:
+			i++
+			j++
+		case f.Rows[i] < other.Rows[j]:
+			diff++
+			i++
+		default:
+			diff++
+			j++
+		}
+	}
+	return diff + uint(len(f.Rows)-i) + uint(len(other.Rows)-j)
+}
+
+// check returns why the rows overlap too much with a previously released query.
+func (qso *querySetOverlap) check(query libdrynx.Query, identities []float64) error {
+	current := newFingerprint(query, identities)
+
+	qso.mutex.Lock()
+	defer qso.mutex.Unlock()
+
+	for _, previous := range qso.history {
+		if !current.sameData(previous) {
+			continue
+		}
+		if diff := current.difference(previous); diff > 0 && diff < qso.minimumDifference {
+			return fmt.Errorf("rows differ by only %v from a previous %v query, need at least %v", diff, previous.Operation, qso.minimumDifference)
+		}
+	}
+
+	return nil
+}
+
+func (qso *querySetOverlap) IdentityColumn() libdrynx.ColumnID {
+	return qso.identity
+}
+
+func (qso *querySetOverlap) Record(query libdrynx.Query, identities []float64) {
+	current := newFingerprint(query, identities)
+	raw, err := json.Marshal(current)
+	if err != nil {
+		log.Error("[OVERLAP]", "unable to store the released rows:", err)
+		return
+	}
+
+	qso.mutex.Lock()
+	defer qso.mutex.Unlock()
+
+	qso.history = append(qso.history, current)
+	if uint(len(qso.history)) > qso.historySize {
+		qso.history = qso.history[1:]
+	}
+
+	db, err := openHistory(qso.path)
+	if err != nil {
+		// still remembered until restart
+		log.Error("[OVERLAP]", "unable to store the released rows:", err)
+		return
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketHistory))
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := bucket.Put(key, raw); err != nil {
+			return err
+		}
+
+		// forget the oldest ones, in key order
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && uint(bucket.Stats().KeyN) > qso.historySize; k, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("[OVERLAP]", "unable to store the released rows:", err)
+	}
+}
+
+func (qso *querySetOverlap) Vet(query libdrynx.Query, results [][]float64) error {
+	if qso.noiseScale != 0 {
+		// already handled by Suppress
+		return nil
+	}
+	return errors.New("rows not identified")
+}
+
+func (qso *querySetOverlap) VetIdentified(query libdrynx.Query, results [][]float64, identities []float64) error {
+	if qso.noiseScale != 0 {
+		// already handled by Suppress
+		return nil
+	}
+	return qso.check(query, identities)
+}
+
+// Suppress adds noise to the selected values if the rows, identified by the column past them, overlap too much
+// with a previous query, or if they aren't identified.
+func (qso *querySetOverlap) Suppress(query libdrynx.Query, results [][]float64) [][]float64 {
+	if qso.noiseScale == 0 {
+		return results
+	}
+	selected := selectedCount(query, results)
+	if selected < len(results) && qso.check(query, results[selected]) == nil {
+		return results
+	}
+
+	qso.mutex.Lock()
+	defer qso.mutex.Unlock()
+
+	ret := make([][]float64, len(results))
+	for i, column := range results {
+		if i >= selected {
+			ret[i] = column
+			continue
+		}
+		ret[i] = make([]float64, len(column))
+		for j, v := range column {
+			u := qso.random.Float64() - 0.5
+			noise := -qso.noiseScale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+			ret[i][j] = math.Round(v + noise)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,132 @@
+package neutralizers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+	"github.com/ldsec/drynx/lib/provider/neutralizers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// release vets the results of the identified rows, recording them if they are released.
+func release(neutralizer provider.Neutralizer, query libdrynx.Query, results [][]float64, identities []float64) error {
+	identifier := neutralizer.(provider.Identifier)
+	if err := identifier.VetIdentified(query, results, identities); err != nil {
+		return err
+	}
+	identifier.Record(query, identities)
+	return nil
+}
+
+func newQuerySetOverlap(t *testing.T, historySize uint, noiseScale float64) (provider.Neutralizer, string) {
+	dir, err := ioutil.TempDir("", "overlap")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "history.db")
+
+	neutralizer, err := neutralizers.NewQuerySetOverlap(2, historySize, noiseScale, "id", path)
+	require.NoError(t, err)
+	return neutralizer, path
+}
+
+func TestQuerySetOverlapRefuse(t *testing.T) {
+	neutralizer, path := newQuerySetOverlap(t, 10, 0)
+	defer os.RemoveAll(filepath.Dir(path))
+	query := queryOn("sum", "a")
+
+	assert.Equal(t, libdrynx.ColumnID("id"), neutralizer.(provider.Identifier).IdentityColumn())
+
+	assert.NoError(t, release(neutralizer, query, [][]float64{{1, 1, 1, 1}}, []float64{1, 2, 3, 4}))
+	// same rows, same answer
+	assert.NoError(t, release(neutralizer, query, [][]float64{{1, 1, 1, 1}}, []float64{4, 3, 2, 1}))
+	// only one individual removed, even if another one has the same values
+	assert.Error(t, release(neutralizer, query, [][]float64{{1, 1, 1}}, []float64{1, 2, 3}))
+	// other columns are other rows
+	assert.NoError(t, release(neutralizer, queryOn("sum", "b"), [][]float64{{1, 1, 1}}, []float64{1, 2, 3}))
+	// so are other datasets
+	other := query
+	other.Dataset = "other"
+	assert.NoError(t, release(neutralizer, other, [][]float64{{1, 1, 1}}, []float64{1, 2, 3}))
+	// far enough from every previous query
+	assert.NoError(t, release(neutralizer, query, [][]float64{{1, 1}}, []float64{1, 2}))
+	// rows which aren't identified
+	assert.Error(t, neutralizer.Vet(query, [][]float64{{1, 1}}))
+}
+
+func TestQuerySetOverlapRecord(t *testing.T) {
+	neutralizer, path := newQuerySetOverlap(t, 10, 0)
+	defer os.RemoveAll(filepath.Dir(path))
+	identifier := neutralizer.(provider.Identifier)
+	query := queryOn("sum", "a")
+
+	assert.NoError(t, identifier.VetIdentified(query, [][]float64{{1, 2, 3, 4}}, []float64{1, 2, 3, 4}))
+	// first query was never released
+	assert.NoError(t, identifier.VetIdentified(query, [][]float64{{1, 2, 3}}, []float64{1, 2, 3}))
+}
+
+func TestQuerySetOverlapHistory(t *testing.T) {
+	neutralizer, path := newQuerySetOverlap(t, 1, 0)
+	defer os.RemoveAll(filepath.Dir(path))
+	query := queryOn("sum", "a")
+
+	assert.NoError(t, release(neutralizer, query, [][]float64{{1, 2, 3, 4}}, []float64{1, 2, 3, 4}))
+	assert.NoError(t, release(neutralizer, query, [][]float64{{5, 6, 7, 8}}, []float64{5, 6, 7, 8}))
+	// first query was forgotten
+	assert.NoError(t, release(neutralizer, query, [][]float64{{1, 2, 3}}, []float64{1, 2, 3}))
+}
+
+func TestQuerySetOverlapPersisted(t *testing.T) {
+	neutralizer, path := newQuerySetOverlap(t, 2, 0)
+	defer os.RemoveAll(filepath.Dir(path))
+	query := queryOn("sum", "a")
+
+	assert.NoError(t, release(neutralizer, query, [][]float64{{1, 2, 3, 4}}, []float64{1, 2, 3, 4}))
+	assert.NoError(t, release(neutralizer, query, [][]float64{{5, 6, 7, 8}}, []float64{5, 6, 7, 8}))
+	assert.NoError(t, release(neutralizer, query, [][]float64{{1, 2}}, []float64{1, 2}))
+
+	// as after a restart
+	restarted, err := neutralizers.NewQuerySetOverlap(2, 2, 0, "id", path)
+	require.NoError(t, err)
+	identifier := restarted.(provider.Identifier)
+	assert.Error(t, identifier.VetIdentified(query, [][]float64{{5, 6, 7}}, []float64{5, 6, 7}))
+	// first query was forgotten
+	assert.NoError(t, identifier.VetIdentified(query, [][]float64{{1, 2, 3, 4, 9}}, []float64{1, 2, 3, 4, 9}))
+}
+
+func TestQuerySetOverlapNoise(t *testing.T) {
+	neutralizer, path := newQuerySetOverlap(t, 10, 1000)
+	defer os.RemoveAll(filepath.Dir(path))
+	query := queryOn("sum", "a")
+	suppressor := neutralizer.(provider.Suppressor)
+
+	// identities following the selected column
+	data := [][]float64{{1, 2, 3, 4}, {1, 2, 3, 4}}
+	assert.Equal(t, data, suppressor.Suppress(query, data))
+	assert.NoError(t, release(neutralizer, query, data[:1], data[1]))
+
+	data = [][]float64{{1, 2, 3}, {1, 2, 3}}
+	suppressed := suppressor.Suppress(query, data)
+	assert.NotEqual(t, data[0], suppressed[0])
+	assert.Equal(t, data[1], suppressed[1])
+	assert.NoError(t, release(neutralizer, query, suppressed[:1], suppressed[1]))
+
+	// rows which aren't identified
+	data = [][]float64{{1, 2, 3, 4}}
+	assert.NotEqual(t, data, suppressor.Suppress(query, data))
+	assert.NoError(t, neutralizer.Vet(query, data))
+}
+
+func TestQuerySetOverlapNoIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = neutralizers.NewQuerySetOverlap(2, 10, 0, "", filepath.Join(dir, "history.db"))
+	assert.Error(t, err)
+	_, err = neutralizers.NewQuerySetOverlap(2, 10, 0, "id", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package neutralizers
+
+import (
+	"fmt"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+type scoped struct {
+	columns     []libdrynx.ColumnID
+	operations  []string
+	neutralizer provider.Neutralizer
+}
+
+// NewScoped creates a Neutralizer applying the given one only to queries selecting one of the columns
+// with one of the operations. An empty list of columns or operations matches every query.
+func NewScoped(columns []libdrynx.ColumnID, operations []string, neutralizer provider.Neutralizer) provider.Neutralizer {
+	return scoped{columns, operations, neutralizer}
+}
+
+func (s scoped) matches(query libdrynx.Query) bool {
+	if len(s.operations) > 0 && !containsOperation(s.operations, query.Operation.NameOp) {
+		return false
+	}
+	if len(s.columns) == 0 {
+		return true
+	}
+	for _, selected := range query.Selector {
+		for _, c := range s.columns {
+			if selected == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s scoped) Vet(query libdrynx.Query, results [][]float64) error {
+	if !s.matches(query) {
+		return nil
+	}
+	if err := s.neutralizer.Vet(query, results); err != nil {
+		if len(s.columns) > 0 {
+			return fmt.Errorf("on columns %v: %v", s.columns, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s scoped) Suppress(query libdrynx.Query, results [][]float64) [][]float64 {
+	suppressor, ok := s.neutralizer.(provider.Suppressor)
+	if !ok || !s.matches(query) {
+		return results
+	}
+	return suppressor.Suppress(query, results)
+}
+
+func (s scoped) IdentityColumn() libdrynx.ColumnID {
+	if i, ok := s.neutralizer.(provider.Identifier); ok {
+		return i.IdentityColumn()
+	}
+	return ""
+}
+
+func (s scoped) VetIdentified(query libdrynx.Query, results [][]float64, identities []float64) error {
+	if !s.matches(query) {
+		return nil
+	}
+	if err := vetIdentified(s.neutralizer, s.IdentityColumn(), query, results, identities); err != nil {
+		if len(s.columns) > 0 {
+			return fmt.Errorf("on columns %v: %v", s.columns, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s scoped) Record(query libdrynx.Query, identities []float64) {
+	if i, ok := identifiedBy(s.neutralizer, s.IdentityColumn()); ok && s.matches(query) {
+		i.Record(query, identities)
+	}
+}
+
+type allowedOperations struct {
+	operations []string
+}
+
+// NewAllowedOperations creates a Neutralizer vetting only the given operations.
+func NewAllowedOperations(operations []string) provider.Neutralizer {
+	return allowedOperations{operations}
+}
+
+func (ao allowedOperations) Vet(query libdrynx.Query, _ [][]float64) error {
+	if !containsOperation(ao.operations, query.Operation.NameOp) {
+		return fmt.Errorf("operation %q not allowed, only %v", query.Operation.NameOp, ao.operations)
+	}
+	return nil
+}
+
+type deniedOperations struct {
+	operations []string
+}
+
+// NewDeniedOperations creates a Neutralizer refusing the given operations.
+func NewDeniedOperations(operations []string) provider.Neutralizer {
+	return deniedOperations{operations}
+}
+
+func (do deniedOperations) Vet(query libdrynx.Query, _ [][]float64) error {
+	if containsOperation(do.operations, query.Operation.NameOp) {
+		return fmt.Errorf("operation %q denied", query.Operation.NameOp)
+	}
+	return nil
+}
+
+func containsOperation(operations []string, name string) bool {
+	for _, op := range operations {
+		if op == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package neutralizers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"go.dedis.ch/onet/v3/log"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+// RangePolicy is how to handle values outside of the range of a query.
+type RangePolicy int
+
+const (
+	// ClipValues replaces the values outside of the range by the nearest bound.
+	ClipValues RangePolicy = iota
+	// DropRows removes the rows having a value outside of the range.
+	DropRows
+	// RefuseOutOfRange refuses the queries on rows having a value outside of the range.
+	RefuseOutOfRange
+)
+
+const bucketAudit = "audit"
+
+type rangeEnforcement struct {
+	policy RangePolicy
+	audit  string
+}
+
+// NewRangeEnforcement creates a Neutralizer ensuring that the values to encode are in the range of the query, so
+// that honest data providers do not fail their range proofs nor pollute the aggregation. The values are in
+// [QueryMin, QueryMax], if set or for operations releasing a cell per value, such as frequencyCount. For the
+// operations summing them, sum, mean and variance, they are also in [0, u^l-1] for the range of the sum, and each
+// output is checked against its own range, so that a query can still be refused after clipping or dropping.
+// It also implements provider.Suppressor, handling out of range values following the given policy.
+// The number of affected rows is logged and, if audit is not empty, stored in the bbolt DB at this path.
+func NewRangeEnforcement(policy RangePolicy, audit string) (provider.Neutralizer, error) {
+	if policy != ClipValues && policy != DropRows && policy != RefuseOutOfRange {
+		return nil, errors.New("unknown range policy")
+	}
+	if audit != "" {
+		db, err := openAudit(audit)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return rangeEnforcement{policy, audit}, nil
+}
+
+// openAudit opens the audit DB, only while recording as values out of range should be rare.
+func openAudit(path string) (*bbolt.DB, error) {
+	return bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+}
+
+// sumOutputs returns the clear outputs of the operations summing the values of the first column, ordered as their
+// ranges, or nil for the other operations.
+func sumOutputs(operation string, column []float64) []float64 {
+	sum, squares := 0.0, 0.0
+	for _, v := range column {
+		sum += v
+		squares += v * v
+	}
+
+	switch operation {
+	case "sum":
+		return []float64{sum}
+	case "mean":
+		return []float64{sum, float64(len(column))}
+	case "variance":
+		return []float64{sum, float64(len(column)), squares}
+	}
+	return nil
+}
+
+// rangeMax returns the maximum value of a range [0, u^l-1], false if not set.
+func rangeMax(r *libdrynx.Int64List) (float64, bool) {
+	if r == nil || len(r.Content) < 2 || r.Content[0] == 0 || r.Content[1] == 0 {
+		return 0, false
+	}
+	return math.Pow(float64(r.Content[0]), float64(r.Content[1])) - 1, true
+}
+
+// bounds returns the minimum and maximum value to encode for the query, false if there is none.
+func (rangeEnforcement) bounds(query libdrynx.Query) (float64, float64, bool) {
+	op := query.Operation
+	if cellsOperations[op.NameOp] {
+		return float64(op.QueryMin), float64(op.QueryMax), true
+	}
+	// logistic regression encodes standardised features, not the values themselves
+	if op.NameOp == "logistic regression" {
+		return 0, 0, false
+	}
+
+	min, max := math.Inf(-1), math.Inf(1)
+	if op.QueryMin != 0 || op.QueryMax != 0 {
+		min, max = float64(op.QueryMin), float64(op.QueryMax)
+	}
+	if sumOutputs(op.NameOp, nil) != nil && len(query.Ranges) > 0 {
+		if u, ok := rangeMax(query.Ranges[0]); ok {
+			min, max = math.Max(min, 0), math.Min(max, u)
+		}
+	}
+	if math.IsInf(min, -1) && math.IsInf(max, 1) {
+		return 0, 0, false
+	}
+	return min, max, true
+}
+
+// record logs how the values out of range were handled, storing it in the audit DB if any.
+func (re rangeEnforcement) record(record string) {
+	log.Info("[RANGES]", record)
+	if re.audit == "" {
+		return
+	}
+
+	db, err := openAudit(re.audit)
+	if err != nil {
+		log.Error("[RANGES]", "unable to store the audit record:", err)
+		return
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		audit, err := tx.CreateBucketIfNotExists([]byte(bucketAudit))
+		if err != nil {
+			return err
+		}
+		seq, err := audit.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%v/%v", time.Now().UTC().Format(time.RFC3339Nano), seq)
+		return audit.Put([]byte(key), []byte(record))
+	})
+	if err != nil {
+		log.Error("[RANGES]", "unable to store the audit record:", err)
+	}
+}
+
+// outOfRange returns the rows having a value outside of the bounds.
+func outOfRange(results [][]float64, min, max float64) map[uint]bool {
+	rows := make(map[uint]bool)
+	for _, column := range results {
+		for j, v := range column {
+			if v < min || v > max {
+				rows[uint(j)] = true
+			}
+		}
+	}
+	return rows
+}
+
+func (re rangeEnforcement) Vet(query libdrynx.Query, results [][]float64) error {
+	min, max, ok := re.bounds(query)
+	if !ok {
+		return nil
+	}
+
+	if rows := outOfRange(results, min, max); len(rows) > 0 {
+		re.record(fmt.Sprintf("operation %v, %v rows outside of [%v, %v], refused", query.Operation.NameOp, len(rows), min, max))
+		return fmt.Errorf("%v rows outside of [%v, %v]", len(rows), min, max)
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	for i, output := range sumOutputs(query.Operation.NameOp, results[0]) {
+		if i >= len(query.Ranges) {
+			break
+		}
+		if u, ok := rangeMax(query.Ranges[i]); ok && (output < 0 || output > u) {
+			re.record(fmt.Sprintf("operation %v, output %v of %v outside of [0, %v], refused", query.Operation.NameOp, i, output, u))
+			return fmt.Errorf("output %v of %v outside of [0, %v]", i, output, u)
+		}
+	}
+
+	return nil
+}
+
+func (re rangeEnforcement) Suppress(query libdrynx.Query, results [][]float64) [][]float64 {
+	min, max, ok := re.bounds(query)
+	if !ok || re.policy == RefuseOutOfRange {
+		return results
+	}
+	selected := selectedCount(query, results)
+	rows := outOfRange(results[:selected], min, max)
+	if len(rows) == 0 {
+		return results
+	}
+
+	ret := make([][]float64, len(results))
+	for i, column := range results {
+		ret[i] = make([]float64, 0, len(column))
+		for j, v := range column {
+			if rows[uint(j)] && re.policy == DropRows {
+				continue
+			}
+			if i < selected {
+				v = math.Max(min, math.Min(max, v))
+			}
+			ret[i] = append(ret[i], v)
+		}
+	}
+
+	action := "clipped"
+	if re.policy == DropRows {
+		action = "dropped"
+	}
+	re.record(fmt.Sprintf("operation %v, %v rows outside of [%v, %v], %v", query.Operation.NameOp, len(rows), min, max, action))
+
+	return ret
+}
@@ -0,0 +1,119 @@
+package neutralizers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/bbolt"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+	"github.com/ldsec/drynx/lib/provider/neutralizers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeEnforcementCells(t *testing.T) {
+	query := frequencyCountQuery()
+	data := [][]float64{{-1, 0, 2, 4, 7}}
+
+	clip, err := neutralizers.NewRangeEnforcement(neutralizers.ClipValues, "")
+	require.NoError(t, err)
+	assert.Error(t, clip.Vet(query, data))
+	clipped := clip.(provider.Suppressor).Suppress(query, data)
+	assert.Equal(t, [][]float64{{0, 0, 2, 4, 4}}, clipped)
+	assert.NoError(t, clip.Vet(query, clipped))
+
+	drop, err := neutralizers.NewRangeEnforcement(neutralizers.DropRows, "")
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{0, 2, 4}}, drop.(provider.Suppressor).Suppress(query, data))
+
+	refuse, err := neutralizers.NewRangeEnforcement(neutralizers.RefuseOutOfRange, "")
+	require.NoError(t, err)
+	assert.Equal(t, data, refuse.(provider.Suppressor).Suppress(query, data))
+	assert.Error(t, refuse.Vet(query, data))
+}
+
+func TestRangeEnforcementRanges(t *testing.T) {
+	query := libdrynx.Query{
+		Operation: libdrynx.Operation{NameOp: "sum", NbrInput: 1},
+		Ranges:    []*libdrynx.Int64List{{Content: []int64{2, 4}}}, // [0, 15]
+	}
+
+	drop, err := neutralizers.NewRangeEnforcement(neutralizers.DropRows, "")
+	require.NoError(t, err)
+	dropped := drop.(provider.Suppressor).Suppress(query, [][]float64{{1, 20, 3, -2}})
+	assert.Equal(t, [][]float64{{1, 3}}, dropped)
+	assert.NoError(t, drop.Vet(query, dropped))
+
+	// each value fits but not their sum
+	assert.Error(t, drop.Vet(query, [][]float64{{10, 10}}))
+
+	query.Ranges = nil
+	assert.NoError(t, drop.Vet(query, [][]float64{{-100, 100}}))
+
+	_, err = neutralizers.NewRangeEnforcement(neutralizers.RangePolicy(42), "")
+	assert.Error(t, err)
+}
+
+func TestRangeEnforcementQueryRange(t *testing.T) {
+	query := libdrynx.Query{
+		Operation: libdrynx.Operation{NameOp: "sum", NbrInput: 1, QueryMin: 2, QueryMax: 5},
+		Ranges:    []*libdrynx.Int64List{{Content: []int64{2, 4}}}, // [0, 15]
+	}
+
+	clip, err := neutralizers.NewRangeEnforcement(neutralizers.ClipValues, "")
+	require.NoError(t, err)
+	assert.Error(t, clip.Vet(query, [][]float64{{1, 3}}))
+	assert.Equal(t, [][]float64{{2, 3, 5}}, clip.(provider.Suppressor).Suppress(query, [][]float64{{1, 3, 20}}))
+
+	// the range of the sum is stricter
+	query.Operation.QueryMax = 100
+	assert.Error(t, clip.Vet(query, [][]float64{{20}}))
+}
+
+func TestRangeEnforcementOutputs(t *testing.T) {
+	query := libdrynx.Query{
+		Operation: libdrynx.Operation{NameOp: "variance", NbrInput: 1},
+		Ranges: []*libdrynx.Int64List{
+			{Content: []int64{2, 5}}, // sum in [0, 31]
+			{Content: []int64{2, 2}}, // count in [0, 3]
+			{Content: []int64{2, 6}}, // sum of squares in [0, 63]
+		},
+	}
+
+	refuse, err := neutralizers.NewRangeEnforcement(neutralizers.RefuseOutOfRange, "")
+	require.NoError(t, err)
+	assert.NoError(t, refuse.Vet(query, [][]float64{{1, 7}}))
+	// sum of squares over its range
+	assert.Error(t, refuse.Vet(query, [][]float64{{5, 7}}))
+	// count over its range
+	assert.Error(t, refuse.Vet(query, [][]float64{{1, 1, 1, 1}}))
+}
+
+func TestRangeEnforcementAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ranges")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.db")
+
+	query := frequencyCountQuery()
+	drop, err := neutralizers.NewRangeEnforcement(neutralizers.DropRows, path)
+	require.NoError(t, err)
+	drop.(provider.Suppressor).Suppress(query, [][]float64{{-1, 0, 7}})
+	assert.Error(t, drop.Vet(query, [][]float64{{-1}}))
+
+	db, err := bbolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	records := 0
+	require.NoError(t, db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("audit")).ForEach(func(k, v []byte) error {
+			records++
+			return nil
+		})
+	}))
+	assert.Equal(t, 2, records)
+}
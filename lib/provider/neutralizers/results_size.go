@@ -1,6 +1,8 @@
 package neutralizers
 
 import (
+	"fmt"
+
 	"github.com/ldsec/drynx/lib"
 	"github.com/ldsec/drynx/lib/provider"
 )
@@ -9,11 +11,16 @@ type minimumResultsSize struct {
 	minimum uint
 }
 
-// NewMinimumResultsSize creates a Neutralizer vetting only when len(results) >= minimum
+// NewMinimumResultsSize creates a Neutralizer vetting only when len(results) >= minimum.
+// As results are given by column, it only checks the number of selected columns;
+// use NewMinimumRowsCount to ensure that enough rows are used.
 func NewMinimumResultsSize(minimum uint) provider.Neutralizer {
 	return minimumResultsSize{minimum}
 }
 
-func (rs minimumResultsSize) Vet(_ libdrynx.Query, results [][]float64) bool {
-	return uint(len(results)) >= rs.minimum
+func (rs minimumResultsSize) Vet(_ libdrynx.Query, results [][]float64) error {
+	if size := uint(len(results)); size < rs.minimum {
+		return fmt.Errorf("only %v columns in results, need at least %v", size, rs.minimum)
+	}
+	return nil
 }
@@ -0,0 +1,47 @@
+package neutralizers
+
+import (
+	"fmt"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
+)
+
+type minimumRowsCount struct {
+	minimum uint
+}
+
+// NewMinimumRowsCount creates a Neutralizer vetting only when the results contain at least minimum rows.
+func NewMinimumRowsCount(minimum uint) provider.Neutralizer {
+	return minimumRowsCount{minimum}
+}
+
+func (rc minimumRowsCount) Vet(_ libdrynx.Query, results [][]float64) error {
+	if count := rowsCount(results); count < rc.minimum {
+		return fmt.Errorf("only %v rows in results, need at least %v", count, rc.minimum)
+	}
+	return nil
+}
+
+// rowsCount returns the number of complete rows in the given columns.
+func rowsCount(results [][]float64) uint {
+	if len(results) == 0 {
+		return 0
+	}
+
+	count := len(results[0])
+	for _, column := range results[1:] {
+		if len(column) < count {
+			count = len(column)
+		}
+	}
+	return uint(count)
+}
+
+// selectedCount returns how many of the columns are the selected ones, the others being past them.
+func selectedCount(query libdrynx.Query, results [][]float64) int {
+	if n := len(query.Selector); n > 0 && n < len(results) {
+		return n
+	}
+	return len(results)
+}
@@ -0,0 +1,19 @@
+package neutralizers_test
+
+import (
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider/neutralizers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinimumRowsCount(t *testing.T) {
+	neutralizer := neutralizers.NewMinimumRowsCount(3)
+	query := libdrynx.Query{Operation: libdrynx.Operation{NameOp: "sum", NbrInput: 1}}
+
+	assert.Error(t, neutralizer.Vet(query, [][]float64{{1, 2}}))
+	assert.NoError(t, neutralizer.Vet(query, [][]float64{{1, 2, 3}}))
+	assert.Error(t, neutralizer.Vet(query, [][]float64{{1, 2, 3}, {1, 2}}))
+	assert.Error(t, neutralizer.Vet(query, nil))
+}
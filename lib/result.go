@@ -0,0 +1,44 @@
+package libdrynx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OutputNames names the values decoded for the operation, such as the counted value for each count of a
+// frequencyCount or the index of each coefficient of a regression; a single value is named as the operation.
+func (op Operation) OutputNames(nbrValues int) []string {
+	names := make([]string, nbrValues)
+	for i := range names {
+		switch {
+		case op.NameOp == "frequencyCount" || op.NameOp == "union" || op.NameOp == "inter":
+			names[i] = strconv.FormatInt(op.QueryMin+int64(i), 10)
+		case op.NameOp == "lin_reg" || op.NameOp == "logistic regression":
+			names[i] = fmt.Sprintf("coefficient %v", i)
+		case nbrValues == 1:
+			names[i] = op.NameOp
+		default:
+			names[i] = fmt.Sprintf("%v %v", op.NameOp, i)
+		}
+	}
+	return names
+}
+
+// ParseGroup decodes the key of a group of a response, as written by the DPs, to the values of its columns.
+func ParseGroup(group string) ([]int64, error) {
+	if !strings.HasPrefix(group, "[") || !strings.HasSuffix(group, "]") {
+		return nil, fmt.Errorf("invalid group <%v>", group)
+	}
+
+	fields := strings.Fields(group[1 : len(group)-1])
+	values := make([]int64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group <%v>: %v", group, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
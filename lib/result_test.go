@@ -0,0 +1,38 @@
+package libdrynx_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+func TestOutputNames(t *testing.T) {
+	sum, err := libdrynx.ChooseOperation("sum", 0, 0, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sum"}, sum.OutputNames(1))
+
+	freqCount, err := libdrynx.ChooseOperation("frequencyCount", -1, 2, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-1", "0", "1", "2"}, freqCount.OutputNames(4))
+
+	linReg, err := libdrynx.ChooseOperation("lin_reg", 0, 0, 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"coefficient 0", "coefficient 1", "coefficient 2"}, linReg.OutputNames(3))
+}
+
+func TestParseGroup(t *testing.T) {
+	for _, values := range [][]int64{{}, {0}, {1, -2, 3}} {
+		parsed, err := libdrynx.ParseGroup(fmt.Sprint(values))
+		require.NoError(t, err)
+		assert.Equal(t, values, parsed)
+	}
+
+	for _, group := range []string{"", "0", "[a]", "[1 2"} {
+		_, err := libdrynx.ParseGroup(group)
+		assert.Error(t, err, "group %q", group)
+	}
+}
@@ -0,0 +1,166 @@
+package libdrynx
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"sort"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/protobuf"
+)
+
+// writeField hashes the given bytes prefixed by their length, so that consecutive fields can't be confused.
+func writeField(h hash.Hash, b []byte) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(b)))
+	h.Write(size[:])
+	h.Write(b)
+}
+
+// signedDigest returns what the querier signs: a hash of the survey query without its signature and routing flag.
+// The maps are hashed in key order, as their protobuf encoding is not deterministic.
+func (sq SurveyQuery) signedDigest() ([]byte, error) {
+	serverToDP, idToPublic := sq.ServerToDP, sq.IDtoPublic
+	sq.Signature, sq.IntraMessage, sq.ServerToDP, sq.IDtoPublic = nil, false, nil, nil
+
+	encoded, err := protobuf.Encode(&sq)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	writeField(h, encoded)
+
+	cns := make([]string, 0, len(serverToDP))
+	for cn := range serverToDP {
+		cns = append(cns, cn)
+	}
+	sort.Strings(cns)
+	for _, cn := range cns {
+		writeField(h, []byte(cn))
+		if dps := serverToDP[cn]; dps != nil {
+			encoded, err := protobuf.Encode(dps)
+			if err != nil {
+				return nil, err
+			}
+			writeField(h, encoded)
+		}
+	}
+
+	ids := make([]string, 0, len(idToPublic))
+	for id := range idToPublic {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		writeField(h, []byte(id))
+		if public := idToPublic[id]; public != nil {
+			encoded, err := public.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			writeField(h, encoded)
+		}
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Sign signs the survey query as the querier, whose private key matches ClientPubKey.
+func (sq *SurveyQuery) Sign(private kyber.Scalar) error {
+	if sq.ClientPubKey == nil || !Suite.Point().Mul(private, nil).Equal(sq.ClientPubKey) {
+		return errors.New("private key not matching the querier's public key")
+	}
+
+	digest, err := sq.signedDigest()
+	if err != nil {
+		return err
+	}
+	sq.Signature, err = schnorr.Sign(Suite, private, digest)
+	return err
+}
+
+// VerifySignature checks that the survey query was signed by the querier owning ClientPubKey.
+func (sq SurveyQuery) VerifySignature() error {
+	if sq.ClientPubKey == nil {
+		return errors.New("no querier's public key")
+	}
+	if sq.Signature == nil {
+		return errors.New("not signed")
+	}
+
+	digest, err := sq.signedDigest()
+	if err != nil {
+		return err
+	}
+	if err := schnorr.Verify(Suite, sq.ClientPubKey, digest, sq.Signature); err != nil {
+		return errors.New("signature is not correct")
+	}
+	return nil
+}
+
+// Sign signs the cancellation as the querier of the survey.
+func (cs *CancelSurvey) Sign(private kyber.Scalar) error {
+	sig, err := schnorr.Sign(Suite, private, []byte("cancel "+cs.SurveyID))
+	cs.Signature = sig
+	return err
+}
+
+// VerifySignature checks that the cancellation was signed by the given querier.
+func (cs CancelSurvey) VerifySignature(querier kyber.Point) error {
+	if querier == nil || cs.Signature == nil {
+		return errors.New("not signed")
+	}
+	if err := schnorr.Verify(Suite, querier, []byte("cancel "+cs.SurveyID), cs.Signature); err != nil {
+		return errors.New("signature is not correct")
+	}
+	return nil
+}
+
+// signedDigest returns what the querier signs: a hash of the nodes' keys and of the threshold.
+func (sd StartDKG) signedDigest() ([]byte, error) {
+	h := sha256.New()
+	writeField(h, []byte("dkg"))
+	if sd.Roster != nil {
+		for _, si := range sd.Roster.List {
+			encoded, err := si.Public.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			writeField(h, encoded)
+		}
+	}
+	var threshold [8]byte
+	binary.BigEndian.PutUint64(threshold[:], uint64(sd.Threshold))
+	writeField(h, threshold[:])
+	return h.Sum(nil), nil
+}
+
+// Sign signs the key generation as the querier owning the given private key.
+func (sd *StartDKG) Sign(private kyber.Scalar) error {
+	digest, err := sd.signedDigest()
+	if err != nil {
+		return err
+	}
+	sd.Querier = Suite.Point().Mul(private, nil)
+	sd.Signature, err = schnorr.Sign(Suite, private, digest)
+	return err
+}
+
+// VerifySignature checks that the key generation was signed by the querier owning Querier.
+func (sd StartDKG) VerifySignature() error {
+	if sd.Querier == nil || sd.Signature == nil {
+		return errors.New("not signed")
+	}
+
+	digest, err := sd.signedDigest()
+	if err != nil {
+		return err
+	}
+	if err := schnorr.Verify(Suite, sd.Querier, digest, sd.Signature); err != nil {
+		return errors.New("signature is not correct")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package libdrynx
 
 import (
 	"encoding"
+	"fmt"
 	"sync"
 	"time"
 
@@ -75,6 +76,111 @@ type CloseDB struct {
 	Close int64
 }
 
+// GetDatasets is used to fetch the names of the datasets served by a DP
+type GetDatasets struct {
+}
+
+// Datasets is the reply to GetDatasets
+type Datasets struct {
+	Names []string
+}
+
+// SubmitSurveyQuery is used to start a survey without waiting for its result
+type SubmitSurveyQuery struct {
+	SQ SurveyQuery
+}
+
+// SurveyHandle is the reply to SubmitSurveyQuery, identifying the survey to poll
+type SurveyHandle struct {
+	SurveyID string
+}
+
+// GetSurveyStatus is used to fetch the progress of a submitted survey
+type GetSurveyStatus struct {
+	SurveyID string
+}
+
+// SurveyStatus is the reply to GetSurveyStatus, as seen by the computing node the survey was submitted to
+type SurveyStatus struct {
+	SurveyID string
+	// "submitted", "collection", "aggregation", "obfuscation", "key switching", "done" or "failed"
+	Phase string
+	// data providers of the survey, and how many of them answered, the ones of the other computing nodes once they
+	// finished their data collection
+	NbrDPs         int
+	NbrDPsAnswered int
+	// set when failed
+	Error string
+}
+
+// FetchSurveyResult is used to get the key-switched result of a finished survey
+type FetchSurveyResult struct {
+	SurveyID string
+}
+
+// CancelSurvey is used to stop a running survey, at the computing node it was sent to and then at all its nodes
+type CancelSurvey struct {
+	SurveyID string
+	// querier's signature, checked against the public key of the survey
+	Signature []byte
+}
+
+// GetRoles is used to fetch the roles a node takes in the surveys
+type GetRoles struct {
+}
+
+// Roles is the reply to GetRoles
+type Roles struct {
+	ComputingNode bool
+	DataProvider  bool
+	VerifyingNode bool
+}
+
+// ListSurveys is used to fetch the surveys held by a node
+type ListSurveys struct {
+}
+
+// SurveyInfo is the state of a survey at a node, for one of its roles
+type SurveyInfo struct {
+	SurveyID string
+	Role     string
+	// created, collecting, aggregating, done or failed
+	State string
+	// unix time of the last change of state
+	Since int64
+	Error string
+}
+
+// SurveysList is the reply to ListSurveys
+type SurveysList struct {
+	Surveys []SurveyInfo
+}
+
+// GetStatus is used to ask a node what it is doing
+type GetStatus struct {
+}
+
+// DatasetStatus describes a dataset served by a data provider, with the types of its loader and neutralizer
+type DatasetStatus struct {
+	Name        string
+	Loader      string
+	Neutralizer string
+}
+
+// Status is the reply to GetStatus
+type Status struct {
+	Roles    Roles
+	Datasets []DatasetStatus
+	// seconds since the node started
+	Uptime  int64
+	Surveys []SurveyInfo
+	// last block of the proofs skipchain, empty if none
+	SkipchainHead  string
+	SkipchainIndex int
+	// size in bytes of the database of the proofs
+	DBSize int64
+}
+
 // GetGenesis is the struct used to trigger the fetching of the genesis block
 type GetGenesis struct {
 }
@@ -324,6 +430,11 @@ func AddDiffP(qdf QueryDiffP) bool {
 	return !(qdf.LapMean == 0.0 && qdf.LapScale == 0.0 && qdf.NoiseListSize == 0 && qdf.Quanta == 0.0 && qdf.Scale == 0 && qdf.Limit == 0)
 }
 
+// AddLocalDiffP checks if local differential privacy is required or not
+func AddLocalDiffP(qldf QueryLocalDiffP) bool {
+	return !(qldf.Scale == 0.0 && qldf.FlipProbability == 0.0)
+}
+
 func checkRangesZeros(ranges []*Int64List) bool {
 	for _, v := range ranges {
 		if (*v).Content[0] != 0 || (*v).Content[1] != 0 {
@@ -421,6 +532,25 @@ func CheckParameters(sq SurveyQuery, diffP bool) bool {
 		}
 	}
 
+	if AddLocalDiffP(sq.Query.LocalDiffP) {
+		if sq.Query.Proofs != 0 {
+			result = false
+			message = message + "local diffP cannot be proven \n"
+		}
+		if sq.Query.Obfuscation {
+			result = false
+			message = message + "local diffP with obfuscation \n"
+		}
+		if sq.Query.LocalDiffP.FlipProbability < 0.0 || sq.Query.LocalDiffP.FlipProbability > 1.0 || sq.Query.LocalDiffP.Scale < 0.0 {
+			result = false
+			message = message + "local diffP parameters out of bounds \n"
+		}
+		if m := sq.Query.LocalDiffP.Mechanism; m != "" && m != "laplace" && m != "gaussian" {
+			result = false
+			message = message + "unknown local diffP mechanism \n"
+		}
+	}
+
 	if message != "" {
 		log.Lvl1(message)
 	}
@@ -463,27 +593,23 @@ func QueryToProofsNbrs(q SurveyQuery) []int {
 }
 
 // UpdateDB put in a given bucket the value as byte with given key.
-func UpdateDB(db *bbolt.DB, bucketName string, key string, value []byte) {
+func UpdateDB(db *bbolt.DB, bucketName string, key string, value []byte) error {
 	if err := db.Batch(func(tx *bbolt.Tx) error {
 		//Bucket with SurveyID server Adress
 		b, err := tx.CreateBucketIfNotExists([]byte(bucketName))
 		if err != nil {
-			log.Fatal("error")
+			return fmt.Errorf("bucket %v: %v", bucketName, err)
 		}
 		//Put at key previous block index, the bitmap
-		err = b.Put([]byte(key), value)
-		if err != nil {
-			log.Fatal("error insert")
-		}
-
-		return nil
+		return b.Put([]byte(key), value)
 	}); err != nil {
-		log.Fatal("Could not update DB", err)
+		return fmt.Errorf("could not update DB: %v", err)
 	}
+	return nil
 }
 
 // ChooseOperation sets the parameters according to the operation
-func ChooseOperation(operationName string, queryMin, queryMax, d int, cuttingFactor int) Operation {
+func ChooseOperation(operationName string, queryMin, queryMax, d int, cuttingFactor int) (Operation, error) {
 	operation := Operation{}
 
 	operation.NameOp = operationName
@@ -526,12 +652,12 @@ func ChooseOperation(operationName string, queryMin, queryMax, d int, cuttingFac
 	case "logistic regression":
 		break
 	default:
-		log.Fatal("Operation: <", operation, "> does not exist")
+		return Operation{}, fmt.Errorf("operation <%v> does not exist", operationName)
 	}
 
 	if cuttingFactor != 0 {
 		operation.NbrOutput = operation.NbrOutput * cuttingFactor
 	}
 
-	return operation
+	return operation, nil
 }
@@ -0,0 +1,70 @@
+package libdrynx
+
+import (
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/onet/v3"
+)
+
+// StartDKG asks a computing node to generate a collective key with the computing nodes of the roster. Its secret is
+// shared among them so that any Threshold of them can switch the results of the surveys encrypted under it.
+type StartDKG struct {
+	Roster    *onet.Roster
+	Threshold int
+
+	// querier asking for the key and its signature of the other fields, required by the nodes authorizing queriers
+	// optional
+	Querier   kyber.Point
+	Signature []byte
+}
+
+// CollectiveKey is the reply to StartDKG
+type CollectiveKey struct {
+	Key       kyber.Point
+	Threshold int
+}
+
+// KeyShare is the share of a computing node of a collective key generated by StartDKG
+type KeyShare struct {
+	Key       kyber.Point
+	Threshold int
+	// index of the node among the ones which generated the key
+	Index  int
+	Secret kyber.Scalar
+	// public keys of the nodes which generated the key, by index of their share
+	Holders []kyber.Point
+}
+
+// HolderIndex returns the index of the share held by the node of the given public key.
+func (ks KeyShare) HolderIndex(public kyber.Point) (int, bool) {
+	for i, h := range ks.Holders {
+		if h.Equal(public) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// CollectiveKey returns the key encrypting the data of the survey: the threshold key if set, else the aggregate key
+// of its computing nodes.
+func (sq SurveyQuery) CollectiveKey() kyber.Point {
+	if sq.ThresholdKey != nil {
+		return sq.ThresholdKey
+	}
+	return sq.RosterServers.Aggregate
+}
+
+// LagrangeCoefficient returns the coefficient of the share of the given index, interpolating the secret at zero from
+// the shares of the given indexes, numbered as the nodes which generated the key.
+func LagrangeCoefficient(index int, indexes []int) kyber.Scalar {
+	xi := Suite.Scalar().SetInt64(int64(index) + 1)
+	num, den := Suite.Scalar().One(), Suite.Scalar().One()
+	for _, j := range indexes {
+		if j == index {
+			continue
+		}
+		xj := Suite.Scalar().SetInt64(int64(j) + 1)
+		num.Mul(num, xj)
+		den.Mul(den, Suite.Scalar().Sub(xj, xi))
+	}
+	return num.Div(num, den)
+}
@@ -0,0 +1,26 @@
+package libdrynx_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/util/random"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+func TestLagrangeCoefficient(t *testing.T) {
+	secret := libdrynx.Suite.Scalar().Pick(random.New())
+	poly := share.NewPriPoly(libdrynx.Suite, 3, secret, random.New())
+	shares := poly.Shares(5)
+
+	for _, indexes := range [][]int{{0, 1, 2}, {1, 3, 4}, {4, 0, 2}} {
+		recovered := libdrynx.Suite.Scalar().Zero()
+		for _, i := range indexes {
+			term := libdrynx.Suite.Scalar().Mul(libdrynx.LagrangeCoefficient(i, indexes), shares[i].V)
+			recovered.Add(recovered, term)
+		}
+		assert.True(t, secret.Equal(recovered), "shares %v", indexes)
+	}
+}
@@ -1,9 +1,11 @@
 package protocols
 
 import (
+	"errors"
 	"fmt"
 	"github.com/ldsec/drynx/lib"
 	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/metrics"
 	"github.com/ldsec/drynx/lib/proof"
 	"github.com/ldsec/drynx/lib/provider"
 	"github.com/ldsec/drynx/lib/range"
@@ -13,8 +15,9 @@ import (
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
-	"math/rand"
+	"go.dedis.ch/onet/v3/network"
 	"sync"
+	"time"
 )
 
 // DataCollectionProtocolName is the registered name for the data provider protocol.
@@ -31,6 +34,10 @@ type AnnouncementDCMessage struct{}
 // DataCollectionMessage message that contains the data of each data provider
 type DataCollectionMessage struct {
 	DCMdata libdrynx.ResponseDPBytes
+	// version of the dataset used by the data provider, if known
+	DatasetVersion string
+	// set by a data provider refusing the survey, sending no data
+	Refusal string
 }
 
 // Structs
@@ -38,8 +45,9 @@ type DataCollectionMessage struct {
 
 // SurveyToDP is used to trigger the upload of data by a data provider
 type SurveyToDP struct {
-	SurveyID  string
-	Aggregate kyber.Point // the joint aggregate key to encrypt the data
+	SurveyID     string
+	Aggregate    kyber.Point // the joint aggregate key to encrypt the data
+	ClientPubKey kyber.Point // the querier, charged for the released results
 
 	// query statement
 	Query libdrynx.Query // the query must be added to each node before the protocol can start
@@ -76,8 +84,35 @@ type DataCollectionProtocol struct {
 
 	// when to refuse to release results
 	Neutralizer provider.Neutralizer
+
+	// how much privacy the released results cost
+	Accountant provider.Accountant
+
+	// set at a data provider which refused the survey, to tell the root instead of sending data
+	Refusal error
+
+	// version of the data provided, at the data provider
+	DatasetVersion string
+	// versions used by each data provider, at the root
+	DatasetVersions map[string]string
+	// called at the root on each data provider response, if set
+	OnResponse func(*network.ServerIdentity)
+
+	// at the root, how long to wait for the data providers, zero to wait for all of them
+	Timeout time.Duration
+	// at the root, how many data providers must have answered when the timeout expires
+	Quorum int
+	// data providers which answered, at the root
+	Contributors []string
+	// set at the root before sending on FeedbackChannel if the data collection failed
+	Err error
+	// closed when the survey is cancelled, if set
+	Cancelled <-chan struct{}
 }
 
+// ErrCancelled is returned when the survey was cancelled.
+var ErrCancelled = errors.New("survey cancelled")
+
 // NewDataCollectionProtocol constructs a DataCollection protocol instance
 func NewDataCollectionProtocol(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
 	p := &DataCollectionProtocol{
@@ -104,7 +139,7 @@ func (p *DataCollectionProtocol) Start() error {
 		// the root node sends an announcement message to all the nodes
 		if !node.IsRoot() {
 			if err := p.SendTo(node, &AnnouncementDCMessage{}); err != nil {
-				log.Fatal(err)
+				return err
 			}
 		}
 	}
@@ -117,38 +152,63 @@ func (p *DataCollectionProtocol) Dispatch() error {
 
 	// 1. If not root -> wait for announcement message from root
 	if !p.IsRoot() {
+		if p.Refusal != nil {
+			log.Lvl1("["+p.Name()+"]", "survey", p.Survey.SurveyID, "refused:", p.Refusal)
+			return p.SendTo(p.Root(), &DataCollectionMessage{Refusal: p.Refusal.Error()})
+		}
+		select {
+		case <-p.Cancelled:
+			log.Lvl1("["+p.Name()+"]", "survey", p.Survey.SurveyID, "cancelled, not answering")
+			return nil
+		default:
+		}
+
 		response := p.GenerateData()
-		dcm := DataCollectionMessage{DCMdata: response}
+		dcm := DataCollectionMessage{DCMdata: response, DatasetVersion: p.DatasetVersion}
 
 		// 2. Send data to root
 		if err := p.SendTo(p.Root(), &dcm); err != nil {
 			return err
 		}
 	} else {
-		// 3. If root wait for all other nodes to send their data
-		dcmAggregate := make(map[string]libunlynx.CipherVector, 0)
-		for i := 0; i < len(p.Tree().List())-1; i++ {
-			dcm := <-p.DataCollectionChannel
-			dcmData := dcm.DCMdata
-
-			// received map with bytes -> go back to map with CipherVector
-			dcmDecoded := make(map[string]libunlynx.CipherVector, len(dcmData.Data))
-			for i, v := range dcmData.Data {
-				cv := libunlynx.NewCipherVector(dcmData.Len)
-				cv.FromBytes(v, dcmData.Len)
-				dcmDecoded[i] = *cv
-			}
+		// 3. If root wait for all other nodes to send their data, or for the timeout
+		var timeout <-chan time.Time
+		if p.Timeout > 0 {
+			timer := time.NewTimer(p.Timeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
 
-			// aggregate values that belong to the same group (that are originated from different data providers)
-			for key, value := range dcmDecoded {
-				// if already in the map -> add to what is inside
-				if cv, ok := dcmAggregate[key]; ok {
-					newCV := libunlynx.NewCipherVector(len(cv))
-					newCV.Add(cv, value)
-					dcmAggregate[key] = *newCV
-				} else { // otherwise create a new entry
-					dcmAggregate[key] = value
+		dcmAggregate := make(map[string]libunlynx.CipherVector, 0)
+		p.DatasetVersions = make(map[string]string)
+		p.Contributors = make([]string, 0)
+		nbrDPs := len(p.Tree().List()) - 1
+	collect:
+		for len(p.Contributors) < nbrDPs {
+			select {
+			case dcm := <-p.DataCollectionChannel:
+				if dcm.Refusal != "" {
+					p.Err = fmt.Errorf("data provider %v refused the survey: %v", dcm.ServerIdentity, dcm.Refusal)
+					break collect
+				}
+				p.Contributors = append(p.Contributors, dcm.ServerIdentity.String())
+				if dcm.DatasetVersion != "" {
+					p.DatasetVersions[dcm.ServerIdentity.String()] = dcm.DatasetVersion
+				}
+				if p.OnResponse != nil {
+					p.OnResponse(dcm.ServerIdentity)
 				}
+				aggregateResponse(dcmAggregate, dcm.DCMdata)
+			case <-p.Cancelled:
+				p.Err = ErrCancelled
+				break collect
+			case <-timeout:
+				if len(p.Contributors) < p.Quorum {
+					p.Err = fmt.Errorf("only %v of %v data providers answered in %v, %v needed", len(p.Contributors), nbrDPs, p.Timeout, p.Quorum)
+				} else {
+					log.Warn("["+p.Name()+"]", "only", len(p.Contributors), "of", nbrDPs, "data providers answered in", p.Timeout, ", proceeding without the others")
+				}
+				break collect
 			}
 		}
 		p.FeedbackChannel <- dcmAggregate
@@ -156,13 +216,40 @@ func (p *DataCollectionProtocol) Dispatch() error {
 	return nil
 }
 
+// aggregateResponse adds a data provider's response to the responses aggregated so far.
+func aggregateResponse(dcmAggregate map[string]libunlynx.CipherVector, dcmData libdrynx.ResponseDPBytes) {
+	// received map with bytes -> go back to map with CipherVector
+	dcmDecoded := make(map[string]libunlynx.CipherVector, len(dcmData.Data))
+	for i, v := range dcmData.Data {
+		cv := libunlynx.NewCipherVector(dcmData.Len)
+		cv.FromBytes(v, dcmData.Len)
+		dcmDecoded[i] = *cv
+	}
+
+	// aggregate values that belong to the same group (that are originated from different data providers)
+	for key, value := range dcmDecoded {
+		// if already in the map -> add to what is inside
+		if cv, ok := dcmAggregate[key]; ok {
+			newCV := libunlynx.NewCipherVector(len(cv))
+			newCV.Add(cv, value)
+			dcmAggregate[key] = *newCV
+		} else { // otherwise create a new entry
+			dcmAggregate[key] = value
+		}
+	}
+}
+
 // Support Functions
 //______________________________________________________________________________________________________________________
 
 func generateNeutralResponse(survey SurveyToDP, groupsStrings []string) libdrynx.ResponseDPBytes {
-	encrypted := make(libunlynx.CipherVector, survey.Query.Operation.NbrOutput)
-	for i := range encrypted {
-		encrypted[i] = *libunlynx.EncryptInt(survey.Aggregate, 0)
+	queries, err := survey.Query.SubQueries()
+	if err != nil {
+		queries = []libdrynx.Query{survey.Query}
+	}
+	var encrypted libunlynx.CipherVector
+	for _, query := range queries {
+		encrypted = append(encrypted, neutralResponse(survey.Aggregate, query)...)
 	}
 	raw, _, _ := encrypted.ToBytes()
 
@@ -174,6 +261,172 @@ func generateNeutralResponse(survey SurveyToDP, groupsStrings []string) libdrynx
 	return libdrynx.ResponseDPBytes{Data: grouped, Len: len(groupsStrings)}
 }
 
+// neutralResponse is the encrypted response releasing nothing for a query which isn't a batch.
+func neutralResponse(key kyber.Point, query libdrynx.Query) libunlynx.CipherVector {
+	encrypted := make(libunlynx.CipherVector, query.Operation.NbrOutput)
+	for i := range encrypted {
+		encrypted[i] = *libunlynx.EncryptInt(key, 0)
+	}
+	// perturbed as any other response, so that the querier can debias the aggregation
+	if libdrynx.AddLocalDiffP(query.LocalDiffP) {
+		if perturbed, _, err := perturbLocally(key, query, make([]int64, len(encrypted))); err == nil {
+			encrypted = perturbed
+		}
+	}
+	return encrypted
+}
+
+// perturbLocally applies the local differential privacy asked by the query on the clear response and encrypts it.
+func perturbLocally(key kyber.Point, query libdrynx.Query, clear []int64) (libunlynx.CipherVector, []int64, error) {
+	perturbed, err := libdrynxencoding.PerturbLocally(clear, query.Operation.NameOp, query.LocalDiffP)
+	if err != nil {
+		return nil, nil, err
+	}
+	return *libunlynx.EncryptIntVector(key, perturbed), perturbed, nil
+}
+
+// release returns the provided data which can be released for a query which isn't a batch, once suppressed and
+// vetted, with the identities of its rows if any, or false if the response has to be neutralized. It is still to be
+// charged on the privacy budget.
+func (p *DataCollectionProtocol) release(query libdrynx.Query, providedData [][]float64, identities []float64) ([][]float64, []float64, bool) {
+	// hide identifying rows, keeping their identities along
+	if s, ok := p.Neutralizer.(provider.Suppressor); ok {
+		if identities == nil {
+			providedData = s.Suppress(query, providedData)
+		} else {
+			withIdentities := append(providedData[:len(providedData):len(providedData)], identities)
+			suppressed := s.Suppress(query, withIdentities)
+			providedData, identities = suppressed[:len(suppressed)-1], suppressed[len(suppressed)-1]
+		}
+	}
+
+	// vet results
+	if n := p.Neutralizer; n != nil {
+		var err error
+		if i, ok := n.(provider.Identifier); ok && identities != nil {
+			err = i.VetIdentified(query, providedData, identities)
+		} else {
+			err = n.Vet(query, providedData)
+		}
+		if err != nil {
+			log.Warnf("results neutralized: %v", err)
+			metrics.NeutralizedResponses.Inc(query.Dataset)
+			return nil, nil, false
+		}
+	}
+
+	return providedData, identities, true
+}
+
+// charge charges the queries to release on the privacy budget, all at once as a batch so that none is charged if
+// the budget is exceeded, returning false if the response has to be neutralized.
+func (p *DataCollectionProtocol) charge(queries []libdrynx.Query, releasable []bool) bool {
+	a := p.Accountant
+	if a == nil {
+		return true
+	}
+
+	var operations []libdrynx.Operation
+	var charged libdrynx.Query
+	for i, query := range queries {
+		if releasable[i] {
+			operations = append(operations, query.Operation)
+			charged = query
+		}
+	}
+	switch len(operations) {
+	case 0:
+		return true
+	case 1:
+	default:
+		batch, err := libdrynx.NewBatchOperation(operations)
+		if err != nil {
+			log.Warnf("results neutralized: %v", err)
+			return false
+		}
+		// only the operations matter to the budget
+		charged.Operation, charged.Batch = batch, operations
+		charged.Ranges, charged.IVSigs = nil, libdrynx.QueryIVSigs{}
+	}
+
+	querier := ""
+	if p.Survey.ClientPubKey != nil {
+		querier = p.Survey.ClientPubKey.String()
+	}
+	if err := a.Spend(p.Survey.SurveyID, querier, charged); err != nil {
+		log.Warnf("results neutralized: %v", err)
+		metrics.NeutralizedResponses.Inc(charged.Dataset)
+		return false
+	}
+	return true
+}
+
+// encode encodes and encrypts the released data for a query which isn't a batch, returning the encrypted and clear
+// responses with what is needed to create their range proofs.
+func (p *DataCollectionProtocol) encode(query libdrynx.Query, data [][]float64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof, error) {
+	// read the signatures needed to compute the range proofs
+	signatures := make([][]libdrynx.PublishSignature, len(query.IVSigs.InputValidationSigs))
+	for i, row := range query.IVSigs.InputValidationSigs {
+		signatures[i] = make([]libdrynx.PublishSignature, len((*row).Content))
+		for j, v := range (*row).Content {
+			signatures[i][j] = libdrynxrange.PublishSignatureBytesToPublishSignatures(v)
+		}
+	}
+
+	var clearResponse []int64
+	var encryptedResponse []libunlynx.CipherText
+	var cprf []libdrynxrange.CreateProof
+
+	if query.Operation.NameOp == "logistic regression" {
+		lrParameters := query.Operation.LRParameters
+		// the selected columns are the features, followed by the label
+		if len(data) != int(lrParameters.NbrFeatures)+1 {
+			return nil, nil, nil, fmt.Errorf("logistic regression needs %v features and a label, got %v columns", lrParameters.NbrFeatures, len(data))
+		}
+		labels := data[len(data)-1]
+
+		xFloat := make([][]float64, len(labels))
+		yInt := make([]int64, len(labels))
+		for i, label := range labels {
+			xFloat[i] = make([]float64, lrParameters.NbrFeatures)
+			for j := range xFloat[i] {
+				xFloat[i][j] = data[j][i]
+			}
+			yInt[i] = int64(label)
+		}
+
+		// set the number of records to the number of records owned by this data provider
+		lrParameters.NbrRecords = int64(len(labels))
+
+		//p.Survey.Query.Ranges = nil
+		encryptedResponse, clearResponse, cprf = libdrynxencoding.EncodeForFloat(xFloat, yInt, lrParameters, p.Survey.Aggregate, signatures, query.Ranges, query.Operation.NameOp)
+	} else {
+		ints := make([][]int64, len(data))
+		for i, l := range data {
+			arr := make([]int64, len(l))
+			for j, v := range l {
+				arr[j] = int64(v)
+			}
+			ints[i] = arr
+		}
+		encryptedResponse, clearResponse, cprf = libdrynxencoding.Encode(ints, p.Survey.Aggregate, signatures, query.Ranges, query.Operation)
+	}
+
+	if libdrynx.AddLocalDiffP(query.LocalDiffP) {
+		// the range proofs are over the values before their perturbation
+		if query.Proofs != 0 {
+			return nil, nil, nil, errors.New("local differential privacy cannot be proven")
+		}
+		perturbed, perturbedClear, err := perturbLocally(p.Survey.Aggregate, query, clearResponse)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to perturb locally: %v", err)
+		}
+		encryptedResponse, clearResponse = perturbed, perturbedClear
+	}
+
+	return encryptedResponse, clearResponse, cprf, nil
+}
+
 // GenerateData is used to generate data at DPs, this is more for simulation's purposes
 func (p *DataCollectionProtocol) GenerateData() libdrynx.ResponseDPBytes {
 	// Prepare the generation of all possible groups with the query information.
@@ -189,61 +442,57 @@ func (p *DataCollectionProtocol) GenerateData() libdrynx.ResponseDPBytes {
 		groupsString[i] = fmt.Sprint(v)
 	}
 	mutexGroups.Unlock()
-	// read the signatures needed to compute the range proofs
-	signatures := make([][]libdrynx.PublishSignature, len(p.Survey.Query.IVSigs.InputValidationSigs))
-	for i, row := range p.Survey.Query.IVSigs.InputValidationSigs {
-		signatures[i] = make([]libdrynx.PublishSignature, len((*row).Content))
-		for j, v := range (*row).Content {
-			signatures[i][j] = libdrynxrange.PublishSignatureBytesToPublishSignatures(v)
-		}
-	}
 
-	// load wanted data
-	providedData, err := p.Loader.Provide(p.Survey.Query)
+	// load wanted data, along with the identities of the rows if the neutralizer tells them apart
+	provided := p.Survey.Query
+	identity := libdrynx.ColumnID("")
+	if i, ok := p.Neutralizer.(provider.Identifier); ok {
+		identity = i.IdentityColumn()
+	}
+	if identity != "" {
+		provided.Selector = append(provided.Selector[:len(provided.Selector):len(provided.Selector)], identity)
+		provided.Operation.NbrInput++
+	}
+	var providedData [][]float64
+	var err error
+	if v, ok := p.Loader.(provider.Versioned); ok {
+		providedData, p.DatasetVersion, err = v.ProvideWithVersion(provided)
+	} else {
+		providedData, err = p.Loader.Provide(provided)
+	}
 	if err != nil {
 		log.Errorf("unable to provide using loader: %v", err)
 		return generateNeutralResponse(p.Survey, groupsString)
 	}
+	var identities []float64
+	if identity != "" {
+		if len(providedData) == 0 {
+			log.Errorf("loader provided no identities")
+			return generateNeutralResponse(p.Survey, groupsString)
+		}
+		providedData, identities = providedData[:len(providedData)-1], providedData[len(providedData)-1]
+	}
 
-	// vet results
-	if n := p.Neutralizer; n != nil && !n.Vet(p.Survey.Query, providedData) {
-		log.Warn("results neutralized")
+	// the operations of a batch are run over the same data, each released on its own
+	queries, err := p.Survey.Query.SubQueries()
+	if err != nil {
+		log.Errorf("unable to split the batch: %v", err)
 		return generateNeutralResponse(p.Survey, groupsString)
 	}
-
-	// logistic regression specific
-	var xFloat [][]float64
-	var yInt []int64
-	lrParameters := p.Survey.Query.Operation.LRParameters
-	if p.Survey.Query.Operation.NameOp == "logistic regression" {
-		if lrParameters.FilePath != "" {
-			// note: GetDataForDataProvider(...) business only for testing purpose
-			dataProviderID := p.TreeNode().ServerIdentity
-			xFloat, yInt = libdrynxencoding.GetDataForDataProvider(p.Survey.Query.Operation.LRParameters.DatasetName, p.Survey.Query.Operation.LRParameters.FilePath, *dataProviderID)
-
-			// set the number of records to the number of records owned by this data provider
-			//dataSpecifics = recq.Query.Operation.DataSpecifics
-			lrParameters.NbrRecords = int64(len(xFloat))
-		} else {
-			// create dummy data
-			xFloat = make([][]float64, lrParameters.NbrRecords)
-			yInt = make([]int64, lrParameters.NbrRecords)
-			limit := 4
-
-			m := int(lrParameters.NbrFeatures)
-			for i := 0; i < int(lrParameters.NbrRecords); i++ {
-				xFloat[i] = make([]float64, m)
-				yInt[i] = int64(rand.Intn(2)) // sample 0 or 1 randomly for the label
-				for j := 1; j < m; j++ {
-					r := rand.Intn(limit)
-					xFloat[i][j] = float64(r)
-				}
-			}
+	released := make([][][]float64, len(queries))
+	releasedIdentities := make([][]float64, len(queries))
+	releasable := make([]bool, len(queries))
+	for i, query := range queries {
+		released[i], releasedIdentities[i], releasable[i] = p.release(query, providedData, identities)
+		// the proofs are over the whole response, which has to be neutralized as a whole
+		if !releasable[i] && (query.Proofs != 0 || len(queries) == 1) {
+			return generateNeutralResponse(p.Survey, groupsString)
 		}
 	}
 
 	// ------- START: ENCODING & ENCRYPTION -------
 	encodeTime := libunlynx.StartTimer(p.Name() + "_DPencoding")
+	endEncoding := metrics.Phase("dp_encoding")
 	cprf := make([]libdrynxrange.CreateProof, 0)
 
 	// compute response
@@ -256,26 +505,44 @@ func (p *DataCollectionProtocol) GenerateData() libdrynx.ResponseDPBytes {
 
 		if p.Survey.Query.CuttingFactor != 0 {
 			p.Survey.Query.Operation.NbrOutput = int(p.Survey.Query.Operation.NbrOutput / p.Survey.Query.CuttingFactor)
+			queries[0].Operation.NbrOutput = p.Survey.Query.Operation.NbrOutput
 		}
-		if p.Survey.Query.Operation.NameOp == "logistic regression" {
-			//p.Survey.Query.Ranges = nil
-			encryptedResponse, clearResponse, cprf = libdrynxencoding.EncodeForFloat(xFloat, yInt, lrParameters, p.Survey.Aggregate, signatures, p.Survey.Query.Ranges, p.Survey.Query.Operation.NameOp)
-		} else {
-			ints := make([][]int64, len(providedData))
-			for i, l := range providedData {
-				arr := make([]int64, len(l))
-				for j, v := range l {
-					arr[j] = int64(v)
-				}
-				ints[i] = arr
+		for i, query := range queries {
+			if !releasable[i] {
+				encryptedResponse = append(encryptedResponse, neutralResponse(p.Survey.Aggregate, query)...)
+				continue
+			}
+
+			encrypted, clearPart, proofs, err := p.encode(query, released[i])
+			if err != nil {
+				log.Errorf("%v", err)
+				return generateNeutralResponse(p.Survey, groupsString)
 			}
-			encryptedResponse, clearResponse, cprf = libdrynxencoding.Encode(ints, p.Survey.Aggregate, signatures, p.Survey.Query.Ranges, p.Survey.Query.Operation)
+			encryptedResponse = append(encryptedResponse, encrypted...)
+			clearResponse = append(clearResponse, clearPart...)
+			cprf = append(cprf, proofs...)
 		}
 
 		log.Lvl2("Data Provider", p.Name(), "computes the query response", clearResponse, "for groups:", groupsString, "with operation:", p.Survey.Query.Operation)
 
 		queryResponse[v] = libunlynx.CipherVector(encryptedResponse)
+	}
+
+	// charged once the whole response is ready to be sent
+	if !p.charge(queries, releasable) {
+		libunlynx.EndTimer(encodeTime)
+		endEncoding()
+		return generateNeutralResponse(p.Survey, groupsString)
+	}
+	if i, ok := p.Neutralizer.(provider.Identifier); ok && identities != nil {
+		for j, query := range queries {
+			if releasable[j] {
+				i.Record(query, releasedIdentities[j])
+			}
+		}
+	}
 
+	for _, v := range groupsString {
 		// scaling for simulation purposes
 		qr := queryResponse[v]
 		for i := 0; i < p.Survey.Query.CuttingFactor-1; i++ {
@@ -284,6 +551,7 @@ func (p *DataCollectionProtocol) GenerateData() libdrynx.ResponseDPBytes {
 		if p.Survey.Query.Proofs != 0 {
 			go func() {
 				startAllProofs := libunlynx.StartTimer(p.Name() + "_AllProofs")
+				endProofsCreation := metrics.Phase("proofs_creation")
 				rpl := libdrynxrange.RangeProofList{}
 
 				//rangeProofCreation := libunlynx.StartTimer(p.Name() + "_RangeProofCreation")
@@ -336,24 +604,18 @@ func (p *DataCollectionProtocol) GenerateData() libdrynx.ResponseDPBytes {
 				pi.(*ProofCollectionProtocol).Proof = drynxproof.ProofRequest{RangeProof: drynxproof.NewRangeProofRequest(&rpl, p.Survey.SurveyID, p.ServerIdentity().String(), "", p.Survey.Query.RosterVNs, p.Private(), nil)}
 				//libunlynx.EndTimer(rangeProofCreation)
 
-				go func() {
-					if err := pi.Dispatch(); err != nil {
-						log.Fatal(err)
-					}
-				}()
-				go func() {
-					if err := pi.Start(); err != nil {
-						log.Fatal(err)
-					}
-				}()
-				<-pi.(*ProofCollectionProtocol).FeedbackChannel
+				if err := RunProofCollection(pi); err != nil {
+					log.Error("["+p.Name()+"]", "unable to send the range proofs of survey", p.Survey.SurveyID, ":", err)
+				}
 
 				libunlynx.EndTimer(startAllProofs)
+				endProofsCreation()
 
 			}()
 		}
 	}
 	libunlynx.EndTimer(encodeTime)
+	endEncoding()
 	// ------- END -------
 
 	//convert the response to bytes
@@ -27,7 +27,11 @@ func createTestQuery(aggregate kyber.Point, operationName string, proofs int, di
 	queryStatement.SurveyID = "query_test"
 	queryStatement.Aggregate = aggregate
 
-	query.Operation = libdrynx.ChooseOperation(operationName, int(randomRange[0]), int(randomRange[1]), dimensions, cuttingFactor)
+	operation, err := libdrynx.ChooseOperation(operationName, int(randomRange[0]), int(randomRange[1]), dimensions, cuttingFactor)
+	if err != nil {
+		return queryStatement, err
+	}
+	query.Operation = operation
 	query.Proofs = proofs
 	selector := [2]libdrynx.ColumnID{}
 	query.Selector = selector[:]
@@ -111,3 +115,58 @@ func NewDataCollectionTest(tni *onet.TreeNodeInstance) (onet.ProtocolInstance, e
 	dcp.Survey = query
 	return dcp, nil
 }
+
+// TestDataCollectionProtocolDropout tests that the root gives up on data providers not answering in time
+func TestDataCollectionProtocolDropout(t *testing.T) {
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+
+	if _, err := onet.GlobalProtocolRegister("DataCollectionDropoutTest", NewDataCollectionDropoutTest); err != nil {
+		log.Fatal("Failed to register the <DataCollectionDropoutTest> protocol:", err)
+	}
+	_, _, tree := local.GenTree(3, true)
+
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	var err error
+	query, err = createTestQuery(keys.Public, "sum", 0, 5, 0)
+	assert.Nil(t, err, "Error when generating test query")
+
+	rootInstance, err := local.CreateProtocol("DataCollectionDropoutTest", tree)
+	if err != nil {
+		t.Fatal("Couldn't start protocol:", err)
+	}
+	protocol := rootInstance.(*protocols.DataCollectionProtocol)
+
+	go func() {
+		if err := protocol.Start(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	select {
+	case <-protocol.FeedbackChannel:
+		assert.Error(t, protocol.Err)
+		assert.Empty(t, protocol.Contributors)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Didn't finish in time")
+	}
+}
+
+// NewDataCollectionDropoutTest is a test specific protocol instance constructor whose data providers never answer.
+func NewDataCollectionDropoutTest(tni *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	pi, err := NewDataCollectionTest(tni)
+	if err != nil {
+		return nil, err
+	}
+
+	dcp := pi.(*protocols.DataCollectionProtocol)
+	if tni.IsRoot() {
+		dcp.Timeout = 500 * time.Millisecond
+		dcp.Quorum = 1
+	} else {
+		cancelled := make(chan struct{})
+		close(cancelled)
+		dcp.Cancelled = cancelled
+	}
+	return dcp, nil
+}
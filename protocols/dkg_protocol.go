@@ -0,0 +1,277 @@
+// The distributed key generation protocol permits the computing nodes to generate a collective key, the secret of
+// which is shared among them, using kyber's Pedersen DKG.
+// The root announces the threshold to the other nodes. Each node sends a deal to every other one, broadcasts its
+// response to each deal it received and processes all the responses. Each node then reports the collective key to
+// the root, which checks that all of them agree.
+
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ldsec/drynx/lib"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share/dkg/pedersen"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// DKGProtocolName is the registered name for the distributed key generation protocol.
+const DKGProtocolName = "DKG"
+
+// DefaultDKGTimeout is how long a node waits for the messages of the others.
+const DefaultDKGTimeout = time.Minute
+
+func init() {
+	network.RegisterMessage(DKGStartMessage{})
+	network.RegisterMessage(DKGDealMessage{})
+	network.RegisterMessage(DKGResponseMessage{})
+	network.RegisterMessage(DKGDoneMessage{})
+	if _, err := onet.GlobalProtocolRegister(DKGProtocolName, NewDKGProtocol); err != nil {
+		log.Fatal("Error registering <DKGProtocol>:", err)
+	}
+}
+
+// Messages
+//______________________________________________________________________________________________________________________
+
+// DKGStartMessage is sent by the root to start the key generation.
+type DKGStartMessage struct {
+	Threshold int
+}
+
+// DKGDealMessage contains the deal of a node for another one.
+type DKGDealMessage struct {
+	Deal *dkg.Deal
+}
+
+// DKGResponseMessage contains the response of a node to a deal, broadcast to all the nodes.
+type DKGResponseMessage struct {
+	Response *dkg.Response
+}
+
+// DKGDoneMessage reports the collective key computed by a node to the root.
+type DKGDoneMessage struct {
+	Key kyber.Point
+	// set if the node failed to compute it
+	Error string
+}
+
+// Protocol
+//______________________________________________________________________________________________________________________
+
+// DKGProtocol generates a collective key, with a share of its secret at each node of the tree.
+type DKGProtocol struct {
+	*onet.TreeNodeInstance
+
+	// Protocol feedback channel, at the root
+	FeedbackChannel chan libdrynx.KeyShare
+
+	// Protocol communication channels
+	StartChannel chan struct {
+		*onet.TreeNode
+		DKGStartMessage
+	}
+	DealChannel chan struct {
+		*onet.TreeNode
+		DKGDealMessage
+	}
+	ResponseChannel chan struct {
+		*onet.TreeNode
+		DKGResponseMessage
+	}
+	DoneChannel chan struct {
+		*onet.TreeNode
+		DKGDoneMessage
+	}
+
+	// number of nodes needed to use the key, set at the root
+	Threshold int
+	// how long to wait for the messages of the other nodes
+	Timeout time.Duration
+	// called at each node with its share of the key, if set
+	OnShare func(libdrynx.KeyShare) error
+	// set at the root before sending on FeedbackChannel if the key generation failed
+	Err error
+
+	// signaled when the root is started
+	started chan struct{}
+}
+
+// NewDKGProtocol constructs a DKG protocol instance
+func NewDKGProtocol(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	p := &DKGProtocol{
+		TreeNodeInstance: n,
+		FeedbackChannel:  make(chan libdrynx.KeyShare, 1),
+		Timeout:          DefaultDKGTimeout,
+		started:          make(chan struct{}, 1),
+	}
+
+	if err := p.RegisterChannel(&p.StartChannel); err != nil {
+		return nil, fmt.Errorf("couldn't register start channel: %v", err)
+	}
+	nbrNodes := len(n.Tree().List())
+	if err := p.RegisterChannelLength(&p.DealChannel, nbrNodes); err != nil {
+		return nil, fmt.Errorf("couldn't register deal channel: %v", err)
+	}
+	// each node receives the responses of all the others to all the deals
+	if err := p.RegisterChannelLength(&p.ResponseChannel, nbrNodes*nbrNodes); err != nil {
+		return nil, fmt.Errorf("couldn't register response channel: %v", err)
+	}
+	if err := p.RegisterChannelLength(&p.DoneChannel, nbrNodes); err != nil {
+		return nil, fmt.Errorf("couldn't register done channel: %v", err)
+	}
+
+	return p, nil
+}
+
+// Start is called at the root node and starts the execution of the protocol.
+func (p *DKGProtocol) Start() error {
+	log.Lvl2("["+p.Name()+"]", "starts a DKG Protocol with a threshold of", p.Threshold)
+
+	nbrNodes := len(p.Tree().List())
+	if p.Threshold < 1 || p.Threshold > nbrNodes {
+		return fmt.Errorf("threshold of %v for %v nodes", p.Threshold, nbrNodes)
+	}
+	if err := p.Broadcast(&DKGStartMessage{Threshold: p.Threshold}); err != nil {
+		return err
+	}
+	p.started <- struct{}{}
+	return nil
+}
+
+// Dispatch is called on each tree node. It waits for incoming messages and handles them.
+func (p *DKGProtocol) Dispatch() error {
+	defer p.Done()
+
+	timer := time.NewTimer(p.Timeout)
+	defer timer.Stop()
+
+	// 1. Wait for the root to start, with the threshold
+	if p.IsRoot() {
+		select {
+		case <-p.started:
+		case <-timer.C:
+			p.Err = errors.New("DKG not started in time")
+			p.FeedbackChannel <- libdrynx.KeyShare{}
+			return nil
+		}
+	} else {
+		select {
+		case start := <-p.StartChannel:
+			p.Threshold = start.Threshold
+		case <-timer.C:
+			return errors.New("DKG not started in time")
+		}
+	}
+
+	// 2. Generate the key with the other nodes
+	share, err := p.generate(timer.C)
+	if err == nil && p.OnShare != nil {
+		err = p.OnShare(share)
+	}
+
+	// 3. Report the key to the root
+	if !p.IsRoot() {
+		done := DKGDoneMessage{Key: share.Key}
+		if err != nil {
+			done.Error = err.Error()
+		}
+		return p.SendToParent(&done)
+	}
+
+	for i := 0; err == nil && i < len(p.Tree().List())-1; i++ {
+		select {
+		case done := <-p.DoneChannel:
+			switch {
+			case done.Error != "":
+				err = fmt.Errorf("node %v failed: %v", done.ServerIdentity, done.Error)
+			case !done.Key.Equal(share.Key):
+				err = fmt.Errorf("node %v computed another key", done.ServerIdentity)
+			}
+		case <-timer.C:
+			err = fmt.Errorf("only %v of %v nodes computed the key in time", i, len(p.Tree().List())-1)
+		}
+	}
+	p.Err = err
+	p.FeedbackChannel <- share
+	return nil
+}
+
+// generate runs the Pedersen DKG with the other nodes, returning the share of the node.
+func (p *DKGProtocol) generate(timeout <-chan time.Time) (libdrynx.KeyShare, error) {
+	nodes := p.Tree().List()
+	publics := make([]kyber.Point, len(nodes))
+	for i, node := range nodes {
+		publics[i] = node.ServerIdentity.Public
+	}
+
+	generator, err := dkg.NewDistKeyGenerator(libdrynx.Suite, p.Private(), publics, p.Threshold)
+	if err != nil {
+		return libdrynx.KeyShare{}, err
+	}
+
+	deals, err := generator.Deals()
+	if err != nil {
+		return libdrynx.KeyShare{}, err
+	}
+	for i, deal := range deals {
+		if err := p.SendTo(nodes[i], &DKGDealMessage{Deal: deal}); err != nil {
+			return libdrynx.KeyShare{}, err
+		}
+	}
+
+	// respond to the deal of each other node
+	for i := 0; i < len(nodes)-1; i++ {
+		select {
+		case deal := <-p.DealChannel:
+			response, err := generator.ProcessDeal(deal.Deal)
+			if err != nil {
+				return libdrynx.KeyShare{}, fmt.Errorf("deal of %v: %v", deal.ServerIdentity, err)
+			}
+			for _, node := range nodes {
+				if !node.ID.Equal(p.TreeNode().ID) {
+					if err := p.SendTo(node, &DKGResponseMessage{Response: response}); err != nil {
+						return libdrynx.KeyShare{}, err
+					}
+				}
+			}
+		case <-timeout:
+			return libdrynx.KeyShare{}, fmt.Errorf("only %v of %v deals received in time", i, len(nodes)-1)
+		}
+	}
+
+	// each other node responds to the deals of all the nodes but itself
+	for i := 0; i < (len(nodes)-1)*(len(nodes)-1); i++ {
+		select {
+		case response := <-p.ResponseChannel:
+			if justification, err := generator.ProcessResponse(response.Response); err != nil {
+				return libdrynx.KeyShare{}, fmt.Errorf("response of %v: %v", response.ServerIdentity, err)
+			} else if justification != nil {
+				return libdrynx.KeyShare{}, fmt.Errorf("deal complained about by %v", response.ServerIdentity)
+			}
+		case <-timeout:
+			return libdrynx.KeyShare{}, errors.New("not all responses received in time")
+		}
+	}
+
+	if !generator.Certified() {
+		return libdrynx.KeyShare{}, errors.New("key generation not certified")
+	}
+	distKeyShare, err := generator.DistKeyShare()
+	if err != nil {
+		return libdrynx.KeyShare{}, err
+	}
+
+	log.Lvl2("["+p.Name()+"]", p.ServerIdentity(), "holds share", distKeyShare.Share.I, "of collective key", distKeyShare.Public())
+	return libdrynx.KeyShare{
+		Key:       distKeyShare.Public(),
+		Threshold: p.Threshold,
+		Index:     distKeyShare.Share.I,
+		Secret:    distKeyShare.Share.V,
+		Holders:   publics,
+	}, nil
+}
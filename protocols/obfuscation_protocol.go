@@ -142,18 +142,15 @@ func NewObfuscationProtocol(n *onet.TreeNodeInstance) (onet.ProtocolInstance, er
 
 	err := pop.RegisterChannel(&pop.DataReferenceChannel)
 	if err != nil {
-		log.Fatal(err)
 		return nil, errors.New("couldn't register data reference channel: " + err.Error())
 	}
 
 	err = pop.RegisterChannel(&pop.ChildDataChannel)
 	if err != nil {
-		log.Fatal(err)
 		return nil, errors.New("couldn't register child-data channel: " + err.Error())
 	}
 
 	if err := pop.RegisterChannel(&pop.LengthNodeChannel); err != nil {
-		log.Fatal(err)
 		return nil, errors.New("couldn't register data reference channel: " + err.Error())
 	}
 
@@ -260,17 +257,9 @@ func (p *ObfuscationProtocol) ascendingObfuscationPhase() (libunlynx.CipherVecto
 			pi := p.MapPIs["obfuscation/"+p.ServerIdentity().String()]
 			pi.(*ProofCollectionProtocol).Proof = drynxproof.ProofRequest{ObfuscationProof: drynxproof.NewObfuscationProofRequest(&proof, p.Query.SurveyID, p.ServerIdentity().String(), "", p.Query.Query.RosterVNs, p.Private(), nil)}
 
-			go func() {
-				if err := pi.Dispatch(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			go func() {
-				if err := pi.Start(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			<-pi.(*ProofCollectionProtocol).FeedbackChannel
+			if err := RunProofCollection(pi); err != nil {
+				log.Error("["+p.Name()+"]", "unable to send the obfuscation proof of survey", p.Query.SurveyID, ":", err)
+			}
 		}()
 	}
 
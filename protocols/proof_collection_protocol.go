@@ -2,6 +2,8 @@ package protocols
 
 import (
 	"errors"
+	"fmt"
+	"github.com/ldsec/drynx/lib/metrics"
 	"github.com/ldsec/drynx/lib/proof"
 	"sync"
 
@@ -115,14 +117,39 @@ type ProofCollectionProtocol struct {
 }
 
 // CastToQueryInfo get in the concurrent map the queryInfo
-func CastToQueryInfo(object interface{}, err error) *libdrynx.QueryInfo {
+func CastToQueryInfo(object interface{}, err error) (*libdrynx.QueryInfo, error) {
 	if err != nil {
-		log.Fatal("Error reading map")
+		return nil, fmt.Errorf("error reading map: %v", err)
 	}
-	if object == nil {
+	qi, ok := object.(*libdrynx.QueryInfo)
+	if !ok || qi == nil {
+		return nil, errors.New("no proofs expected for this survey")
+	}
+	return qi, nil
+}
+
+// RunProofCollection sends the proof from the root of the protocol and waits for the verifying nodes to process it.
+func RunProofCollection(pi onet.ProtocolInstance) error {
+	pcp := pi.(*ProofCollectionProtocol)
+
+	errs := make(chan error, 2)
+	go func() {
+		if err := pcp.Dispatch(); err != nil {
+			errs <- err
+		}
+	}()
+	go func() {
+		if err := pcp.Start(); err != nil {
+			errs <- err
+		}
+	}()
+
+	select {
+	case <-pcp.FeedbackChannel:
 		return nil
+	case err := <-errs:
+		return err
 	}
-	return object.(*libdrynx.QueryInfo)
 }
 
 // NewProofCollectionProtocol constructs a ProofCollection protocol instance.
@@ -164,14 +191,14 @@ func (p *ProofCollectionProtocol) Start() error {
 	} else if p.Proof.KeySwitchProof != nil {
 		log.Lvl2("["+p.Name()+"]", "starts a Proof Collection Protocol: KEY SWITCH")
 	} else {
-		log.Fatal("Did not recognise the type of proof")
+		return errors.New("did not recognise the type of proof")
 	}
 
 	for _, node := range p.Tree().List() {
 		// the root node sends an announcement message to all the nodes
 		if !node.IsRoot() {
 			if err := p.SendTo(node, &AnnouncementPCMessage{Proof: p.Proof}); err != nil {
-				log.Fatal(err)
+				return err
 			}
 		}
 	}
@@ -272,11 +299,11 @@ func (p *ProofCollectionProtocol) Dispatch() error {
 				p.Proof.KeySwitchProof.SB)
 
 		} else {
-			log.Fatal("Did not recognise the type of proof")
+			return errors.New("did not recognise the type of proof")
 		}
 
 		if err != nil {
-			log.Fatal("Error when verifying the proof:", err)
+			return fmt.Errorf("error when verifying the proof: %v", err)
 		}
 
 		dcm := ProofCollectionMessage{Result: verif, SB: sb}
@@ -305,9 +332,16 @@ func (p *ProofCollectionProtocol) Dispatch() error {
 }
 
 func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, senderID, potentialDeterministicInfo string, verificationResult int64, data, signature []byte, roster *onet.Roster, sb *skipchain.SkipBlock) (*skipchain.SkipBlock, error) {
+	metrics.Proofs.Inc(typeProof, drynxproof.Outcome(verificationResult))
+
 	p.Mutex.Lock()
 
-	remainingProofs := CastToQueryInfo(p.Request.Get(surveyID)).TotalNbrProofs[index]
+	qi, err := CastToQueryInfo(p.Request.Get(surveyID))
+	if err != nil {
+		p.Mutex.Unlock()
+		return nil, err
+	}
+	remainingProofs := qi.TotalNbrProofs[index]
 	rootVN := roster.List[0].Equal(p.ServerIdentity())
 
 	if remainingProofs > 0 {
@@ -316,9 +350,9 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 		//Put in the bitmap the value of the verification
 		//Key is SurveyID + type_of_proof + senderID + addiInfo + serverID
 		nameOfProof := surveyID + "/" + typeProof + "/" + senderID + "/" + potentialDeterministicInfo + "/" + p.ServerIdentity().Address.String()
-		qi := CastToQueryInfo(p.Request.Get(string(surveyID)))
 		qi.Bitmap[nameOfProof] = verificationResult
 		if _, err := p.Request.Replace(surveyID, qi); err != nil {
+			p.Mutex.Unlock()
 			return nil, err
 		}
 
@@ -327,12 +361,16 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 		//TODO: append signature to data
 
 		if typeProof != "shuffle" {
-			libdrynx.UpdateDB(p.DB, surveyID+"/"+typeProof, nameOfProof, data)
+			if err := libdrynx.UpdateDB(p.DB, surveyID+"/"+typeProof, nameOfProof, data); err != nil {
+				p.Mutex.Unlock()
+				return nil, err
+			}
 		}
 
 		//Decrease size of proof expected for this type by 1
 		qi.TotalNbrProofs[index]--
 		if _, err := p.Request.Replace(surveyID, qi); err != nil {
+			p.Mutex.Unlock()
 			return nil, err
 		}
 
@@ -342,7 +380,7 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 
 		//Check if all proofs has been processed.
 		proofsRemaining := 0
-		for _, count := range CastToQueryInfo(p.Request.Get(surveyID)).TotalNbrProofs {
+		for _, count := range qi.TotalNbrProofs {
 			proofsRemaining += count
 		}
 		log.Lvl2("VN", p.ServerIdentity().String(), "is checking the number of proofs.", proofsRemaining, "proofs remaining.")
@@ -351,50 +389,40 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 		if proofsRemaining == 0 {
 			log.Lvl2("VN", p.ServerIdentity().String(), "received all expected proofs.")
 
-			mapByte, err := network.Marshal(&libdrynx.BitMap{BitMap: CastToQueryInfo(p.Request.Get(string(surveyID))).Bitmap})
+			mapByte, err := network.Marshal(&libdrynx.BitMap{BitMap: qi.Bitmap})
 			if err != nil {
-				log.Fatal("Cannot marshalize map", err)
+				return nil, fmt.Errorf("cannot marshal the bitmap: %v", err)
 			}
 
-			libdrynx.UpdateDB(p.DB, p.ServerIdentity().Address.String(), surveyID+"/map", mapByte)
+			if err := libdrynx.UpdateDB(p.DB, p.ServerIdentity().Address.String(), surveyID+"/map", mapByte); err != nil {
+				return nil, err
+			}
 
 			//If not root, send bitmap
 			if !rootVN {
 				// send to root of the VNs
 				for _, treeNode := range p.Tree().List() {
 					if treeNode.ServerIdentity.String() == roster.List[0].String() {
-						bm := CastToQueryInfo(p.Request.Get(string(surveyID))).Bitmap
-						err := p.SendTo(treeNode, &BitmapCollectionMessage{Bitmap: bm})
-						if err != nil {
-							log.Fatal(err)
+						if err := p.SendTo(treeNode, &BitmapCollectionMessage{Bitmap: qi.Bitmap}); err != nil {
+							return nil, err
 						}
 					}
 				}
 				// If root
 			} else {
-				p.SharedBMChannel <- CastToQueryInfo(p.Request.Get(string(surveyID))).Bitmap
+				p.SharedBMChannel <- qi.Bitmap
 			}
 		}
 
 		//if root of VNs wait for bitmaps
 		if rootVN {
-			go func() {
-				<-p.SharedBMChannelToTerminate
-				err := p.SendTo(p.TreeNode(), &BitmapCollectionMessage{})
-				if err != nil {
-					log.Fatal(err)
-				}
-			}()
-
 			for i := 0; i < len(p.Tree().List())-1; i++ {
-				bitmap := <-p.BitmapCollectionChannel
-
-				// if the message was sent by the go routine
-				if bitmap.ServerIdentity.String() == p.ServerIdentity().String() {
+				select {
+				case bitmap := <-p.BitmapCollectionChannel:
+					p.SharedBMChannel <- bitmap.Bitmap
+				case <-p.SharedBMChannelToTerminate:
 					return nil, nil // terminate
 				}
-
-				p.SharedBMChannel <- bitmap.Bitmap
 			}
 		}
 
@@ -0,0 +1,263 @@
+// The threshold key switching protocol permits the computing nodes to switch ciphertexts encrypted under a collective
+// key generated by the DKG protocol to the querier's key, with any threshold of them.
+// The root sends the random parts of the ciphertexts to the other nodes. Each node holding a share of the key replies
+// with its contribution to the switch, from which the root interpolates the switched ciphertexts as soon as it has
+// enough of them.
+
+package protocols
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// ThresholdKeySwitchingProtocolName is the registered name for the threshold key switching protocol.
+const ThresholdKeySwitchingProtocolName = "ThresholdKeySwitching"
+
+// DefaultThresholdKeySwitchingTimeout is how long the root waits for the contributions of the other nodes.
+const DefaultThresholdKeySwitchingTimeout = time.Minute
+
+func init() {
+	network.RegisterMessage(ThresholdKeySwitchingDownMessage{})
+	network.RegisterMessage(ThresholdKeySwitchingUpMessage{})
+	if _, err := onet.GlobalProtocolRegister(ThresholdKeySwitchingProtocolName, NewThresholdKeySwitchingProtocol); err != nil {
+		log.Fatal("Error registering <ThresholdKeySwitchingProtocol>:", err)
+	}
+}
+
+// Messages
+//______________________________________________________________________________________________________________________
+
+// ThresholdKeySwitchingDownMessage contains what the nodes need to contribute to the switch.
+type ThresholdKeySwitchingDownMessage struct {
+	// random parts of the ciphertexts to switch
+	Ks              []kyber.Point
+	TargetPublicKey kyber.Point
+}
+
+// ThresholdKeySwitchingUpMessage contains the contribution of a node to the switch.
+type ThresholdKeySwitchingUpMessage struct {
+	// index of the share of the node
+	Index         int
+	Contributions libunlynx.CipherVector
+	// set if the node can't contribute
+	Error string
+}
+
+// Protocol
+//______________________________________________________________________________________________________________________
+
+// ThresholdKeySwitchingProtocol switches ciphertexts encrypted under a threshold key to another key.
+type ThresholdKeySwitchingProtocol struct {
+	*onet.TreeNodeInstance
+
+	// Protocol feedback channel, at the root
+	FeedbackChannel chan libunlynx.CipherVector
+
+	// Protocol communication channels
+	DownChannel chan struct {
+		*onet.TreeNode
+		ThresholdKeySwitchingDownMessage
+	}
+	UpChannel chan struct {
+		*onet.TreeNode
+		ThresholdKeySwitchingUpMessage
+	}
+
+	// share of the key held by the node, nil if it has none
+	Share *libdrynx.KeyShare
+
+	// key to switch to, at every node: the other ones only contribute to a switch to it
+	TargetPublicKey *kyber.Point
+
+	// Protocol state data, at the root
+	TargetOfSwitch *libunlynx.CipherVector
+	// how long to wait for the contributions of the other nodes
+	Timeout time.Duration
+	// nodes whose contributions switched the ciphertexts
+	KeySwitchers []*network.ServerIdentity
+	// set before sending on FeedbackChannel if the switch failed
+	Err error
+
+	// number of nodes reached by the root, once started
+	reached chan int
+}
+
+// NewThresholdKeySwitchingProtocol constructs a threshold key switching protocol instance
+func NewThresholdKeySwitchingProtocol(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	p := &ThresholdKeySwitchingProtocol{
+		TreeNodeInstance: n,
+		FeedbackChannel:  make(chan libunlynx.CipherVector, 1),
+		Timeout:          DefaultThresholdKeySwitchingTimeout,
+		reached:          make(chan int, 1),
+	}
+
+	if err := p.RegisterChannel(&p.DownChannel); err != nil {
+		return nil, fmt.Errorf("couldn't register down channel: %v", err)
+	}
+	if err := p.RegisterChannelLength(&p.UpChannel, len(n.Tree().List())); err != nil {
+		return nil, fmt.Errorf("couldn't register up channel: %v", err)
+	}
+
+	return p, nil
+}
+
+// Start is called at the root node and starts the execution of the protocol.
+func (p *ThresholdKeySwitchingProtocol) Start() error {
+	if p.TargetOfSwitch == nil || p.TargetPublicKey == nil {
+		p.reached <- 0
+		return errors.New("no ciphertexts or key to switch to")
+	}
+	log.Lvl2("["+p.Name()+"]", "starts a Threshold Key Switching Protocol (", len(*p.TargetOfSwitch), "ciphertext(s) )")
+
+	ks := make([]kyber.Point, len(*p.TargetOfSwitch))
+	for i, ct := range *p.TargetOfSwitch {
+		ks[i] = ct.K
+	}
+	down := ThresholdKeySwitchingDownMessage{Ks: ks, TargetPublicKey: *p.TargetPublicKey}
+
+	// the nodes which are down are left out, as long as enough others contribute
+	reached := 0
+	for _, node := range p.Tree().List() {
+		if node.IsRoot() {
+			continue
+		}
+		if err := p.SendTo(node, &down); err != nil {
+			log.Warn("["+p.Name()+"]", "unable to reach", node.ServerIdentity, ":", err)
+			continue
+		}
+		reached++
+	}
+	p.reached <- reached
+	return nil
+}
+
+// Dispatch is called on each tree node. It waits for incoming messages and handles them.
+func (p *ThresholdKeySwitchingProtocol) Dispatch() error {
+	defer p.Done()
+
+	if !p.IsRoot() {
+		down := <-p.DownChannel
+		up := ThresholdKeySwitchingUpMessage{}
+		switch {
+		case p.Share == nil:
+			up.Error = "no share of the key"
+		case p.TargetPublicKey == nil || !down.TargetPublicKey.Equal(*p.TargetPublicKey):
+			// else, the root would get a switch to any key of its own
+			up.Error = "not switching to the querier's key"
+		default:
+			up.Index = p.Share.Index
+			up.Contributions = contribution(*p.Share, down.Ks, *p.TargetPublicKey)
+		}
+		return p.SendToParent(&up)
+	}
+
+	switched, err := p.switchAtRoot()
+	p.Err = err
+	p.FeedbackChannel <- switched
+	return nil
+}
+
+// switchAtRoot collects the contributions of the nodes until enough of them are there to switch the ciphertexts.
+func (p *ThresholdKeySwitchingProtocol) switchAtRoot() (libunlynx.CipherVector, error) {
+	pending := <-p.reached
+	if p.TargetOfSwitch == nil || p.TargetPublicKey == nil {
+		return nil, errors.New("no ciphertexts or key to switch to")
+	}
+	if p.Share == nil {
+		return nil, errors.New("no share of the key")
+	}
+	threshold := p.Share.Threshold
+	if index, ok := p.Share.HolderIndex(p.Public()); !ok || index != p.Share.Index {
+		return nil, errors.New("share not generated by the node, or without the nodes holding the others")
+	}
+
+	ks := make([]kyber.Point, len(*p.TargetOfSwitch))
+	for i, ct := range *p.TargetOfSwitch {
+		ks[i] = ct.K
+	}
+	contributions := map[int]libunlynx.CipherVector{p.Share.Index: contribution(*p.Share, ks, *p.TargetPublicKey)}
+	p.KeySwitchers = []*network.ServerIdentity{p.ServerIdentity()}
+
+	timer := time.NewTimer(p.Timeout)
+	defer timer.Stop()
+
+	for len(contributions) < threshold {
+		if pending == 0 {
+			return nil, fmt.Errorf("only %v contributions to the switch, %v needed", len(contributions), threshold)
+		}
+
+		select {
+		case up := <-p.UpChannel:
+			pending--
+			// the index is the one of the share generated by the sender, not the one it claims
+			index, holder := p.Share.HolderIndex(up.ServerIdentity.Public)
+			switch _, ok := contributions[index]; {
+			case up.Error != "":
+				log.Warn("["+p.Name()+"]", up.ServerIdentity, "can't contribute to the switch:", up.Error)
+			case !holder || index != up.Index:
+				log.Warn("["+p.Name()+"]", up.ServerIdentity, "doesn't hold share", up.Index, "of the key")
+			case ok || len(up.Contributions) != len(ks):
+				log.Warn("["+p.Name()+"]", up.ServerIdentity, "sent an invalid contribution to the switch")
+			default:
+				contributions[index] = up.Contributions
+				p.KeySwitchers = append(p.KeySwitchers, up.ServerIdentity)
+			}
+		case <-timer.C:
+			return nil, fmt.Errorf("only %v contributions to the switch in %v, %v needed", len(contributions), p.Timeout, threshold)
+		}
+	}
+
+	indexes := make([]int, 0, len(contributions))
+	for index := range contributions {
+		indexes = append(indexes, index)
+	}
+
+	// interpolate the contributions, removing the collective key and adding the target one
+	switched := make(libunlynx.CipherVector, len(ks))
+	for i, ct := range *p.TargetOfSwitch {
+		switched[i] = libunlynx.CipherText{K: libunlynx.SuiTe.Point().Null(), C: ct.C.Clone()}
+	}
+	for index, cv := range contributions {
+		coefficient := libdrynx.LagrangeCoefficient(index, indexes)
+		for i, c := range cv {
+			switched[i].K.Add(switched[i].K, libunlynx.SuiTe.Point().Mul(coefficient, c.K))
+			switched[i].C.Add(switched[i].C, libunlynx.SuiTe.Point().Mul(coefficient, c.C))
+		}
+	}
+
+	log.Lvl2("["+p.Name()+"]", "switched", len(switched), "ciphertext(s) with the shares", indexes)
+	return switched, nil
+}
+
+// contribution returns, for each random part K of a ciphertext, vG and vT - sK with a fresh v, s being the share of
+// the node and T the target key.
+func contribution(share libdrynx.KeyShare, ks []kyber.Point, target kyber.Point) libunlynx.CipherVector {
+	contributions := make(libunlynx.CipherVector, len(ks))
+
+	wg := libunlynx.StartParallelize(len(ks))
+	for i, k := range ks {
+		go func(i int, k kyber.Point) {
+			defer wg.Done()
+
+			v := libunlynx.SuiTe.Scalar().Pick(random.New())
+			c := libunlynx.SuiTe.Point().Mul(v, target)
+			contributions[i] = libunlynx.CipherText{
+				K: libunlynx.SuiTe.Point().Mul(v, nil),
+				C: c.Sub(c, libunlynx.SuiTe.Point().Mul(share.Secret, k)),
+			}
+		}(i, k)
+	}
+	libunlynx.EndParallelize(wg)
+
+	return contributions
+}
@@ -0,0 +1,143 @@
+package protocols_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/protocols"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+)
+
+// keyShares are the shares generated by the DKG test, by node, and the key the nodes switch to
+var keyShares = struct {
+	sync.Mutex
+	byNode map[string]libdrynx.KeyShare
+	target kyber.Point
+}{byNode: make(map[string]libdrynx.KeyShare)}
+
+// TestThresholdKeySwitching tests the key generation and the switch with some of the nodes
+func TestThresholdKeySwitching(t *testing.T) {
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	if _, err := onet.GlobalProtocolRegister("DKGTest", NewDKGTest); err != nil {
+		log.Fatal("Failed to register the <DKGTest> protocol:", err)
+	}
+	if _, err := onet.GlobalProtocolRegister("ThresholdKeySwitchingTest", NewThresholdKeySwitchingTest); err != nil {
+		log.Fatal("Failed to register the <ThresholdKeySwitchingTest> protocol:", err)
+	}
+
+	_, _, tree := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	// generate the key, any 3 of the 5 nodes switching to the querier's key
+	p, err := local.CreateProtocol("DKGTest", tree)
+	require.NoError(t, err)
+	dkg := p.(*protocols.DKGProtocol)
+	dkg.Threshold = 3
+	go func() {
+		if err := dkg.Start(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	var collectiveKey libdrynx.KeyShare
+	select {
+	case collectiveKey = <-dkg.FeedbackChannel:
+		require.NoError(t, dkg.Err)
+	case <-time.After(protocols.DefaultDKGTimeout):
+		t.Fatal("Didn't generate the key in time")
+	}
+
+	// a node lost its share
+	keyShares.Lock()
+	assert.Len(t, keyShares.byNode, 5)
+	delete(keyShares.byNode, tree.List()[4].ServerIdentity.String())
+	querier := key.NewKeyPair(libunlynx.SuiTe)
+	keyShares.target = querier.Public
+	keyShares.Unlock()
+
+	target := libunlynx.EncryptIntVector(collectiveKey.Key, []int64{0, 1, 42})
+
+	p, err = local.CreateProtocol("ThresholdKeySwitchingTest", tree)
+	require.NoError(t, err)
+	keySwitch := p.(*protocols.ThresholdKeySwitchingProtocol)
+	keySwitch.TargetOfSwitch = target
+	keySwitch.TargetPublicKey = &querier.Public
+	go func() {
+		if err := keySwitch.Start(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	select {
+	case switched := <-keySwitch.FeedbackChannel:
+		require.NoError(t, keySwitch.Err)
+		assert.Equal(t, []int64{0, 1, 42}, libunlynx.DecryptIntVector(querier.Private, &switched))
+		assert.True(t, len(keySwitch.KeySwitchers) >= 3)
+	case <-time.After(protocols.DefaultThresholdKeySwitchingTimeout):
+		t.Fatal("Didn't switch in time")
+	}
+
+	// the other nodes don't help the root switching to a key of its own
+	p, err = local.CreateProtocol("ThresholdKeySwitchingTest", tree)
+	require.NoError(t, err)
+	keySwitch = p.(*protocols.ThresholdKeySwitchingProtocol)
+	rogue := key.NewKeyPair(libunlynx.SuiTe)
+	keySwitch.TargetOfSwitch = target
+	keySwitch.TargetPublicKey = &rogue.Public
+	go func() {
+		if err := keySwitch.Start(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	select {
+	case <-keySwitch.FeedbackChannel:
+		assert.Error(t, keySwitch.Err)
+	case <-time.After(protocols.DefaultThresholdKeySwitchingTimeout):
+		t.Fatal("Didn't refuse in time")
+	}
+}
+
+// NewDKGTest is a test specific protocol instance constructor that keeps the generated shares.
+func NewDKGTest(tni *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	pi, err := protocols.NewDKGProtocol(tni)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := pi.(*protocols.DKGProtocol)
+	protocol.OnShare = func(share libdrynx.KeyShare) error {
+		keyShares.Lock()
+		defer keyShares.Unlock()
+		keyShares.byNode[tni.ServerIdentity().String()] = share
+		return nil
+	}
+	return protocol, nil
+}
+
+// NewThresholdKeySwitchingTest is a test specific protocol instance constructor that injects the generated shares.
+func NewThresholdKeySwitchingTest(tni *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	pi, err := protocols.NewThresholdKeySwitchingProtocol(tni)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := pi.(*protocols.ThresholdKeySwitchingProtocol)
+	keyShares.Lock()
+	defer keyShares.Unlock()
+	if share, ok := keyShares.byNode[tni.ServerIdentity().String()]; ok {
+		protocol.Share = &share
+	}
+	if target := keyShares.target; target != nil {
+		protocol.TargetPublicKey = &target
+	}
+	return protocol, nil
+}
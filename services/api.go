@@ -1,8 +1,12 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+
 	"github.com/ldsec/drynx/lib"
-	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/decryption"
 	"github.com/ldsec/drynx/lib/obfuscation"
 	"github.com/ldsec/drynx/lib/range"
 	"github.com/ldsec/unlynx/lib"
@@ -23,10 +27,19 @@ type API struct {
 	entryPoint *network.ServerIdentity
 	public     kyber.Point
 	private    kyber.Scalar
+
+	// solves the values of the results, with a default table computed on the first results if not set
+	decryption     *libdrynxdecryption.Solver
+	decryptionOnce sync.Once
 }
 
 // NewDrynxClient constructor of a client.
 func NewDrynxClient(entryPoint *network.ServerIdentity, clientID string) *API {
+	return NewDrynxClientWithKeys(entryPoint, clientID, key.NewKeyPair(libunlynx.SuiTe))
+}
+
+// NewDrynxClientWithKeys constructor of a client identified by a long-term key pair, such as one allowed by the nodes.
+func NewDrynxClientWithKeys(entryPoint *network.ServerIdentity, clientID string, keys *key.Pair) *API {
 	network.RegisterMessage(libdrynx.GetLatestBlock{})
 	network.RegisterMessage(libdrynxrange.RangeProofListBytes{})
 	network.RegisterMessage(libunlynxshuffle.PublishedShufflingProofBytes{})
@@ -34,7 +47,6 @@ func NewDrynxClient(entryPoint *network.ServerIdentity, clientID string) *API {
 	network.RegisterMessage(libunlynxaggr.PublishedAggregationListProofBytes{})
 	network.RegisterMessage(libdrynxobfuscation.PublishedListObfuscationProofBytes{})
 
-	keys := key.NewKeyPair(libunlynx.SuiTe)
 	newClient := &API{
 		Client:     onet.NewClient(libdrynx.Suite, ServiceName),
 		clientID:   clientID,
@@ -43,11 +55,39 @@ func NewDrynxClient(entryPoint *network.ServerIdentity, clientID string) *API {
 		private:    keys.Private,
 	}
 
-	limit := int64(10000)
-	libunlynx.CreateDecryptionTable(limit, newClient.public, newClient.private)
 	return newClient
 }
 
+// SetDecryption sets how the client solves the values of the results, such as with a table read from disk or a larger
+// limit, instead of the default solver.
+func (c *API) SetDecryption(solver *libdrynxdecryption.Solver) {
+	c.decryption = solver
+}
+
+// decrypt decrypts the ciphertexts, failing on the values out of the range of the client's solver.
+func (c *API) decrypt(cv libunlynx.CipherVector) ([]int64, error) {
+	var err error
+	c.decryptionOnce.Do(func() {
+		if c.decryption == nil {
+			var table *libdrynxdecryption.Table
+			table, err = libdrynxdecryption.NewTable(libdrynxdecryption.DefaultTableBound)
+			c.decryption = libdrynxdecryption.NewSolver(table)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]int64, len(cv))
+	for i, ct := range cv {
+		point := libunlynx.DecryptPoint(c.private, ct)
+		if values[i], err = c.decryption.Solve(point); err != nil {
+			return nil, fmt.Errorf("unable to decrypt output %v of the result: %v", i, err)
+		}
+	}
+	return values, nil
+}
+
 // Send Query
 //______________________________________________________________________________________________________________________
 
@@ -92,40 +132,215 @@ func (c *API) GenerateSurveyQuery(rosterServers, rosterVNs *onet.Roster, dpToSer
 	}
 }
 
+// Nodes are the nodes of a network, by the roles they advertise
+type Nodes struct {
+	ComputingNodes []network.ServerIdentity
+	DataProviders  []network.ServerIdentity
+	VerifyingNodes []network.ServerIdentity
+}
+
+func newRoster(ids []network.ServerIdentity) *onet.Roster {
+	list := make([]*network.ServerIdentity, len(ids))
+	for i := range ids {
+		list[i] = &ids[i]
+	}
+	return onet.NewRoster(list)
+}
+
+// GenerateSurveyQueryForNodes generates a query as GenerateSurveyQuery, run by the given nodes: the first computing
+// node is the one to send the query to, and the data providers are assigned by libdrynx.AssignDataProviders.
+func (c *API) GenerateSurveyQueryForNodes(nodes Nodes, assigned map[string]string, surveyID string, operation libdrynx.Operation, ranges []*libdrynx.Int64List, ps []*libdrynx.PublishSignatureBytesList, proofs int, obfuscation bool, thresholds []float64, diffP libdrynx.QueryDiffP, cuttingFactor int) (libdrynx.SurveyQuery, error) {
+	if len(nodes.ComputingNodes) == 0 || len(nodes.DataProviders) == 0 {
+		return libdrynx.SurveyQuery{}, errors.New("need some computing nodes and data providers")
+	}
+
+	rosterServers := newRoster(nodes.ComputingNodes)
+	if rosterServers == nil {
+		return libdrynx.SurveyQuery{}, errors.New("unable to gen roster of computing nodes")
+	}
+	var rosterVNs *onet.Roster
+	if proofs != 0 {
+		if rosterVNs = newRoster(nodes.VerifyingNodes); rosterVNs == nil {
+			return libdrynx.SurveyQuery{}, errors.New("proofs need some verifying nodes")
+		}
+	}
+
+	assignment, err := libdrynx.AssignDataProviders(nodes.ComputingNodes, nodes.DataProviders, assigned)
+	if err != nil {
+		return libdrynx.SurveyQuery{}, err
+	}
+	dpToServer := make(map[string]*[]network.ServerIdentity, len(assignment))
+	for cn, dps := range assignment {
+		dpToServer[cn] = &dps.Content
+	}
+
+	idToPublic := make(map[string]kyber.Point)
+	for _, ids := range [][]network.ServerIdentity{nodes.ComputingNodes, nodes.DataProviders, nodes.VerifyingNodes} {
+		for _, id := range ids {
+			idToPublic[id.String()] = id.ServicePublic(ServiceName)
+		}
+	}
+
+	return c.GenerateSurveyQuery(rosterServers, rosterVNs, dpToServer, idToPublic, surveyID, operation, ranges, ps, proofs, obfuscation, thresholds, diffP, cuttingFactor), nil
+}
+
 // SendSurveyQuery creates a survey based on a set of entities (servers) and a survey description.
-func (c *API) SendSurveyQuery(sq libdrynx.SurveyQuery) (*[]string, *[][]float64, error) {
+func (c *API) SendSurveyQuery(sq libdrynx.SurveyQuery) (*SurveyResult, error) {
+	sr, err := c.send(sq)
+	if err != nil {
+		return nil, err
+	}
+	return c.decodeResponse(sq, sr)
+}
+
+// send signs the survey and sends it to the entry point, waiting for its encrypted result.
+func (c *API) send(sq libdrynx.SurveyQuery) (libdrynx.ResponseDP, error) {
 	log.Lvl2("[API] <Drynx> Client", c.clientID, "is creating a query with SurveyID: ", sq.SurveyID)
 
-	if sq.ClientPubKey == nil {
-		sq.ClientPubKey = c.public
+	if err := c.sign(&sq); err != nil {
+		return libdrynx.ResponseDP{}, err
 	}
 
 	//send the query and get the answer
 	sr := libdrynx.ResponseDP{}
 	err := c.SendProtobuf(c.entryPoint, &sq, &sr)
 	if err != nil {
-		return nil, nil, err
+		return libdrynx.ResponseDP{}, err
 	}
 
 	log.Lvl2("[API] <Drynx> Client", c.clientID, "successfully executed the query with SurveyID ", sq.SurveyID)
+	return sr, nil
+}
+
+// sign sets the client as the querier of the survey, signing it, unless the results are to be switched to another key.
+func (c *API) sign(sq *libdrynx.SurveyQuery) error {
+	if sq.ClientPubKey == nil {
+		sq.ClientPubKey = c.public
+	}
+	if !sq.ClientPubKey.Equal(c.public) {
+		return nil
+	}
+	return sq.Sign(c.private)
+}
+
+// SubmitSurveyQuery starts a survey without waiting for its result, to be polled with GetSurveyStatus.
+func (c *API) SubmitSurveyQuery(sq libdrynx.SurveyQuery) (string, error) {
+	log.Lvl2("[API] <Drynx> Client", c.clientID, "is submitting a query with SurveyID: ", sq.SurveyID)
+
+	if err := c.sign(&sq); err != nil {
+		return "", err
+	}
+
+	handle := libdrynx.SurveyHandle{}
+	if err := c.SendProtobuf(c.entryPoint, &libdrynx.SubmitSurveyQuery{SQ: sq}, &handle); err != nil {
+		return "", err
+	}
+	return handle.SurveyID, nil
+}
+
+// GetSurveyStatus reports the progress of a submitted survey.
+func (c *API) GetSurveyStatus(surveyID string) (*libdrynx.SurveyStatus, error) {
+	status := libdrynx.SurveyStatus{}
+	if err := c.SendProtobuf(c.entryPoint, &libdrynx.GetSurveyStatus{SurveyID: surveyID}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// FetchSurveyResult gets the result of a finished submitted survey, as returned by SendSurveyQuery.
+// The survey must have been submitted by this client, as the result is encrypted for it.
+func (c *API) FetchSurveyResult(sq libdrynx.SurveyQuery) (*SurveyResult, error) {
+	sr := libdrynx.ResponseDP{}
+	if err := c.SendProtobuf(c.entryPoint, &libdrynx.FetchSurveyResult{SurveyID: sq.SurveyID}, &sr); err != nil {
+		return nil, err
+	}
+
+	return c.decodeResponse(sq, sr)
+}
+
+// CancelSurvey stops a survey sent to the entry point, which asks all the nodes of the survey to stop.
+func (c *API) CancelSurvey(surveyID string) error {
+	cancel := libdrynx.CancelSurvey{SurveyID: surveyID}
+	if err := cancel.Sign(c.private); err != nil {
+		return err
+	}
+	return c.SendProtobuf(c.entryPoint, &cancel, nil)
+}
+
+// SendGetDatasets requests the names of the datasets served by a DP
+func (c *API) SendGetDatasets(dp *network.ServerIdentity) ([]string, error) {
+	reply := libdrynx.Datasets{}
+	if err := c.SendProtobuf(dp, &libdrynx.GetDatasets{}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Names, nil
+}
 
-	// decrypt/decode the result
-	clientDecode := libunlynx.StartTimer("Decode")
-	log.Lvl2("[API] <Drynx> Client", c.clientID, "is decrypting the results")
-
-	grp := make([]string, len(sr.Data))
-	aggr := make([][]float64, len(sr.Data))
-	count := 0
-	for i, res := range sr.Data {
-		vec := make(libunlynx.CipherVector, len(res.Content))
-		for j, e := range res.Content {
-			vec[j] = libunlynx.CipherText{K: e.K, C: e.C}
+// SendGetRoles requests the roles a node takes in the surveys
+func (c *API) SendGetRoles(node *network.ServerIdentity) (libdrynx.Roles, error) {
+	reply := libdrynx.Roles{}
+	if err := c.SendProtobuf(node, &libdrynx.GetRoles{}, &reply); err != nil {
+		return libdrynx.Roles{}, err
+	}
+	return reply, nil
+}
+
+// SendGetStatus asks a node what it is doing
+func (c *API) SendGetStatus(node *network.ServerIdentity) (libdrynx.Status, error) {
+	reply := libdrynx.Status{}
+	if err := c.SendProtobuf(node, &libdrynx.GetStatus{}, &reply); err != nil {
+		return libdrynx.Status{}, err
+	}
+	return reply, nil
+}
+
+// SendStartDKG generates a collective key with the computing nodes, which any threshold of them can use to switch the
+// results of the surveys; the request is signed, as the nodes may only generate keys for known queriers
+func (c *API) SendStartDKG(computingNodes []network.ServerIdentity, threshold int) (kyber.Point, error) {
+	roster := newRoster(computingNodes)
+	if roster == nil {
+		return nil, errors.New("no computing nodes")
+	}
+	start := libdrynx.StartDKG{Roster: roster, Threshold: threshold}
+	if err := start.Sign(c.private); err != nil {
+		return nil, err
+	}
+	reply := libdrynx.CollectiveKey{}
+	if err := c.SendProtobuf(c.entryPoint, &start, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Key, nil
+}
+
+// DiscoverNodes asks the nodes for their roles, leaving out the ones not replying, such as a computing node which is down
+func (c *API) DiscoverNodes(nodes []network.ServerIdentity) Nodes {
+	discovered := Nodes{}
+	for _, node := range nodes {
+		node := node
+		roles, err := c.SendGetRoles(&node)
+		if err != nil {
+			log.Warn("[API] <Drynx> Client", c.clientID, "leaving out node", node.String(), ":", err)
+			continue
+		}
+
+		if roles.ComputingNode {
+			discovered.ComputingNodes = append(discovered.ComputingNodes, node)
+		}
+		if roles.DataProvider {
+			discovered.DataProviders = append(discovered.DataProviders, node)
+		}
+		if roles.VerifyingNode {
+			discovered.VerifyingNodes = append(discovered.VerifyingNodes, node)
 		}
-		grp[count] = i
-		aggr[count] = libdrynxencoding.Decode(vec, c.private, sq.Query.Operation)
 	}
-	libunlynx.EndTimer(clientDecode)
+	return discovered
+}
 
-	log.Lvl2("[API] <Drynx> Client", c.clientID, "finished decrypting the results")
-	return &grp, &aggr, nil
+// SendListSurveys requests the surveys held by a node, with their state for each of its roles
+func (c *API) SendListSurveys(node *network.ServerIdentity) ([]libdrynx.SurveyInfo, error) {
+	reply := libdrynx.SurveysList{}
+	if err := c.SendProtobuf(node, &libdrynx.ListSurveys{}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Surveys, nil
 }
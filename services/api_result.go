@@ -0,0 +1,160 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/proof"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// SurveyResult is the decrypted and decoded result of a survey
+type SurveyResult struct {
+	SurveyID string
+	// results of each group, ordered by key
+	Groups []GroupResult
+	// data providers whose responses were aggregated
+	DPs []string
+	// computing nodes which switched the result, set for a survey under a threshold key
+	KeySwitchers []string
+	// verification of the proofs, set for a survey with proofs
+	Proofs *ProofsSummary
+}
+
+// GroupResult is the result of a group of a survey
+type GroupResult struct {
+	// values of the grouping columns
+	Key []int64
+	// results of each operation, several for a batch
+	Operations []OperationResult
+}
+
+// OperationResult is the result of an operation for a group
+type OperationResult struct {
+	Operation string
+	// name of each value, such as the counted value for a frequencyCount
+	Names  []string
+	Values []float64
+}
+
+// ProofsSummary sums up the verification of the proofs of a survey by the verifying nodes
+type ProofsSummary struct {
+	// number of proofs by outcome of their verification, such as "verified", or "received" when not sampled
+	Outcomes map[string]int
+	// set if the verification couldn't be fetched, such as while the verifying nodes are still at it
+	Error string
+}
+
+// decodeResponse decrypts and decodes the result of the survey, for each group and each operation of a batch.
+func (c *API) decodeResponse(sq libdrynx.SurveyQuery, sr libdrynx.ResponseDP) (*SurveyResult, error) {
+	queries, err := sq.Query.SubQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	// decrypt/decode the result
+	clientDecode := libunlynx.StartTimer("Decode")
+	log.Lvl2("[API] <Drynx> Client", c.clientID, "is decrypting the results")
+
+	result := &SurveyResult{SurveyID: sq.SurveyID, DPs: sr.DPs, KeySwitchers: sr.KeySwitchers}
+	for group, res := range sr.Data {
+		key, err := libdrynx.ParseGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		groupResult := GroupResult{Key: key, Operations: make([]OperationResult, len(queries))}
+
+		vec := make(libunlynx.CipherVector, len(res.Content))
+		for j, e := range res.Content {
+			vec[j] = libunlynx.CipherText{K: e.K, C: e.C}
+		}
+
+		// the outputs of the operations of a batch are concatenated
+		offset := 0
+		for k, query := range queries {
+			next := len(vec)
+			if len(queries) > 1 {
+				if next = offset + query.Operation.NbrOutput; next > len(vec) {
+					return nil, fmt.Errorf("result of %v outputs, operations of the batch giving more", len(vec))
+				}
+			}
+			part := vec[offset:next]
+			values, err := c.decrypt(part)
+			if err != nil {
+				return nil, err
+			}
+			if sr.LocalDiffP != nil && libdrynxencoding.IsBitOperation(query.Operation.NameOp) {
+				// estimate the real count of each bit before decoding it
+				values = libdrynxencoding.DebiasLocally(values, *sr.LocalDiffP)
+			}
+
+			decoded := libdrynxencoding.DecodeClear(values, query.Operation)
+			groupResult.Operations[k] = OperationResult{
+				Operation: query.Operation.NameOp,
+				Names:     query.Operation.OutputNames(len(decoded)),
+				Values:    decoded,
+			}
+			offset = next
+		}
+
+		result.Groups = append(result.Groups, groupResult)
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		return lessKey(result.Groups[i].Key, result.Groups[j].Key)
+	})
+	libunlynx.EndTimer(clientDecode)
+
+	log.Lvl2("[API] <Drynx> Client", c.clientID, "results aggregated from", len(sr.DPs), "DPs:", sr.DPs)
+	if sr.LocalDiffP != nil {
+		log.Lvl2("[API] <Drynx> Client", c.clientID, "results perturbed by", sr.LocalDiffP.NbrDPs, "DPs, with a noise variance of", sr.LocalDiffP.Variance)
+	}
+	if len(sr.KeySwitchers) > 0 {
+		log.Lvl2("[API] <Drynx> Client", c.clientID, "results switched by", len(sr.KeySwitchers), "CNs:", sr.KeySwitchers)
+	}
+	log.Lvl2("[API] <Drynx> Client", c.clientID, "finished decrypting the results")
+
+	if sq.Query.Proofs != 0 && sq.Query.RosterVNs != nil {
+		result.Proofs = c.summarizeProofs(sq)
+	}
+	return result, nil
+}
+
+func lessKey(a, b []int64) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// summarizeProofs fetches the verification of the proofs of the survey inserted by the verifying nodes in the
+// skipchain, without waiting for it.
+func (c *API) summarizeProofs(sq libdrynx.SurveyQuery) *ProofsSummary {
+	summary := &ProofsSummary{Outcomes: make(map[string]int)}
+
+	sb, err := c.SendGetBlock(sq.Query.RosterVNs, sq.SurveyID)
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+	_, msg, err := network.Unmarshal(sb.Data, libunlynx.SuiTe)
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+	block, ok := msg.(*libdrynx.DataBlock)
+	if !ok {
+		summary.Error = "block without the verification of the survey"
+		return summary
+	}
+
+	for _, verification := range block.Proofs {
+		summary.Outcomes[drynxproof.Outcome(verification)]++
+	}
+	return summary
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/util/key"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+func encryptedGroup(client *API, values ...int64) *libdrynx.CipherVector {
+	cv := libunlynx.EncryptIntVector(client.public, values)
+	vec := &libdrynx.CipherVector{Content: make([]*libdrynx.CipherText, len(*cv))}
+	for i, ct := range *cv {
+		vec.Content[i] = &libdrynx.CipherText{K: ct.K, C: ct.C}
+	}
+	return vec
+}
+
+func TestDecodeResponseGroups(t *testing.T) {
+	client := NewDrynxClientWithKeys(nil, "test-decode", key.NewKeyPair(libunlynx.SuiTe))
+
+	sum, err := libdrynx.ChooseOperation("sum", 0, 0, 0, 0)
+	require.NoError(t, err)
+	freqCount, err := libdrynx.ChooseOperation("frequencyCount", 0, 2, 0, 0)
+	require.NoError(t, err)
+	batch, err := libdrynx.NewBatchOperation([]libdrynx.Operation{sum, freqCount})
+	require.NoError(t, err)
+
+	sq := libdrynx.SurveyQuery{SurveyID: "test-decode", Query: libdrynx.Query{Operation: batch, Batch: []libdrynx.Operation{sum, freqCount}}}
+	sr := libdrynx.ResponseDP{
+		Data: map[string]*libdrynx.CipherVector{
+			fmt.Sprint([]int64{1}): encryptedGroup(client, 12, 3, 4, 5),
+			fmt.Sprint([]int64{0}): encryptedGroup(client, -7, 0, 1, 0),
+		},
+		DPs: []string{"dp"},
+	}
+
+	result, err := client.decodeResponse(sq, sr)
+	require.NoError(t, err)
+	assert.Equal(t, "test-decode", result.SurveyID)
+	assert.Equal(t, []string{"dp"}, result.DPs)
+	assert.Nil(t, result.Proofs)
+	assert.Equal(t, []GroupResult{{
+		Key: []int64{0},
+		Operations: []OperationResult{
+			{Operation: "sum", Names: []string{"sum"}, Values: []float64{-7}},
+			{Operation: "frequencyCount", Names: []string{"0", "1", "2"}, Values: []float64{0, 1, 0}},
+		},
+	}, {
+		Key: []int64{1},
+		Operations: []OperationResult{
+			{Operation: "sum", Names: []string{"sum"}, Values: []float64{12}},
+			{Operation: "frequencyCount", Names: []string{"0", "1", "2"}, Values: []float64{3, 4, 5}},
+		},
+	}}, result.Groups)
+}
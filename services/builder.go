@@ -1,7 +1,10 @@
 package services
 
 import (
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/fanliao/go-concurrentMap"
 	"go.dedis.ch/cothority/v3/skipchain"
@@ -11,6 +14,7 @@ import (
 	"go.dedis.ch/protobuf"
 
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/authorization"
 	"github.com/ldsec/drynx/lib/operations"
 	"github.com/ldsec/drynx/lib/provider"
 	"github.com/ldsec/drynx/protocols"
@@ -24,13 +28,19 @@ func init() {
 }
 
 type builderDataProvider struct {
-	loader      provider.Loader
-	neutralizer provider.Neutralizer
+	datasets   map[string]dataset
+	accountant provider.Accountant
 }
 
 // Builder is the state of node creation.
+// A node can run any subset of the roles, registering only what they need.
 type Builder struct {
-	dataProvider *builderDataProvider
+	computingNode    bool
+	resultsRetention time.Duration
+	dataProvider     *builderDataProvider
+	verifyingNode    bool
+	queriers         *authorization.Policy
+	dataDir          string
 }
 
 // NewBuilder allow to create a node.
@@ -40,72 +50,178 @@ func NewBuilder() Builder {
 
 // WithComputingNode add support for running as a Computing Node.
 func (b Builder) WithComputingNode() Builder {
-	// TODO split
-	msgTypes.msgSurveyQuery = onet_network.RegisterMessage(&libdrynx.SurveyQuery{})
-	msgTypes.msgSurveyQueryToDP = onet_network.RegisterMessage(&libdrynx.SurveyQueryToDP{})
-	msgTypes.msgDPqueryReceived = onet_network.RegisterMessage(&DPqueryReceived{})
-	msgTypes.msgSyncDCP = onet_network.RegisterMessage(&SyncDCP{})
-	msgTypes.msgDPdataFinished = onet_network.RegisterMessage(&DPdataFinished{})
-
-	onet_network.RegisterMessage(&libdrynx.SurveyQueryToVN{})
-	onet_network.RegisterMessage(&libdrynx.ResponseDP{})
-
-	onet_network.RegisterMessage(&libdrynx.EndVerificationRequest{})
+	b.computingNode = true
+	if b.resultsRetention == 0 {
+		b.resultsRetention = DefaultResultsRetention
+	}
+	return b
+}
 
-	onet_network.RegisterMessage(libdrynx.DataBlock{})
-	onet_network.RegisterMessage(&libdrynx.GetLatestBlock{})
-	onet_network.RegisterMessage(&libdrynx.GetGenesis{})
-	onet_network.RegisterMessage(&libdrynx.GetBlock{})
-	onet_network.RegisterMessage(&libdrynx.GetProofs{})
-	onet_network.RegisterMessage(&libdrynx.CloseDB{})
+// WithResultsRetention sets how long the Computing Node keeps the results of submitted surveys, and the finished
+// surveys themselves. Nodes without this role keep them for DefaultResultsRetention.
+func (b Builder) WithResultsRetention(retention time.Duration) Builder {
+	if !b.computingNode {
+		panic("WithResultsRetention: not a computing node")
+	}
 
+	b.resultsRetention = retention
 	return b
 }
 
-// WithDataProvider add support for running as a Data Provider.
+// WithDataProvider add support for running as a Data Provider, serving the default dataset.
 func (b Builder) WithDataProvider(loader provider.Loader, neutralizer provider.Neutralizer) Builder {
+	return b.WithDataset("", loader, neutralizer)
+}
+
+// WithDataset add support for running as a Data Provider, serving a dataset under the given name.
+// It can be called multiple times, for different names.
+func (b Builder) WithDataset(name string, loader provider.Loader, neutralizer provider.Neutralizer) Builder {
 	if loader == nil {
-		panic("WithDataProvider: loader == nil")
+		panic("WithDataset: loader == nil")
 	}
 
-	onet_network.RegisterMessage(protocols.AnnouncementDCMessage{})
-	onet_network.RegisterMessage(protocols.DataCollectionMessage{})
+	dataProvider := builderDataProvider{datasets: make(map[string]dataset)}
+	if b.dataProvider != nil {
+		dataProvider.accountant = b.dataProvider.accountant
+		for n, ds := range b.dataProvider.datasets {
+			dataProvider.datasets[n] = ds
+		}
+	}
 
-	_, err := onet.GlobalProtocolRegister(protocols.DataCollectionProtocolName, protocols.NewDataCollectionProtocol)
-	if err != nil {
-		log.Fatal("Error registering <DataCollectionProtocol>:", err)
+	if _, ok := dataProvider.datasets[name]; ok {
+		panic("WithDataset: dataset already added: " + name)
+	}
+	dataProvider.datasets[name] = dataset{loader, neutralizer}
+
+	b.dataProvider = &dataProvider
+	return b
+}
+
+// WithAccountant add a privacy budget to the Data Provider.
+func (b Builder) WithAccountant(accountant provider.Accountant) Builder {
+	if b.dataProvider == nil {
+		panic("WithAccountant: not a data provider")
+	}
+
+	dataProvider := *b.dataProvider
+	dataProvider.accountant = accountant
+	b.dataProvider = &dataProvider
+	return b
+}
+
+// WithQueriers restricts the surveys run by the Computing Node and the Data Provider to the ones signed by the
+// queriers of the policy, as allowed by their rule. Without it, any survey is run.
+func (b Builder) WithQueriers(policy *authorization.Policy) Builder {
+	if policy == nil {
+		panic("WithQueriers: policy == nil")
+	}
+
+	b.queriers = policy
+	return b
+}
+
+// WithDataDir sets where the node keeps its data, such as the precomputations for shuffling, reused across surveys, and
+// the shares of the collective keys generated with the other computing nodes.
+// Without it, a directory specific to the node is created in the temporary one.
+func (b Builder) WithDataDir(dir string) Builder {
+	if dir == "" {
+		panic("WithDataDir: dir is empty")
 	}
 
-	b.dataProvider = &builderDataProvider{loader, neutralizer}
+	b.dataDir = dir
 	return b
 }
 
 // WithVerifyingNode add support for running as a Verifying Node.
 func (b Builder) WithVerifyingNode() Builder {
+	b.verifyingNode = true
 	return b
 }
 
+// registerMessages registers the messages received by the roles of the node.
+func (b Builder) registerMessages() {
+	// exchanged between the computing nodes and their data providers
+	if b.computingNode || b.dataProvider != nil {
+		msgTypes.msgSurveyQueryToDP = onet_network.RegisterMessage(&libdrynx.SurveyQueryToDP{})
+		msgTypes.msgDPqueryReceived = onet_network.RegisterMessage(&DPqueryReceived{})
+		msgTypes.msgCancelSurvey = onet_network.RegisterMessage(&libdrynx.CancelSurvey{})
+
+		onet_network.RegisterMessage(protocols.AnnouncementDCMessage{})
+		onet_network.RegisterMessage(protocols.DataCollectionMessage{})
+
+		_, err := onet.GlobalProtocolRegister(protocols.DataCollectionProtocolName, protocols.NewDataCollectionProtocol)
+		if err != nil {
+			log.Fatal("Error registering <DataCollectionProtocol>:", err)
+		}
+	}
+
+	if b.computingNode {
+		msgTypes.msgSurveyQuery = onet_network.RegisterMessage(&libdrynx.SurveyQuery{})
+		msgTypes.msgSyncDCP = onet_network.RegisterMessage(&SyncDCP{})
+		msgTypes.msgDPdataFinished = onet_network.RegisterMessage(&DPdataFinished{})
+
+		onet_network.RegisterMessage(&libdrynx.ResponseDP{})
+		onet_network.RegisterMessage(&libdrynx.StartDKG{})
+		onet_network.RegisterMessage(&libdrynx.CollectiveKey{})
+	}
+
+	if b.verifyingNode {
+		onet_network.RegisterMessage(&libdrynx.SurveyQueryToVN{})
+		onet_network.RegisterMessage(&libdrynx.EndVerificationRequest{})
+
+		onet_network.RegisterMessage(libdrynx.DataBlock{})
+		onet_network.RegisterMessage(&libdrynx.GetLatestBlock{})
+		onet_network.RegisterMessage(&libdrynx.GetGenesis{})
+		onet_network.RegisterMessage(&libdrynx.GetBlock{})
+		onet_network.RegisterMessage(&libdrynx.GetProofs{})
+		onet_network.RegisterMessage(&libdrynx.CloseDB{})
+	}
+}
+
 // Start actually starts the node. You still have to start the onet server.
 func (b Builder) Start() {
-	var loader provider.Loader
-	if b.dataProvider != nil {
-		loader = b.dataProvider.loader
+	if !b.computingNode && b.dataProvider == nil && !b.verifyingNode {
+		panic("Start: no role")
 	}
-	var neutralizer provider.Neutralizer
+	b.registerMessages()
+
+	retention := b.resultsRetention
+	if retention == 0 {
+		retention = DefaultResultsRetention
+	}
+
+	roles := libdrynx.Roles{
+		ComputingNode: b.computingNode,
+		DataProvider:  b.dataProvider != nil,
+		VerifyingNode: b.verifyingNode,
+	}
+
+	var datasets map[string]dataset
+	var accountant provider.Accountant
 	if b.dataProvider != nil {
-		neutralizer = b.dataProvider.neutralizer
+		datasets = b.dataProvider.datasets
+		accountant = b.dataProvider.accountant
 	}
 
 	_, err := onet.RegisterNewService(ServiceName, func(c *onet.Context) (onet.Service, error) {
-		if loader == nil {
-			panic("loader == nil")
+		dataDir := b.dataDir
+		if dataDir == "" {
+			dataDir = filepath.Join(os.TempDir(), "drynx-"+c.ServerIdentity().ID.String())
 		}
+
 		newDrynxInstance := &ServiceDrynx{
 			ServiceProcessor: onet.NewServiceProcessor(c),
+			roles:            roles,
+			started:          time.Now(),
+			controls:         newSurveyControls(retention),
 			Survey:           concurrent.NewConcurrentMap(),
+			submitted:        newSubmittedSurveys(b.resultsRetention),
 			Mutex:            &sync.Mutex{},
-			loader:           loader,
-			neutralizer:      neutralizer,
+			datasets:         datasets,
+			accountant:       accountant,
+			queriers:         b.queriers,
+			shuffles:         newShufflePrecomputations(dataDir),
+			keyShares:        newKeyShares(dataDir),
 		}
 
 		registerHandler := func(handler interface{}) {
@@ -114,22 +230,45 @@ func (b Builder) Start() {
 			}
 		}
 
-		registerHandler(newDrynxInstance.HandleSurveyQuery)
-		registerHandler(newDrynxInstance.HandleSurveyQueryToDP)
-		registerHandler(newDrynxInstance.HandleSurveyQueryToVN)
-		registerHandler(newDrynxInstance.HandleEndVerification)
-		registerHandler(newDrynxInstance.HandleGetLatestBlock)
-		registerHandler(newDrynxInstance.HandleGetGenesis)
-		registerHandler(newDrynxInstance.HandleGetBlock)
-		registerHandler(newDrynxInstance.HandleGetProofs)
-		registerHandler(newDrynxInstance.HandleCloseDB)
-
-		c.RegisterProcessor(newDrynxInstance, msgTypes.msgSurveyQuery)
-		c.RegisterProcessor(newDrynxInstance, msgTypes.msgSurveyQueryToDP)
-
-		//Register new verifFunction
-		if err := skipchain.RegisterVerification(c, VerifyBitmap, newDrynxInstance.verifyFuncBitmap); err != nil {
-			return nil, err
+		registerHandler(newDrynxInstance.HandleGetRoles)
+		registerHandler(newDrynxInstance.HandleListSurveys)
+		registerHandler(newDrynxInstance.HandleGetStatus)
+		if err := c.RegisterRESTHandler(newDrynxInstance.HandleGetStatus, ServiceName, "GET", 3, 3); err != nil {
+			log.Fatal("[SERVICE] <drynx> Server, Wrong REST Handler.", err)
+		}
+
+		if b.computingNode {
+			registerHandler(newDrynxInstance.HandleSurveyQuery)
+			registerHandler(newDrynxInstance.HandleSubmitSurveyQuery)
+			registerHandler(newDrynxInstance.HandleGetSurveyStatus)
+			registerHandler(newDrynxInstance.HandleFetchSurveyResult)
+			registerHandler(newDrynxInstance.HandleCancelSurvey)
+			registerHandler(newDrynxInstance.HandleStartDKG)
+			c.RegisterProcessor(newDrynxInstance, msgTypes.msgSurveyQuery)
+			c.RegisterProcessor(newDrynxInstance, msgTypes.msgDPdataFinished)
+			c.RegisterProcessor(newDrynxInstance, msgTypes.msgCancelSurvey)
+		}
+
+		if b.dataProvider != nil {
+			registerHandler(newDrynxInstance.HandleSurveyQueryToDP)
+			registerHandler(newDrynxInstance.HandleGetDatasets)
+			c.RegisterProcessor(newDrynxInstance, msgTypes.msgSurveyQueryToDP)
+			c.RegisterProcessor(newDrynxInstance, msgTypes.msgCancelSurvey)
+		}
+
+		if b.verifyingNode {
+			registerHandler(newDrynxInstance.HandleSurveyQueryToVN)
+			registerHandler(newDrynxInstance.HandleEndVerification)
+			registerHandler(newDrynxInstance.HandleGetLatestBlock)
+			registerHandler(newDrynxInstance.HandleGetGenesis)
+			registerHandler(newDrynxInstance.HandleGetBlock)
+			registerHandler(newDrynxInstance.HandleGetProofs)
+			registerHandler(newDrynxInstance.HandleCloseDB)
+
+			//Register new verifFunction
+			if err := skipchain.RegisterVerification(c, VerifyBitmap, newDrynxInstance.verifyFuncBitmap); err != nil {
+				return nil, err
+			}
 		}
 
 		return newDrynxInstance, nil
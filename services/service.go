@@ -6,6 +6,9 @@ import (
 	"github.com/coreos/bbolt"
 	"github.com/fanliao/go-concurrentMap"
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/authorization"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/metrics"
 	"github.com/ldsec/drynx/lib/proof"
 	"github.com/ldsec/drynx/lib/provider"
 	"github.com/ldsec/drynx/protocols"
@@ -18,10 +21,10 @@ import (
 	"github.com/ldsec/unlynx/protocols"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/util/random"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
+	"math"
 	"sync"
 	"time"
 )
@@ -29,8 +32,6 @@ import (
 // ServiceName is the registered name for the drynx service.
 const ServiceName = "drynx"
 
-const gobFile = "pre_compute_multiplications.gob"
-
 // Survey represents a survey with the corresponding params
 type Survey struct {
 	SurveyQuery        libdrynx.SurveyQuery
@@ -38,21 +39,23 @@ type Survey struct {
 	Noises             libunlynx.CipherVector
 	ShufflePrecompute  []libunlynxshuffle.CipherVectorScalar
 	MapPIs             map[string]onet.ProtocolInstance
+	DatasetVersions    map[string]string // DP -> version of the dataset it used
+	KeySwitchers       []string          // CNs which switched the result, under a threshold key
+	Refusal            string            // why the survey was refused, at a data provider
 
 	// mutex
 	Mutex *sync.Mutex
 }
 
-func castToSurvey(object interface{}, err error) Survey {
+func castToSurvey(object interface{}, err error) (Survey, error) {
 	if err != nil {
-		log.Fatalf("[SERVICE] <drynx> Server, Error reading map, %+v", err)
+		return Survey{}, fmt.Errorf("error reading map: %v", err)
 	}
 	survey, ok := object.(Survey)
 	if !ok {
-		err := fmt.Errorf("unable to cast to Survey, is %#v", object)
-		log.Fatalf("[SERVICE] <drynx> Server, %+v", err)
+		return Survey{}, fmt.Errorf("unable to cast to Survey, is %#v", object)
 	}
-	return survey
+	return survey, nil
 }
 
 // DPqueryReceived is used to ensure that all DPs have received the query and can proceed with the data collection protocol
@@ -68,19 +71,35 @@ type SyncDCP struct {
 // DPdataFinished is used to ensure that all servers have received the data and can proceed with the collective aggregation
 type DPdataFinished struct {
 	SurveyID string
+	DPs      []string // data providers whose responses were collected
+	Error    string   // set if the data collection failed
 }
 
 // ServiceDrynx defines a service in drynx with a survey.
 type ServiceDrynx struct {
 	*onet.ServiceProcessor
 
+	// roles taken by the node, as advertised to the queriers
+	roles libdrynx.Roles
+	// to report the uptime
+	started time.Time
+	// to stop or synchronize the running surveys
+	controls *surveyControls
+	// queriers allowed to run surveys, anyone if nil
+	queriers *authorization.Policy
+	// precomputations for shuffling, cached in the data directory
+	shuffles *shufflePrecomputations
+	// shares of the collective keys generated with the other computing nodes, kept in the data directory
+	keyShares *keyShares
+
 	// ---- Computing Nodes ----
-	Survey *concurrent.ConcurrentMap
+	Survey    *concurrent.ConcurrentMap
+	submitted *submittedSurveys
 	// -------------------------
 
 	// ---- Data Provider ----
-	loader      provider.Loader
-	neutralizer provider.Neutralizer
+	datasets   map[string]dataset
+	accountant provider.Accountant
 	// -------------------------
 
 	// ---- Verifying Nodes ----
@@ -107,6 +126,7 @@ type MsgTypes struct {
 	msgDPqueryReceived network.MessageTypeID
 	msgSyncDCP         network.MessageTypeID
 	msgDPdataFinished  network.MessageTypeID
+	msgCancelSurvey    network.MessageTypeID
 }
 
 var msgTypes = MsgTypes{}
@@ -115,21 +135,55 @@ var msgTypes = MsgTypes{}
 func (s *ServiceDrynx) Process(msg *network.Envelope) {
 	if msg.MsgType.Equal(msgTypes.msgSurveyQuery) {
 		tmp := (msg.Msg).(*libdrynx.SurveyQuery)
-		_, err := s.HandleSurveyQuery(tmp)
-		log.ErrFatal(err)
+		// failures are reported to the entry server
+		s.HandleSurveyQuery(tmp)
 	} else if msg.MsgType.Equal(msgTypes.msgSurveyQueryToDP) {
 		tmp := (msg.Msg).(*libdrynx.SurveyQueryToDP)
-		_, err := s.HandleSurveyQueryToDP(tmp)
-		log.ErrFatal(err)
+		if _, err := s.HandleSurveyQueryToDP(tmp); err != nil {
+			log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "survey", tmp.SQ.SurveyID, "failed:", err)
+		}
+	} else if msg.MsgType.Equal(msgTypes.msgDPdataFinished) {
+		finished := (msg.Msg).(*DPdataFinished)
+		if s.controls.report(msg.ServerIdentity, finished) {
+			s.submitted.update(finished.SurveyID, func(survey *submittedSurvey) {
+				survey.nbrDPsAnswered += len(finished.DPs)
+			})
+		}
+	} else if msg.MsgType.Equal(msgTypes.msgCancelSurvey) {
+		tmp := (msg.Msg).(*libdrynx.CancelSurvey)
+		if err := s.checkCancel(tmp, msg.ServerIdentity); err != nil {
+			log.Warn("[SERVICE] <drynx> Server", s.ServerIdentity(), "ignoring cancellation of survey", tmp.SurveyID, "from", msg.ServerIdentity, ":", err)
+			return
+		}
+		log.Lvl1("[SERVICE] <drynx> Server", s.ServerIdentity(), "survey", tmp.SurveyID, "cancelled by", msg.ServerIdentity)
+		s.controls.cancel(tmp.SurveyID)
 	} else {
 		log.Warnf("unprocessed message: %#v", msg)
 	}
 }
 
-func (s *ServiceDrynx) waitForSurvey(id string) Survey {
+// authorize checks that the querier may run the survey, if the queriers are restricted
+func (s *ServiceDrynx) authorize(sq libdrynx.SurveyQuery) error {
+	if s.queriers == nil {
+		return nil
+	}
+	if err := s.queriers.Authorize(sq); err != nil {
+		return fmt.Errorf("unauthorized survey: %v", err)
+	}
+	return nil
+}
+
+func (s *ServiceDrynx) waitForSurvey(id string) (Survey, error) {
+	deadline := time.Now().Add(surveyWaitTimeout)
 	for {
 		if obj, _ := s.Survey.Get(id); obj != nil {
-			return obj.(Survey)
+			return obj.(Survey), nil
+		}
+		if err := s.controls.stopped(id); err != nil {
+			return Survey{}, err
+		}
+		if time.Now().After(deadline) {
+			return Survey{}, fmt.Errorf("survey %q not received in %v", id, surveyWaitTimeout)
 		}
 
 		time.Sleep(time.Millisecond * 100)
@@ -140,7 +194,56 @@ func (s *ServiceDrynx) waitForSurvey(id string) Survey {
 //______________________________________________________________________________________________________________________
 
 // HandleSurveyQuery handles the reception of a survey creation query by instantiating the corresponding survey.
+// If the survey fails, only this survey is stopped, at all its nodes, and the error is returned to the querier.
 func (s *ServiceDrynx) HandleSurveyQuery(recq *libdrynx.SurveyQuery) (network.Message, error) {
+	s.collectSurveys()
+
+	if err := s.controls.start(recq.SurveyID, roleComputingNode, !recq.IntraMessage); err != nil {
+		if !recq.IntraMessage {
+			// the survey already known under this identifier is left untouched
+			return nil, err
+		}
+		return nil, s.failSurvey(recq, err)
+	}
+
+	reply, err := s.runSurvey(recq)
+	if !recq.IntraMessage {
+		outcome := stateDone
+		if err != nil {
+			outcome = stateFailed
+		}
+		metrics.Surveys.Inc(recq.Query.Operation.NameOp, outcome)
+	}
+	switch {
+	case err != nil:
+		s.controls.finish(recq.SurveyID, roleComputingNode, err)
+		return nil, s.failSurvey(recq, err)
+	case recq.IntraMessage:
+		// until the end of the key switching started by the entry server
+		s.controls.set(recq.SurveyID, roleComputingNode, stateAggregating)
+	default:
+		s.controls.finish(recq.SurveyID, roleComputingNode, nil)
+	}
+	return reply, nil
+}
+
+// failSurvey reports the failure of the survey, to the entry server which stops it at all its nodes
+func (s *ServiceDrynx) failSurvey(recq *libdrynx.SurveyQuery, err error) error {
+	log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "survey", recq.SurveyID, "failed:", err)
+	if recq.IntraMessage {
+		// the entry server, root of the aggregation tree, fails the survey for the querier
+		finished := &DPdataFinished{SurveyID: recq.SurveyID, Error: err.Error()}
+		if err := s.SendRaw(recq.RosterServers.List[0], finished); err != nil {
+			log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "unable to report the failure of survey", recq.SurveyID, ":", err)
+		}
+	} else {
+		s.cancelAtNodes(recq, &libdrynx.CancelSurvey{SurveyID: recq.SurveyID})
+	}
+	return fmt.Errorf("survey %v: %v", recq.SurveyID, err)
+}
+
+// runSurvey runs the survey as a computing node, returning the result at the entry one
+func (s *ServiceDrynx) runSurvey(recq *libdrynx.SurveyQuery) (network.Message, error) {
 	prefixWithID := func(args []interface{}) []interface{} {
 		arr := make([]interface{}, len(args)+2)
 		arr[0] = "[SERVICE] <drynx> Server"
@@ -151,17 +254,20 @@ func (s *ServiceDrynx) HandleSurveyQuery(recq *libdrynx.SurveyQuery) (network.Me
 	info := func(args ...interface{}) {
 		log.Info(prefixWithID(args)...)
 	}
-	die := func(args ...interface{}) {
-		log.Fatal(prefixWithID(args)...)
-	}
 
 	info("received a [SurveyQuery]")
 
-	// get the total number DPs
-	nbrDPs := 0
-	for _, v := range recq.ServerToDP {
-		if v != nil {
-			nbrDPs += len(v.Content)
+	if err := s.authorize(*recq); err != nil {
+		return nil, err
+	}
+	// the noise values and the proofs of the key switching are bound to the aggregate key of the computing nodes
+	if recq.ThresholdKey != nil && (recq.Query.Proofs != 0 || libdrynx.AddDiffP(recq.Query.DiffP)) {
+		return nil, errors.New("a threshold key can't be used with proofs or differential privacy")
+	}
+	// the data providers encrypt their responses under it, it has to be one the computing nodes share
+	if recq.ThresholdKey != nil {
+		if _, err := s.keyShares.get(recq.ThresholdKey); err != nil {
+			return nil, fmt.Errorf("threshold key not generated by the computing nodes: %v", err)
 		}
 	}
 
@@ -175,31 +281,36 @@ func (s *ServiceDrynx) HandleSurveyQuery(recq *libdrynx.SurveyQuery) (network.Me
 		}
 	}
 
+	// prepares the precomputation for shuffling the noise values, only done with differential privacy
+	var shufflePrecompute []libunlynxshuffle.CipherVectorScalar
+	if libdrynx.AddDiffP(recq.Query.DiffP) {
+		var err error
+		shufflePrecompute, err = s.shufflePrecomputation(recq)
+		if err != nil {
+			return nil, fmt.Errorf("shuffle precomputation: %v", err)
+		}
+	}
+
 	// survey instantiation
 	_, err := s.Survey.Put(recq.SurveyID, Survey{
-		SurveyQuery: *recq,
-		MapPIs:      mapPIs,
+		SurveyQuery:       *recq,
+		MapPIs:            mapPIs,
+		ShufflePrecompute: shufflePrecompute,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	survey := castToSurvey(s.Survey.Get(recq.SurveyID))
-
-	// prepares the precomputation for shuffling
-	lineSize := 100 // + 1 is for the possible count attribute
-	survey.ShufflePrecompute, _ = libunlynxshuffle.PrecomputationWritingForShuffling(false, gobFile, s.ServerIdentity().String(), libunlynx.SuiTe.Scalar().Pick(random.New()), recq.RosterServers.Aggregate, lineSize)
-
 	// if is the root server: send query to all other servers and its data providers
 	if recq.IntraMessage == false {
 		info("broadcasting [SurveyQuery] to CNs ")
 		recq.IntraMessage = true
 		// to other computing servers
 		err = libunlynxtools.SendISMOthers(s.ServiceProcessor, &recq.RosterServers, recq)
+		recq.IntraMessage = false
 		if err != nil {
-			die("broadcasting [SurveyQuery] to CNs error", err)
+			return nil, fmt.Errorf("broadcasting [SurveyQuery] to CNs: %v", err)
 		}
-		recq.IntraMessage = false
 	}
 
 	// to the DPs
@@ -215,18 +326,19 @@ func (s *ServiceDrynx) HandleSurveyQuery(recq *libdrynx.SurveyQuery) (network.Me
 		info("broadcasting [SurveyQuery] to DPs")
 		surveyToDPs := libdrynx.SurveyQueryToDP{SQ: *recq, Root: s.ServerIdentity()}
 		if err := libunlynxtools.SendISMOthers(s.ServiceProcessor, listDPs, &surveyToDPs); err != nil {
-			die("broadcasting [SurveyQuery] to DPs error", err)
+			return nil, fmt.Errorf("broadcasting [SurveyQuery] to DPs: %v", err)
 		}
 	}
 
 	// DRO Phase
 	if recq.IntraMessage == false {
+		surveyID, diffP := recq.SurveyID, recq.Query.DiffP
 		go func() {
 			//diffPTimer := libDrynx.StartTimer(s.ServerIdentity().String() + "_DiffPPhase")
-			if libdrynx.AddDiffP(castToSurvey(s.Survey.Get(recq.SurveyID)).SurveyQuery.Query.DiffP) {
+			if libdrynx.AddDiffP(diffP) {
 				info("starting differential privacy proto")
-				if err := s.DROPhase(castToSurvey(s.Survey.Get(recq.SurveyID)).SurveyQuery.SurveyID); err != nil {
-					die("differential privacy error", err)
+				if err := s.DROPhase(surveyID); err != nil {
+					s.controls.stop(surveyID, fmt.Errorf("differential privacy: %v", err))
 				}
 			}
 			//libDrynx.EndTimer(diffPTimer)
@@ -234,43 +346,76 @@ func (s *ServiceDrynx) HandleSurveyQuery(recq *libdrynx.SurveyQuery) (network.Me
 	}
 
 	startDataCollectionProtocol := libunlynx.StartTimer(s.ServerIdentity().String() + "_DataCollectionProtocol")
+	endDataCollection := metrics.Phase("data_collection")
+	var contributors []string
 	if listDPs != nil {
 		info("starting data collection phase")
+		s.setPhase(recq.SurveyID, "collection")
+		s.controls.set(recq.SurveyID, roleComputingNode, stateCollecting)
 		// servers contact their DPs to get their response
-		if err := s.DataCollectionPhase(recq.SurveyID); err != nil {
-			die("data collection error", err)
+		contributors, err = s.DataCollectionPhase(recq.SurveyID)
+		if err != nil {
+			err = fmt.Errorf("data collection: %v", err)
 		}
 		libunlynx.EndTimer(startDataCollectionProtocol)
+		endDataCollection()
 	}
 
-	// ready to start the collective aggregation & key switching protocol
-	if recq.IntraMessage == false {
-		startJustExecution := libunlynx.StartTimer("JustExecution")
-		if err := s.StartService(recq.SurveyID); err != nil {
-			return nil, err
+	// tell the entry server, the root of the aggregation tree, that the data were collected
+	if recq.IntraMessage {
+		finished := &DPdataFinished{SurveyID: recq.SurveyID, DPs: contributors}
+		if err != nil {
+			info("data collection error", err)
+			finished.Error = err.Error()
 		}
+		return nil, s.SendRaw(recq.RosterServers.List[0], finished)
+	}
 
-		info("completed the query processing...")
+	// ready to start the collective aggregation & key switching protocol
+	if err != nil {
+		return nil, err
+	}
+	contributors, err = s.waitForCNs(recq, contributors)
+	if err != nil {
+		return nil, err
+	}
+	s.controls.set(recq.SurveyID, roleComputingNode, stateAggregating)
 
-		survey := castToSurvey(s.Survey.Get(recq.SurveyID))
-		result := survey.QueryResponseState
-		libunlynx.EndTimer(startJustExecution)
+	startJustExecution := libunlynx.StartTimer("JustExecution")
+	endExecution := metrics.Phase("execution")
+	if err := s.StartService(recq.SurveyID); err != nil {
+		return nil, err
+	}
 
-		ret := make(map[string]*libdrynx.CipherVector)
-		for _, group := range result.Data {
-			vec := make([]*libdrynx.CipherText, len(group.Data))
+	info("completed the query processing...")
 
-			for j, e := range group.Data {
-				vec[j] = &libdrynx.CipherText{K: e.K, C: e.C}
-			}
+	survey, err := castToSurvey(s.Survey.Get(recq.SurveyID))
+	if err != nil {
+		return nil, err
+	}
+	result := survey.QueryResponseState
+	libunlynx.EndTimer(startJustExecution)
+	endExecution()
+
+	ret := make(map[string]*libdrynx.CipherVector)
+	for _, group := range result.Data {
+		vec := make([]*libdrynx.CipherText, len(group.Data))
 
-			ret[group.Group] = &libdrynx.CipherVector{Content: vec}
+		for j, e := range group.Data {
+			vec[j] = &libdrynx.CipherText{K: e.K, C: e.C}
 		}
 
-		return &libdrynx.ResponseDP{Data: ret}, nil
+		ret[group.Group] = &libdrynx.CipherVector{Content: vec}
 	}
 
-	return nil, nil
+	response := &libdrynx.ResponseDP{Data: ret, DPs: contributors, KeySwitchers: survey.KeySwitchers}
+	if libdrynx.AddLocalDiffP(recq.Query.LocalDiffP) {
+		// only the DPs which answered perturbed the result
+		calibration := libdrynxencoding.NewLocalDiffPCalibration(recq.Query.LocalDiffP, len(contributors))
+		response.LocalDiffP = &calibration
+	}
+
+	return response, nil
 }
 
 // Protocol Handlers
@@ -294,25 +439,65 @@ func (s *ServiceDrynx) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.Generic
 		}
 
 		dcp := pi.(*protocols.DataCollectionProtocol)
-		dcp.Loader = s.loader
-		dcp.Neutralizer = s.neutralizer
+		dcp.Accountant = s.accountant
 
-		if !tn.IsRoot() {
-			survey := s.waitForSurvey(target)
+		dcp.Cancelled = s.controls.get(target).cancelled
+
+		if tn.IsRoot() {
+			dcp.OnResponse = func(*network.ServerIdentity) {
+				s.submitted.update(target, func(survey *submittedSurvey) {
+					survey.nbrDPsAnswered++
+				})
+			}
+
+			survey, err := castToSurvey(s.Survey.Get(target))
+			if err != nil {
+				return nil, err
+			}
+			sq := survey.SurveyQuery
+			nbrDPs := len(tn.Tree().List()) - 1
+			dcp.Timeout = time.Duration(sq.Timeout) * time.Second
+			dcp.Quorum = nbrDPs
+			if sq.DPsQuorum > 0 {
+				dcp.Quorum = int(math.Ceil(sq.DPsQuorum * float64(nbrDPs)))
+			}
+		} else {
+			survey, err := s.waitForSurvey(target)
+			if err != nil {
+				return nil, err
+			}
+			if survey.Refusal != "" {
+				dcp.Survey = protocols.SurveyToDP{SurveyID: survey.SurveyQuery.SurveyID}
+				dcp.Refusal = errors.New(survey.Refusal)
+				return dcp, nil
+			}
+
+			dataset := s.getDataset(survey.SurveyQuery.Query.Dataset)
+			dcp.Loader = dataset.loader
+			dcp.Neutralizer = dataset.neutralizer
 
 			queryStatement := protocols.SurveyToDP{
-				SurveyID:  survey.SurveyQuery.SurveyID,
-				Aggregate: survey.SurveyQuery.RosterServers.Aggregate,
-				Query:     survey.SurveyQuery.Query,
+				SurveyID:     survey.SurveyQuery.SurveyID,
+				Aggregate:    survey.SurveyQuery.CollectiveKey(),
+				ClientPubKey: survey.SurveyQuery.ClientPubKey,
+				Query:        survey.SurveyQuery.Query,
 			}
 			dcp.Survey = queryStatement
 			dcp.MapPIs = survey.MapPIs
+
+			tn.OnDoneCallback(func() bool {
+				s.controls.finish(target, roleDataProvider, s.controls.stopped(target))
+				return true
+			})
 		}
 
 		return dcp, nil
 
 	case protocolsunlynx.CollectiveAggregationProtocolName:
-		survey := s.waitForSurvey(target)
+		survey, err := s.waitForSurvey(target)
+		if err != nil {
+			return nil, err
+		}
 		pi, err := s.NewCollectiveAggregationProtocol(tn, survey)
 		if err != nil {
 			return nil, err
@@ -321,7 +506,10 @@ func (s *ServiceDrynx) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.Generic
 		return pi, nil
 
 	case protocols.ObfuscationProtocolName:
-		survey := castToSurvey(s.Survey.Get(target))
+		survey, err := castToSurvey(s.Survey.Get(target))
+		if err != nil {
+			return nil, err
+		}
 		pi, err := protocols.NewObfuscationProtocol(tn)
 		if err != nil {
 			return nil, err
@@ -337,7 +525,10 @@ func (s *ServiceDrynx) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.Generic
 		return pi, nil
 
 	case protocolsunlynx.DROProtocolName:
-		survey := castToSurvey(s.Survey.Get(target))
+		survey, err := castToSurvey(s.Survey.Get(target))
+		if err != nil {
+			return nil, err
+		}
 		log.Lvl2("SERVICE] <drynx> Server", s.ServerIdentity(), " Servers collectively add noise for differential privacy")
 		pi, err := s.NewShufflingProtocol(tn, survey)
 		if err != nil {
@@ -347,12 +538,52 @@ func (s *ServiceDrynx) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.Generic
 		return pi, nil
 
 	case protocolsunlynx.KeySwitchingProtocolName:
-		survey := castToSurvey(s.Survey.Get(target))
+		survey, err := castToSurvey(s.Survey.Get(target))
+		if err != nil {
+			return nil, err
+		}
 		pi, err := s.NewKeySwitchingProtocol(tn, survey)
 		if err != nil {
 			return nil, err
 		}
 
+		// the last protocol of the survey, the entry server finishes it on replying to the querier
+		if !tn.IsRoot() {
+			tn.OnDoneCallback(func() bool {
+				s.controls.finish(target, roleComputingNode, s.controls.stopped(target))
+				return true
+			})
+		}
+
+		return pi, nil
+
+	case protocols.ThresholdKeySwitchingProtocolName:
+		survey, err := castToSurvey(s.Survey.Get(target))
+		if err != nil {
+			return nil, err
+		}
+		pi, err := s.NewThresholdKeySwitchingProtocol(tn, survey)
+		if err != nil {
+			return nil, err
+		}
+
+		// the last protocol of the survey, the entry server finishes it on replying to the querier
+		if !tn.IsRoot() {
+			tn.OnDoneCallback(func() bool {
+				s.controls.finish(target, roleComputingNode, s.controls.stopped(target))
+				return true
+			})
+		}
+
+		return pi, nil
+
+	case protocols.DKGProtocolName:
+		pi, err := protocols.NewDKGProtocol(tn)
+		if err != nil {
+			return nil, err
+		}
+		pi.(*protocols.DKGProtocol).OnShare = s.keyShares.put
+
 		return pi, nil
 
 	default:
@@ -393,17 +624,9 @@ func (s *ServiceDrynx) NewCollectiveAggregationProtocol(tn *onet.TreeNodeInstanc
 			pi := survey.MapPIs["aggregation/"+s.ServerIdentity().String()]
 			pi.(*protocols.ProofCollectionProtocol).Proof = drynxproof.ProofRequest{AggregationProof: drynxproof.NewAggregationProofRequest(&aggrLocalProof, survey.SurveyQuery.SurveyID, s.ServerIdentity().String(), "", survey.SurveyQuery.Query.RosterVNs, tn.Private(), nil)}
 
-			go func() {
-				if err := pi.Dispatch(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			go func() {
-				if err := pi.Start(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			<-pi.(*protocols.ProofCollectionProtocol).FeedbackChannel
+			if err := protocols.RunProofCollection(pi); err != nil {
+				s.controls.stop(survey.SurveyQuery.SurveyID, fmt.Errorf("unable to send the aggregation proof: %v", err))
+			}
 		}()
 		return nil
 	}
@@ -430,17 +653,9 @@ func (s *ServiceDrynx) NewKeySwitchingProtocol(tn *onet.TreeNodeInstance, survey
 			proof, _ := libunlynxkeyswitch.KeySwitchListProofCreation(pubKey, targetPubKey, secretKey, ks2s, rBNegs, vis)
 			pcp := keySwitch.MapPIs["keyswitch/"+keySwitch.ServerIdentity().String()]
 			pcp.(*protocols.ProofCollectionProtocol).Proof = drynxproof.ProofRequest{KeySwitchProof: drynxproof.NewKeySwitchProofRequest(&proof, survey.SurveyQuery.SurveyID, keySwitch.ServerIdentity().String(), "", survey.SurveyQuery.Query.RosterVNs, keySwitch.Private(), nil)}
-			go func() {
-				if err := pcp.Dispatch(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			go func() {
-				if err := pcp.Start(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			<-pcp.(*protocols.ProofCollectionProtocol).FeedbackChannel
+			if err := protocols.RunProofCollection(pcp); err != nil {
+				s.controls.stop(survey.SurveyQuery.SurveyID, fmt.Errorf("unable to send the key switching proof: %v", err))
+			}
 		}()
 		return nil
 	}
@@ -477,23 +692,21 @@ func (s *ServiceDrynx) NewShufflingProtocol(tn *onet.TreeNodeInstance, survey Su
 		shuffle.Proofs = false
 	}
 	shuffle.Precomputed = survey.ShufflePrecompute
+	if shuffle.Precomputed == nil {
+		// the survey was received in another role, replacing the computing node's one
+		if shuffle.Precomputed, err = s.shufflePrecomputation(&survey.SurveyQuery); err != nil {
+			return nil, err
+		}
+	}
 	shuffle.MapPIs = survey.MapPIs
 	shuffle.ProofFunc = func(shuffleTarget, shuffledData []libunlynx.CipherVector, collectiveKey kyber.Point, beta [][]kyber.Scalar, pi []int) *libunlynxshuffle.PublishedShufflingProof {
 		go func() {
 			proof, _ := libunlynxshuffle.ShuffleProofCreation(shuffleTarget, shuffledData, libunlynx.SuiTe.Point().Base(), collectiveKey, beta, pi)
 			pcp := shuffle.MapPIs["shuffle/"+shuffle.ServerIdentity().String()]
 			pcp.(*protocols.ProofCollectionProtocol).Proof = drynxproof.ProofRequest{ShuffleProof: drynxproof.NewShuffleProofRequest(&proof, survey.SurveyQuery.SurveyID, shuffle.ServerIdentity().String(), "", survey.SurveyQuery.Query.RosterVNs, shuffle.Private(), nil)}
-			go func() {
-				if err := pcp.Dispatch(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			go func() {
-				if err := pcp.Start(); err != nil {
-					log.Fatal(err)
-				}
-			}()
-			<-pcp.(*protocols.ProofCollectionProtocol).FeedbackChannel
+			if err := protocols.RunProofCollection(pcp); err != nil {
+				s.controls.stop(survey.SurveyQuery.SurveyID, fmt.Errorf("unable to send the shuffling proof: %v", err))
+			}
 		}()
 		return nil
 	}
@@ -516,7 +729,10 @@ func (s *ServiceDrynx) NewShufflingProtocol(tn *onet.TreeNodeInstance, survey Su
 // StartProtocol starts a specific protocol
 func (s *ServiceDrynx) StartProtocol(name string, targetSurvey string) (onet.ProtocolInstance, error) {
 	// this generates the PIs of proof collection to be run inside the protocols
-	tmp := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	tmp, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return nil, err
+	}
 
 	var tree *onet.Tree
 	if name == protocols.DataCollectionProtocolName {
@@ -525,6 +741,9 @@ func (s *ServiceDrynx) StartProtocol(name string, targetSurvey string) (onet.Pro
 			CNsToDPs[cn] = &dps.Content
 		}
 		tree = generateDataCollectionRoster(s.ServerIdentity(), CNsToDPs).GenerateStar()
+	} else if name == protocols.ThresholdKeySwitchingProtocolName {
+		// the root gets the contributions of the computing nodes which are up
+		tree = tmp.SurveyQuery.RosterServers.GenerateStar()
 	} else {
 		tree = tmp.SurveyQuery.RosterServers.GenerateBinaryTree()
 	}
@@ -542,7 +761,7 @@ func (s *ServiceDrynx) StartProtocol(name string, targetSurvey string) (onet.Pro
 
 	pi, err := s.NewProtocol(tn, &conf)
 	if err != nil {
-		log.Fatal("Error running" + name)
+		return nil, fmt.Errorf("error running %v: %v", name, err)
 	}
 
 	err = s.RegisterProtocolInstance(pi)
@@ -551,7 +770,7 @@ func (s *ServiceDrynx) StartProtocol(name string, targetSurvey string) (onet.Pro
 	}
 	go func() {
 		if err := pi.Dispatch(); err != nil {
-			log.Fatal(err)
+			log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "error running", name, "for survey", targetSurvey, ":", err)
 		}
 	}()
 
@@ -569,45 +788,84 @@ func (s *ServiceDrynx) StartProtocol(name string, targetSurvey string) (onet.Pro
 func (s *ServiceDrynx) StartService(targetSurvey string) error {
 	log.Lvl2("[SERVICE] <drynx> Server", s.ServerIdentity(), " starts a collective aggregation, (differential privacy) & key switching for survey ", targetSurvey)
 
-	target := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	target, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return err
+	}
 
 	// Aggregation Phase
+	s.setPhase(targetSurvey, "aggregation")
 	aggregationTimer := libunlynx.StartTimer(s.ServerIdentity().String() + "_AggregationPhase")
-	err := s.AggregationPhase(target.SurveyQuery.SurveyID)
+	endAggregation := metrics.Phase("aggregation")
+	err = s.AggregationPhase(target.SurveyQuery.SurveyID)
 	if err != nil {
 		return fmt.Errorf("Aggregation Phase: %v", err)
 	}
 	libunlynx.EndTimer(aggregationTimer)
+	endAggregation()
+
+	if err := s.controls.stopped(targetSurvey); err != nil {
+		return err
+	}
 
 	if target.SurveyQuery.Query.Obfuscation {
+		s.setPhase(targetSurvey, "obfuscation")
 		//obfuscationTimer := libDrynx.StartTimer(s.ServerIdentity().String() + "_ObfuscationPhase")
+		endObfuscation := metrics.Phase("obfuscation")
 		err := s.ObfuscationPhase(target.SurveyQuery.SurveyID)
 		if err != nil {
 			return fmt.Errorf("Obfuscation Phase: %v", err)
 		}
 		//libDrynx.EndTimer(obfuscationTimer)
+		endObfuscation()
+	}
+
+	if err := s.controls.stopped(targetSurvey); err != nil {
+		return err
 	}
 
 	// Key Switch Phase
+	s.setPhase(targetSurvey, "key switching")
 	keySwitchTimer := libunlynx.StartTimer(s.ServerIdentity().String() + "_KeySwitchingPhase")
-	err = s.KeySwitchingPhase(target.SurveyQuery.SurveyID)
+	endKeySwitching := metrics.Phase("key_switching")
+	if target.SurveyQuery.ThresholdKey != nil {
+		err = s.ThresholdKeySwitchingPhase(target.SurveyQuery.SurveyID)
+	} else {
+		err = s.KeySwitchingPhase(target.SurveyQuery.SurveyID)
+	}
 	if err != nil {
 		return fmt.Errorf("Key Switching Phase: %v", err)
 	}
 	libunlynx.EndTimer(keySwitchTimer)
+	endKeySwitching()
 
 	return nil
 }
 
-// DataCollectionPhase is the phase where data are collected from DPs
-func (s *ServiceDrynx) DataCollectionPhase(targetSurvey string) error {
+// DataCollectionPhase is the phase where data are collected from DPs, returning the DPs which answered
+func (s *ServiceDrynx) DataCollectionPhase(targetSurvey string) ([]string, error) {
 	pi, err := s.StartProtocol(protocols.DataCollectionProtocolName, targetSurvey)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	dcp := pi.(*protocols.DataCollectionProtocol)
+	dataDPs := <-dcp.FeedbackChannel
+	if dcp.Err != nil {
+		// report why the survey was stopped rather than only that it was
+		if err := s.controls.stopped(targetSurvey); err != nil {
+			return nil, err
+		}
+		return nil, dcp.Err
 	}
-	dataDPs := <-pi.(*protocols.DataCollectionProtocol).FeedbackChannel
 
-	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	survey, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return nil, err
+	}
+	survey.DatasetVersions = dcp.DatasetVersions
+	for dp, version := range dcp.DatasetVersions {
+		log.Lvl1("[SERVICE] <drynx> Server", s.ServerIdentity(), "survey", targetSurvey, "used version", version, "of the dataset of", dp)
+	}
 	// we convert the map into an object of [Group + CipherVector] to avoid later problems with protobuf
 	for key, value := range dataDPs {
 		if survey.SurveyQuery.Query.CuttingFactor != 0 {
@@ -619,9 +877,9 @@ func (s *ServiceDrynx) DataCollectionPhase(targetSurvey string) error {
 	}
 	_, err = s.Survey.Put(string(targetSurvey), survey)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return dcp.Contributors, nil
 }
 
 // AggregationPhase performs the per-group aggregation on the currently grouped data.
@@ -632,7 +890,10 @@ func (s *ServiceDrynx) AggregationPhase(targetSurvey string) error {
 	}
 	cothorityAggregatedData := <-pi.(*protocolsunlynx.CollectiveAggregationProtocol).FeedbackChannel
 
-	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	survey, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return err
+	}
 
 	survey.QueryResponseState = *libdrynx.ConvertFromAggregationStruct(cothorityAggregatedData)
 	_, err = s.Survey.Put(string(targetSurvey), survey)
@@ -650,7 +911,10 @@ func (s *ServiceDrynx) ObfuscationPhase(targetSurvey string) error {
 	}
 	obfuscationData := <-pi.(*protocols.ObfuscationProtocol).FeedbackChannel
 
-	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	survey, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return err
+	}
 	survey.QueryResponseState = *convertFromKeySwitchingStruct(obfuscationData, survey.QueryResponseState)
 	_, err = s.Survey.Put(string(targetSurvey), survey)
 	if err != nil {
@@ -668,7 +932,10 @@ func (s *ServiceDrynx) DROPhase(targetSurvey string) error {
 
 	shufflingResult := <-pi.(*protocolsunlynx.ShufflingProtocol).FeedbackChannel
 
-	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	survey, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return err
+	}
 	noises := *libunlynx.NewCipherVector(len(shufflingResult))
 	for i, v := range shufflingResult {
 		noises[i] = v[0]
@@ -688,7 +955,10 @@ func (s *ServiceDrynx) DROLocalPhase(targetSurvey string) error {
 		return err
 	}
 	shufflingResult := <-pi.(*protocolsunlynx.ShufflingProtocol).FeedbackChannel
-	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	survey, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return err
+	}
 	noises := *libunlynx.NewCipherVector(len(shufflingResult))
 	for i, v := range shufflingResult {
 		noises[i] = v[0]
@@ -709,7 +979,10 @@ func (s *ServiceDrynx) KeySwitchingPhase(targetSurvey string) error {
 	}
 	keySwitchedAggregatedResponses := <-pi.(*protocolsunlynx.KeySwitchingProtocol).FeedbackChannel
 
-	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	survey, err := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if err != nil {
+		return err
+	}
 	survey.QueryResponseState = *convertFromKeySwitchingStruct(keySwitchedAggregatedResponses, survey.QueryResponseState)
 	_, err = s.Survey.Put(targetSurvey, survey)
 	if err != nil {
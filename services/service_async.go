@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ldsec/drynx/lib"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// DefaultResultsRetention is how long a computing node keeps the results of submitted surveys, if not configured
+const DefaultResultsRetention = time.Hour
+
+// submittedSurvey is the progress of a survey submitted to this computing node
+type submittedSurvey struct {
+	phase          string
+	nbrDPs         int
+	nbrDPsAnswered int
+
+	response *libdrynx.ResponseDP
+	err      error
+	finished time.Time
+}
+
+// submittedSurveys are the surveys submitted to a computing node, kept until their retention time
+type submittedSurveys struct {
+	sync.Mutex
+	retention time.Duration
+	surveys   map[string]*submittedSurvey
+}
+
+func newSubmittedSurveys(retention time.Duration) *submittedSurveys {
+	return &submittedSurveys{retention: retention, surveys: make(map[string]*submittedSurvey)}
+}
+
+// update changes the progress of the survey, if it was submitted
+func (ss *submittedSurveys) update(surveyID string, act func(*submittedSurvey)) {
+	ss.Lock()
+	defer ss.Unlock()
+
+	if survey, ok := ss.surveys[surveyID]; ok {
+		act(survey)
+	}
+}
+
+// expire forgets the surveys finished for longer than the retention
+func (ss *submittedSurveys) expire() {
+	ss.Lock()
+	defer ss.Unlock()
+
+	now := time.Now()
+	for id, survey := range ss.surveys {
+		if !survey.finished.IsZero() && now.Sub(survey.finished) > ss.retention {
+			delete(ss.surveys, id)
+		}
+	}
+}
+
+// get returns a copy of the progress of the survey, forgetting the expired ones first
+func (ss *submittedSurveys) get(surveyID string) (submittedSurvey, error) {
+	ss.expire()
+
+	ss.Lock()
+	defer ss.Unlock()
+
+	survey, ok := ss.surveys[surveyID]
+	if !ok {
+		return submittedSurvey{}, fmt.Errorf("unknown survey %q", surveyID)
+	}
+	return *survey, nil
+}
+
+func (s *ServiceDrynx) setPhase(surveyID, phase string) {
+	s.submitted.update(surveyID, func(survey *submittedSurvey) {
+		survey.phase = phase
+	})
+}
+
+// Query Handlers
+//______________________________________________________________________________________________________________________
+
+// HandleSubmitSurveyQuery starts a survey as HandleSurveyQuery does, replying before it finishes
+func (s *ServiceDrynx) HandleSubmitSurveyQuery(recq *libdrynx.SubmitSurveyQuery) (network.Message, error) {
+	sq := recq.SQ
+	if sq.IntraMessage {
+		return nil, errors.New("only a querier can submit a survey")
+	}
+	if err := s.authorize(sq); err != nil {
+		return nil, err
+	}
+
+	// the DPs of the other computing nodes are counted as they report their data collection
+	nbrDPs := 0
+	for _, dps := range sq.ServerToDP {
+		if dps != nil {
+			nbrDPs += len(dps.Content)
+		}
+	}
+
+	s.submitted.Lock()
+	if _, ok := s.submitted.surveys[sq.SurveyID]; ok {
+		s.submitted.Unlock()
+		return nil, fmt.Errorf("survey %q already submitted", sq.SurveyID)
+	}
+	s.submitted.surveys[sq.SurveyID] = &submittedSurvey{phase: "submitted", nbrDPs: nbrDPs}
+	s.submitted.Unlock()
+
+	go func() {
+		reply, err := s.HandleSurveyQuery(&sq)
+
+		response, ok := reply.(*libdrynx.ResponseDP)
+		if err == nil && !ok {
+			err = errors.New("no result")
+		}
+		if err != nil {
+			log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "survey", sq.SurveyID, "failed:", err)
+		}
+
+		s.submitted.update(sq.SurveyID, func(survey *submittedSurvey) {
+			survey.phase = "done"
+			if err != nil {
+				survey.phase = "failed"
+			}
+			survey.response, survey.err, survey.finished = response, err, time.Now()
+		})
+	}()
+
+	return &libdrynx.SurveyHandle{SurveyID: sq.SurveyID}, nil
+}
+
+// HandleGetSurveyStatus reports the progress of a submitted survey
+func (s *ServiceDrynx) HandleGetSurveyStatus(recq *libdrynx.GetSurveyStatus) (network.Message, error) {
+	survey, err := s.submitted.get(recq.SurveyID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &libdrynx.SurveyStatus{
+		SurveyID:       recq.SurveyID,
+		Phase:          survey.phase,
+		NbrDPs:         survey.nbrDPs,
+		NbrDPsAnswered: survey.nbrDPsAnswered,
+	}
+	if survey.err != nil {
+		status.Error = survey.err.Error()
+	}
+	return status, nil
+}
+
+// HandleFetchSurveyResult replies with the key-switched result of a finished survey
+func (s *ServiceDrynx) HandleFetchSurveyResult(recq *libdrynx.FetchSurveyResult) (network.Message, error) {
+	survey, err := s.submitted.get(recq.SurveyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if survey.err != nil {
+		return nil, fmt.Errorf("survey %q failed: %v", recq.SurveyID, survey.err)
+	}
+	if survey.response == nil {
+		return nil, fmt.Errorf("survey %q not finished, in phase %v", recq.SurveyID, survey.phase)
+	}
+	return survey.response, nil
+}
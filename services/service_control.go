@@ -0,0 +1,401 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/protocols"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// surveyWaitTimeout is how long a node waits for a survey it is asked to take part in
+const surveyWaitTimeout = time.Minute
+
+// cnReportGrace is how long the entry computing node waits for the others, after the data providers' timeout
+const cnReportGrace = 10 * time.Second
+
+// surveyMaxAge is how long a node keeps a survey it never saw finishing, as it isn't told the end of all its surveys
+const surveyMaxAge = 24 * time.Hour
+
+// states of a survey at a node, for each of the node's roles
+const (
+	stateCreated     = "created"
+	stateCollecting  = "collecting"
+	stateAggregating = "aggregating"
+	stateDone        = "done"
+	stateFailed      = "failed"
+)
+
+// roles a node takes in a survey
+const (
+	roleComputingNode = "computing-node"
+	roleDataProvider  = "data-provider"
+	roleVerifyingNode = "verifying-node"
+)
+
+// surveyRole is the lifecycle of a survey for one of the node's roles
+type surveyRole struct {
+	state string
+	since time.Time
+	err   error // why the survey failed
+}
+
+func (r surveyRole) finished() bool {
+	return r.state == stateDone || r.state == stateFailed
+}
+
+// surveyControl is how a running survey is stopped or synchronized
+type surveyControl struct {
+	cancelled chan struct{}
+	err       error // why the survey was stopped
+
+	// data collections finished by the other computing nodes, at the entry computing node
+	reports map[string]*DPdataFinished
+	changed chan struct{}
+
+	created time.Time
+	roles   map[string]*surveyRole
+}
+
+// surveyControls are the controls of the surveys a node takes part in, kept until their retention time
+type surveyControls struct {
+	sync.Mutex
+	retention time.Duration
+	controls  map[string]*surveyControl
+}
+
+func newSurveyControls(retention time.Duration) *surveyControls {
+	return &surveyControls{retention: retention, controls: make(map[string]*surveyControl)}
+}
+
+// get returns the control of the survey, created if unknown as messages about a survey can precede the survey itself
+func (sc *surveyControls) get(surveyID string) *surveyControl {
+	sc.Lock()
+	defer sc.Unlock()
+
+	control, ok := sc.controls[surveyID]
+	if !ok {
+		control = &surveyControl{
+			cancelled: make(chan struct{}),
+			reports:   make(map[string]*DPdataFinished),
+			changed:   make(chan struct{}, 1),
+			created:   time.Now(),
+			roles:     make(map[string]*surveyRole),
+		}
+		sc.controls[surveyID] = control
+	}
+	return control
+}
+
+// stop stops the survey for the given reason, if not already done
+func (sc *surveyControls) stop(surveyID string, reason error) {
+	control := sc.get(surveyID)
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	select {
+	case <-control.cancelled:
+	default:
+		control.err = reason
+		close(control.cancelled)
+	}
+
+	for _, role := range control.roles {
+		if !role.finished() {
+			role.state, role.since, role.err = stateFailed, time.Now(), reason
+		}
+	}
+}
+
+// cancel stops the survey as asked by the querier
+func (sc *surveyControls) cancel(surveyID string) {
+	sc.stop(surveyID, protocols.ErrCancelled)
+}
+
+// stopped returns why the survey was stopped, nil if it was not
+func (sc *surveyControls) stopped(surveyID string) error {
+	control := sc.get(surveyID)
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	return control.err
+}
+
+// start records that the node takes the role in the survey, refusing reused identifiers: if fresh, any identifier
+// known to the node, else one it already took this role in
+func (sc *surveyControls) start(surveyID, role string, fresh bool) error {
+	control := sc.get(surveyID)
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	if _, ok := control.roles[role]; ok || (fresh && len(control.roles) > 0) {
+		return fmt.Errorf("survey %q already exists", surveyID)
+	}
+	control.roles[role] = &surveyRole{state: stateCreated, since: time.Now()}
+	return nil
+}
+
+// join records that the node takes the role in the survey, again if it already did, as a data provider answers each
+// of its computing nodes
+func (sc *surveyControls) join(surveyID, role string) {
+	control := sc.get(surveyID)
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	control.roles[role] = &surveyRole{state: stateCollecting, since: time.Now()}
+}
+
+// set changes the state of the survey for the role, unless it is finished
+func (sc *surveyControls) set(surveyID, role, state string) {
+	control := sc.get(surveyID)
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	if r, ok := control.roles[role]; ok && !r.finished() {
+		r.state, r.since = state, time.Now()
+	}
+}
+
+// finish ends the survey for the role, failed if there is an error
+func (sc *surveyControls) finish(surveyID, role string, err error) {
+	control := sc.get(surveyID)
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	if r, ok := control.roles[role]; ok && !r.finished() {
+		r.state, r.since, r.err = stateDone, time.Now(), err
+		if err != nil {
+			r.state = stateFailed
+		}
+	}
+}
+
+// collect forgets the surveys finished for longer than the retention, and the ones older than surveyMaxAge, returning
+// their identifiers
+func (sc *surveyControls) collect() []string {
+	sc.Lock()
+	defer sc.Unlock()
+
+	now := time.Now()
+	var expired []string
+	for id, control := range sc.controls {
+		// surveys only heard of, such as cancelled before being received, expire as finished ones
+		finished, last := true, control.created
+		for _, r := range control.roles {
+			finished = finished && r.finished()
+			if r.since.After(last) {
+				last = r.since
+			}
+		}
+
+		if now.Sub(control.created) > surveyMaxAge || (finished && now.Sub(last) > sc.retention) {
+			select {
+			case <-control.cancelled:
+			default:
+				control.err = errors.New("survey expired")
+				close(control.cancelled)
+			}
+			delete(sc.controls, id)
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
+
+// list describes the surveys known to the node, for each of its roles
+func (sc *surveyControls) list() []libdrynx.SurveyInfo {
+	sc.Lock()
+	defer sc.Unlock()
+
+	infos := make([]libdrynx.SurveyInfo, 0, len(sc.controls))
+	for id, control := range sc.controls {
+		for name, r := range control.roles {
+			info := libdrynx.SurveyInfo{SurveyID: id, Role: name, State: r.state, Since: r.since.Unix()}
+			if r.err != nil {
+				info.Error = r.err.Error()
+			}
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].SurveyID != infos[j].SurveyID {
+			return infos[i].SurveyID < infos[j].SurveyID
+		}
+		return infos[i].Role < infos[j].Role
+	})
+	return infos
+}
+
+// report records that a computing node finished its data collection, returning false if it already reported it
+func (sc *surveyControls) report(from *network.ServerIdentity, finished *DPdataFinished) bool {
+	control := sc.get(finished.SurveyID)
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	if _, ok := control.reports[from.String()]; ok {
+		return false
+	}
+	control.reports[from.String()] = finished
+	select {
+	case control.changed <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// waitForCNs waits at the entry computing node for the other ones to finish their data collection, returning all the
+// data providers whose responses were collected
+func (s *ServiceDrynx) waitForCNs(sq *libdrynx.SurveyQuery, dps []string) ([]string, error) {
+	control := s.controls.get(sq.SurveyID)
+	nbrCNs := len(sq.RosterServers.List) - 1
+
+	var timeout <-chan time.Time
+	if sq.Timeout > 0 {
+		timer := time.NewTimer(time.Duration(sq.Timeout)*time.Second + cnReportGrace)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var reports []*DPdataFinished
+	for {
+		s.controls.Lock()
+		reports = make([]*DPdataFinished, 0, len(control.reports))
+		for _, r := range control.reports {
+			reports = append(reports, r)
+		}
+		s.controls.Unlock()
+
+		if len(reports) >= nbrCNs {
+			break
+		}
+
+		select {
+		case <-control.changed:
+		case <-control.cancelled:
+			return nil, s.controls.stopped(sq.SurveyID)
+		case <-timeout:
+			return nil, fmt.Errorf("only %v of %v computing nodes collected their data in time", len(reports), nbrCNs)
+		}
+	}
+
+	for _, r := range reports {
+		if r.Error != "" {
+			return nil, fmt.Errorf("computing node failed: %v", r.Error)
+		}
+		dps = append(dps, r.DPs...)
+	}
+	sort.Strings(dps)
+	return dps, nil
+}
+
+// HandleCancelSurvey stops a survey started at this computing node, telling its other nodes to stop as well
+func (s *ServiceDrynx) HandleCancelSurvey(recq *libdrynx.CancelSurvey) (network.Message, error) {
+	obj, _ := s.Survey.Get(recq.SurveyID)
+	survey, ok := obj.(Survey)
+	if !ok || survey.SurveyQuery.IntraMessage {
+		return nil, fmt.Errorf("unknown survey %q", recq.SurveyID)
+	}
+	if err := recq.VerifySignature(survey.SurveyQuery.ClientPubKey); err != nil {
+		return nil, fmt.Errorf("only the querier can cancel the survey: %v", err)
+	}
+
+	log.Lvl1("[SERVICE] <drynx> Server", s.ServerIdentity(), "cancelling survey", recq.SurveyID)
+	s.controls.cancel(recq.SurveyID)
+	s.cancelAtNodes(&survey.SurveyQuery, recq)
+
+	return nil, nil
+}
+
+// checkCancel checks that a cancellation sent by another node is about a survey known here and comes from one of its
+// computing nodes. Only the root, failing the survey, may send it unsigned, else it has to be signed by the querier.
+func (s *ServiceDrynx) checkCancel(cs *libdrynx.CancelSurvey, sender *network.ServerIdentity) error {
+	obj, _ := s.Survey.Get(cs.SurveyID)
+	survey, ok := obj.(Survey)
+	if !ok {
+		return errors.New("unknown survey")
+	}
+
+	roster := survey.SurveyQuery.RosterServers
+	if sender == nil || len(roster.List) == 0 {
+		return errors.New("not sent by a computing node of the survey")
+	}
+	if i, _ := roster.Search(sender.ID); i < 0 {
+		return errors.New("not sent by a computing node of the survey")
+	}
+	if cs.Signature == nil && sender.Equal(roster.List[0]) {
+		return nil
+	}
+	if err := cs.VerifySignature(survey.SurveyQuery.ClientPubKey); err != nil {
+		return fmt.Errorf("not cancelled by the querier: %v", err)
+	}
+	return nil
+}
+
+// collectSurveys drops the surveys expired at this node
+func (s *ServiceDrynx) collectSurveys() {
+	s.submitted.expire()
+
+	expired := s.controls.collect()
+	if len(expired) == 0 {
+		return
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	for _, id := range expired {
+		s.Survey.Remove(id)
+		if s.Request != nil {
+			s.Request.Remove(id)
+		}
+	}
+	log.Lvl2("[SERVICE] <drynx> Server", s.ServerIdentity(), "dropped expired surveys", expired)
+}
+
+// HandleGetRoles advertises the roles taken by the node, for the queriers to assign the data providers
+func (s *ServiceDrynx) HandleGetRoles(recq *libdrynx.GetRoles) (network.Message, error) {
+	roles := s.roles
+	return &roles, nil
+}
+
+// HandleListSurveys describes the surveys held by the node, with their state for each of its roles
+func (s *ServiceDrynx) HandleListSurveys(recq *libdrynx.ListSurveys) (network.Message, error) {
+	s.collectSurveys()
+	return &libdrynx.SurveysList{Surveys: s.controls.list()}, nil
+}
+
+// cancelAtNodes tells the other nodes of the survey to stop it, forwarding the cancellation of the querier if any
+func (s *ServiceDrynx) cancelAtNodes(sq *libdrynx.SurveyQuery, cs *libdrynx.CancelSurvey) {
+	nodes := append([]*network.ServerIdentity{}, sq.RosterServers.List...)
+	for _, dps := range sq.ServerToDP {
+		if dps != nil {
+			for i := range dps.Content {
+				nodes = append(nodes, &dps.Content[i])
+			}
+		}
+	}
+
+	sent := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Equal(s.ServerIdentity()) || sent[node.String()] {
+			continue
+		}
+		sent[node.String()] = true
+
+		if err := s.SendRaw(node, cs); err != nil {
+			log.Warn("[SERVICE] <drynx> Server", s.ServerIdentity(), "unable to cancel survey", sq.SurveyID, "at", node, ":", err)
+		}
+	}
+}
@@ -2,34 +2,79 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
 	"github.com/ldsec/drynx/protocols"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
 )
 
+// dataset is served under a name by a DP
+type dataset struct {
+	loader      provider.Loader
+	neutralizer provider.Neutralizer
+}
+
+// unknownDataset is the Loader of the datasets not served, refusing to provide anything
+type unknownDataset string
+
+func (name unknownDataset) Provide(libdrynx.Query) ([][]float64, error) {
+	return nil, fmt.Errorf("unknown dataset %q", string(name))
+}
+
+func (s *ServiceDrynx) getDataset(name string) dataset {
+	if ds, ok := s.datasets[name]; ok {
+		return ds
+	}
+	return dataset{loader: unknownDataset(name)}
+}
+
 // Query Handlers
 //______________________________________________________________________________________________________________________
 
 // HandleSurveyQueryToDP handles the reception of a query at a DP
 func (s *ServiceDrynx) HandleSurveyQueryToDP(recq *libdrynx.SurveyQueryToDP) (network.Message, error) {
+	s.collectSurveys()
+	s.controls.join(recq.SQ.SurveyID, roleDataProvider)
+
+	// refused before touching any data, this data provider then tells the root instead of answering
+	err := s.authorize(recq.SQ)
+	if err == nil && libdrynx.AddLocalDiffP(recq.SQ.Query.LocalDiffP) && recq.SQ.Query.Proofs != 0 {
+		// the range proofs would be over the values before their perturbation
+		err = errors.New("local differential privacy cannot be proven")
+	}
+	if _, ok := s.datasets[recq.SQ.Query.Dataset]; err == nil && !ok {
+		// failing the survey, as the neutral response would be taken for data
+		err = fmt.Errorf("unknown dataset %q", recq.SQ.Query.Dataset)
+	}
+	if err != nil {
+		if _, perr := s.Survey.Put(recq.SQ.SurveyID, Survey{SurveyQuery: recq.SQ, Refusal: err.Error()}); perr != nil {
+			log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "unable to record the refusal of survey", recq.SQ.SurveyID, ":", perr)
+		}
+		s.controls.stop(recq.SQ.SurveyID, err)
+		return nil, err
+	}
+
 	// only generate ProofCollection protocol instances if proofs is enabled
 	var mapPIs map[string]onet.ProtocolInstance
 	if recq.SQ.Query.Proofs != 0 {
-		var err error
 		mapPIs, err = s.generateRangePI(recq)
 		if err != nil {
+			s.controls.finish(recq.SQ.SurveyID, roleDataProvider, err)
 			return nil, err
 		}
 	}
 
-	_, err := s.Survey.Put(recq.SQ.SurveyID, Survey{
+	_, err = s.Survey.Put(recq.SQ.SurveyID, Survey{
 		SurveyQuery: recq.SQ,
 		MapPIs:      mapPIs,
 	})
 	if err != nil {
+		s.controls.finish(recq.SQ.SurveyID, roleDataProvider, err)
 		return nil, err
 	}
 
@@ -42,6 +87,17 @@ func (s *ServiceDrynx) HandleSurveyQueryToDP(recq *libdrynx.SurveyQueryToDP) (ne
 	return nil, nil
 }
 
+// HandleGetDatasets advertises the names of the datasets served by the DP
+func (s *ServiceDrynx) HandleGetDatasets(request *libdrynx.GetDatasets) (network.Message, error) {
+	names := make([]string, 0, len(s.datasets))
+	for name := range s.datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &libdrynx.Datasets{Names: names}, nil
+}
+
 // Support Functions
 //______________________________________________________________________________________________________________________
 
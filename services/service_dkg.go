@@ -0,0 +1,200 @@
+package services
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/protocols"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+// keyShares are the shares of the collective keys generated with the other computing nodes, kept in memory and in the
+// data directory of the node, as the surveys encrypted under a key can't be switched without enough of its shares
+type keyShares struct {
+	sync.Mutex
+	dir    string
+	cached map[string]libdrynx.KeyShare
+}
+
+func newKeyShares(dir string) *keyShares {
+	return &keyShares{dir: dir, cached: make(map[string]libdrynx.KeyShare)}
+}
+
+func (ks *keyShares) path(key kyber.Point) (string, error) {
+	keyBytes, err := key.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(keyBytes)
+	return filepath.Join(ks.dir, fmt.Sprintf("dkg-%x.share", digest[:8])), nil
+}
+
+// get returns the share of the collective key, read from the data directory if not known yet
+func (ks *keyShares) get(key kyber.Point) (libdrynx.KeyShare, error) {
+	path, err := ks.path(key)
+	if err != nil {
+		return libdrynx.KeyShare{}, err
+	}
+
+	ks.Lock()
+	defer ks.Unlock()
+
+	if share, ok := ks.cached[path]; ok {
+		return share, nil
+	}
+
+	encoded, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return libdrynx.KeyShare{}, fmt.Errorf("no share of the collective key %v", key)
+	} else if err != nil {
+		return libdrynx.KeyShare{}, err
+	}
+	share := libdrynx.KeyShare{}
+	if err := protobuf.DecodeWithConstructors(encoded, &share, network.DefaultConstructors(libdrynx.Suite)); err != nil {
+		return libdrynx.KeyShare{}, fmt.Errorf("unable to read the share %v: %v", path, err)
+	}
+	if share.Key == nil || !share.Key.Equal(key) {
+		return libdrynx.KeyShare{}, fmt.Errorf("%v is not a share of the collective key %v", path, key)
+	}
+
+	ks.cached[path] = share
+	return share, nil
+}
+
+// put keeps the share, written to the data directory
+func (ks *keyShares) put(share libdrynx.KeyShare) error {
+	path, err := ks.path(share.Key)
+	if err != nil {
+		return err
+	}
+	encoded, err := protobuf.Encode(&share)
+	if err != nil {
+		return err
+	}
+
+	ks.Lock()
+	defer ks.Unlock()
+
+	if err := writeAtomically(path, func(w io.Writer) error {
+		_, err := w.Write(encoded)
+		return err
+	}); err != nil {
+		return fmt.Errorf("unable to keep the share of the collective key: %v", err)
+	}
+	ks.cached[path] = share
+	return nil
+}
+
+// HandleStartDKG generates a collective key with the computing nodes of the roster, each of them keeping its share.
+// If the queriers are restricted, only them can ask for it.
+func (s *ServiceDrynx) HandleStartDKG(recq *libdrynx.StartDKG) (network.Message, error) {
+	if s.queriers != nil {
+		if err := s.queriers.AuthorizeKeyGeneration(*recq); err != nil {
+			return nil, fmt.Errorf("unauthorized key generation: %v", err)
+		}
+	}
+	if recq.Roster == nil {
+		return nil, errors.New("no roster of computing nodes")
+	}
+	roster := recq.Roster.NewRosterWithRoot(s.ServerIdentity())
+	if roster == nil {
+		return nil, errors.New("not one of the computing nodes of the roster")
+	}
+	tree := roster.GenerateStar()
+	if tree == nil {
+		return nil, errors.New("unable to generate tree")
+	}
+
+	log.Lvl1("[SERVICE] <drynx> Server", s.ServerIdentity(), "generates a collective key with", len(roster.List), "computing nodes, with a threshold of", recq.Threshold)
+
+	tn := s.NewTreeNodeInstance(tree, tree.Root, protocols.DKGProtocolName)
+	conf := onet.GenericConfig{}
+	if err := tn.SetConfig(&conf); err != nil {
+		return nil, err
+	}
+	pi, err := s.NewProtocol(tn, &conf)
+	if err != nil {
+		return nil, err
+	}
+	dkg := pi.(*protocols.DKGProtocol)
+	dkg.Threshold = recq.Threshold
+
+	if err := s.RegisterProtocolInstance(pi); err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := pi.Dispatch(); err != nil {
+			log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "error running", protocols.DKGProtocolName, ":", err)
+		}
+	}()
+	if err := pi.Start(); err != nil {
+		return nil, err
+	}
+
+	share := <-dkg.FeedbackChannel
+	if dkg.Err != nil {
+		return nil, fmt.Errorf("key generation: %v", dkg.Err)
+	}
+	return &libdrynx.CollectiveKey{Key: share.Key, Threshold: share.Threshold}, nil
+}
+
+// NewThresholdKeySwitchingProtocol defines a new threshold key switching protocol, with the share of the node
+func (s *ServiceDrynx) NewThresholdKeySwitchingProtocol(tn *onet.TreeNodeInstance, survey Survey) (onet.ProtocolInstance, error) {
+	pi, err := protocols.NewThresholdKeySwitchingProtocol(tn)
+	if err != nil {
+		return nil, err
+	}
+	keySwitch := pi.(*protocols.ThresholdKeySwitchingProtocol)
+
+	// without its share, the node still answers, so that the root doesn't wait for it
+	if share, err := s.keyShares.get(survey.SurveyQuery.ThresholdKey); err != nil {
+		log.Warn("[SERVICE] <drynx> Server", s.ServerIdentity(), "can't switch survey", survey.SurveyQuery.SurveyID, ":", err)
+	} else {
+		keySwitch.Share = &share
+	}
+
+	// every node only switches to the querier's key
+	target := survey.SurveyQuery.ClientPubKey
+	keySwitch.TargetPublicKey = &target
+	if tn.IsRoot() {
+		keySwitch.TargetOfSwitch = convertToCipherVector(&survey.QueryResponseState)
+	}
+	return pi, nil
+}
+
+// ThresholdKeySwitchingPhase performs the switch to the querier's key of the aggregated data encrypted under a
+// threshold key, with enough of the computing nodes.
+func (s *ServiceDrynx) ThresholdKeySwitchingPhase(targetSurvey string) error {
+	pi, err := s.StartProtocol(protocols.ThresholdKeySwitchingProtocolName, targetSurvey)
+	if err != nil {
+		return err
+	}
+	keySwitch := pi.(*protocols.ThresholdKeySwitchingProtocol)
+	switched := <-keySwitch.FeedbackChannel
+	if keySwitch.Err != nil {
+		return keySwitch.Err
+	}
+
+	survey, err := castToSurvey(s.Survey.Get(targetSurvey))
+	if err != nil {
+		return err
+	}
+	survey.QueryResponseState = *convertFromKeySwitchingStruct(switched, survey.QueryResponseState)
+	survey.KeySwitchers = make([]string, len(keySwitch.KeySwitchers))
+	for i, cn := range keySwitch.KeySwitchers {
+		survey.KeySwitchers[i] = cn.String()
+	}
+	_, err = s.Survey.Put(targetSurvey, survey)
+	return err
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/ldsec/unlynx/lib/shuffle"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/onet/v3/log"
+)
+
+// shufflePrecomputations are the precomputations for shuffling the noise values, cached for each collective key and
+// size, in memory and in the data directory of the node
+type shufflePrecomputations struct {
+	sync.Mutex
+	dir    string
+	cached map[string][]libunlynxshuffle.CipherVectorScalar
+}
+
+func newShufflePrecomputations(dir string) *shufflePrecomputations {
+	return &shufflePrecomputations{dir: dir, cached: make(map[string][]libunlynxshuffle.CipherVectorScalar)}
+}
+
+// shuffleSize returns the size of the precomputation needed to shuffle the noise values of the query
+func shuffleSize(query libdrynx.Query) (lineSize, nbrLines int, err error) {
+	if query.DiffP.NoiseListSize < query.Operation.NbrOutput {
+		return 0, 0, fmt.Errorf("list of %v noise values, %v needed for the outputs of %v",
+			query.DiffP.NoiseListSize, query.Operation.NbrOutput, query.Operation.NameOp)
+	}
+	// each noise value is shuffled as a line of its own
+	return 1, query.DiffP.NoiseListSize, nil
+}
+
+// get returns the precomputation for the collective key, read from the data directory or else computed and written
+// there; surveys sharing a collective key wait for a single computation
+func (sp *shufflePrecomputations) get(collectiveKey kyber.Point, lineSize, nbrLines int) ([]libunlynxshuffle.CipherVectorScalar, error) {
+	keyBytes, err := collectiveKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(keyBytes)
+	name := fmt.Sprintf("shuffle-%x-%vx%v.gob", digest[:8], nbrLines, lineSize)
+
+	sp.Lock()
+	defer sp.Unlock()
+
+	if precomputed, ok := sp.cached[name]; ok {
+		return precomputed, nil
+	}
+
+	path := filepath.Join(sp.dir, name)
+	precomputed, err := readShufflePrecomputation(path)
+	if err != nil || len(precomputed) != nbrLines {
+		if err != nil && !os.IsNotExist(err) {
+			log.Warn("[SERVICE] <drynx> unable to read the shuffle precomputation", path, ", computing it again:", err)
+		}
+
+		precomputed = libunlynxshuffle.CreatePrecomputedRandomize(libunlynx.SuiTe.Point().Base(), collectiveKey, libunlynx.SuiTe.RandomStream(), lineSize, nbrLines)
+		if err := writeShufflePrecomputation(path, precomputed); err != nil {
+			log.Warn("[SERVICE] <drynx> unable to cache the shuffle precomputation", path, ":", err)
+		}
+	}
+
+	sp.cached[name] = precomputed
+	return precomputed, nil
+}
+
+func readShufflePrecomputation(path string) ([]libunlynxshuffle.CipherVectorScalar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var encoded []libunlynxshuffle.CipherVectorScalarBytes
+	if err := gob.NewDecoder(file).Decode(&encoded); err != nil {
+		return nil, err
+	}
+	return libunlynxshuffle.DecodeCipherVectorScalar(encoded)
+}
+
+// writeShufflePrecomputation writes the precomputation at once, so that a restarted node never reads a partial one
+func writeShufflePrecomputation(path string, precomputed []libunlynxshuffle.CipherVectorScalar) error {
+	encoded, err := libunlynxshuffle.EncodeCipherVectorScalar(precomputed)
+	if err != nil {
+		return err
+	}
+	return writeAtomically(path, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(encoded)
+	})
+}
+
+// writeAtomically writes a file of the data directory through a temporary one, renamed once complete
+func writeAtomically(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// shufflePrecomputation returns the precomputation for shuffling the noise values of the survey
+func (s *ServiceDrynx) shufflePrecomputation(sq *libdrynx.SurveyQuery) ([]libunlynxshuffle.CipherVectorScalar, error) {
+	lineSize, nbrLines, err := shuffleSize(sq.Query)
+	if err != nil {
+		return nil, err
+	}
+	return s.shuffles.get(sq.RosterServers.Aggregate, lineSize, nbrLines)
+}
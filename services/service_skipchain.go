@@ -1,6 +1,8 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"sync"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/coreos/bbolt"
 	"github.com/fanliao/go-concurrentMap"
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/metrics"
 	"github.com/ldsec/drynx/protocols"
 	"github.com/ldsec/unlynx/lib"
 	"go.dedis.ch/cothority/v3/skipchain"
@@ -29,6 +32,12 @@ var VerificationBitmap = []skipchain.VerifierID{VerifyBitmap, skipchain.VerifyBa
 
 // HandleSurveyQueryToVN handles the reception of the query at a VN
 func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (network.Message, error) {
+	s.collectSurveys()
+	if err := s.controls.start(recq.SQ.SurveyID, roleVerifyingNode, false); err != nil {
+		return nil, err
+	}
+	s.controls.set(recq.SQ.SurveyID, roleVerifyingNode, stateCollecting)
+
 	s.Mutex.Lock()
 	var totalNbrProofs int
 	log.Lvl2("[SERVICE] <VN> Server", s.ServerIdentity().String(), "received a Survey Query")
@@ -77,7 +86,8 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 	if s.DB == nil {
 		db, err := OpenDB(s.DBPath)
 		if err != nil {
-			log.ErrFatal(err, "Could not open db")
+			s.Mutex.Unlock()
+			return nil, fmt.Errorf("could not open db: %v", err)
 		}
 		s.DB = db
 	}
@@ -89,11 +99,21 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 	s.Mutex.Unlock()
 
 	if s.ServerIdentity().String() == recq.SQ.Query.RosterVNs.List[0].String() {
+		qi, err := protocols.CastToQueryInfo(s.Request.Get(recq.SQ.SurveyID))
+		if err != nil {
+			return nil, err
+		}
+		// the verification fails for this survey only
+		fail := func(err error) {
+			log.Error("[SERVICE] <VN> Server", s.ServerIdentity(), "survey", recq.SQ.SurveyID, "failed:", err)
+			s.controls.finish(recq.SQ.SurveyID, roleVerifyingNode, err)
+		}
+
 		go func() {
 			// read all bitmaps
 			aggregateBitmap := make(map[string]int64)
 			for i := 0; i < len(recq.SQ.Query.RosterVNs.List); i++ {
-				res := <-protocols.CastToQueryInfo(s.Request.Get(recq.SQ.SurveyID)).SharedBMChannel
+				res := <-qi.SharedBMChannel
 
 				for key, value := range res {
 					aggregateBitmap[key] = value
@@ -102,7 +122,7 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 
 			// terminate all protocols
 			for i := 0; i < totalNbrProofs; i++ {
-				protocols.CastToQueryInfo(s.Request.Get(recq.SQ.SurveyID)).SharedBMChannelToTerminate <- struct{}{}
+				qi.SharedBMChannelToTerminate <- struct{}{}
 			}
 
 			startBI := libunlynx.StartTimer("BI")
@@ -118,7 +138,8 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 
 			dataBytes, err := network.Marshal(dataBlock)
 			if err != nil {
-				log.Fatal("Error in marshaling proofs data to insert ,", err)
+				fail(fmt.Errorf("error in marshaling proofs data to insert: %v", err))
+				return
 			}
 
 			// no skipchain yet created
@@ -128,23 +149,31 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 			if s.LastSkipBlock == nil {
 				newSB, err = CreateProofSkipchain(s.Skipchain, recq.SQ.Query.RosterVNs, dataBytes)
 				if err != nil || newSB == nil {
-					log.Fatal("Error creating the genesis block:", err)
+					s.Mutex.Unlock()
+					fail(fmt.Errorf("error creating the genesis block: %v", err))
+					return
 				}
 
 				//Store Genesis in DB
 				genesisBytes, _ := network.Marshal(newSB)
-				libdrynx.UpdateDB(s.DB, "genesis", "genesis", genesisBytes)
+				if err := libdrynx.UpdateDB(s.DB, "genesis", "genesis", genesisBytes); err != nil {
+					log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "unable to store the genesis block:", err)
+				}
 
 				s.LastSkipBlock = newSB
 
 			} else {
 				newSB, err = AppendProofSkipchain(s.Skipchain, recq.SQ.Query.RosterVNs, dataBytes, s.LastSkipBlock, recq.SQ.SurveyID)
 				if err != nil || newSB == nil {
-					log.Fatal("Error appending the block to the chain:", err)
+					s.Mutex.Unlock()
+					fail(fmt.Errorf("error appending the block to the chain: %v", err))
+					return
 				}
 
 				//Store new block in DB
-				libdrynx.UpdateDB(s.DB, "mapping", recq.SQ.SurveyID, []byte(newSB.Hash))
+				if err := libdrynx.UpdateDB(s.DB, "mapping", recq.SQ.SurveyID, []byte(newSB.Hash)); err != nil {
+					log.Error("[SERVICE] <drynx> Server", s.ServerIdentity(), "unable to store the block of survey", recq.SQ.SurveyID, ":", err)
+				}
 
 				s.LastSkipBlock = newSB
 			}
@@ -152,7 +181,8 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 
 			libunlynx.EndTimer(startBI)
 
-			protocols.CastToQueryInfo(s.Request.Get(recq.SQ.SurveyID)).EndVerificationChannel <- *newSB
+			qi.EndVerificationChannel <- *newSB
+			s.controls.finish(recq.SQ.SurveyID, roleVerifyingNode, nil)
 		}()
 	}
 
@@ -162,7 +192,11 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 // HandleEndVerification handles the reception of an end verification request
 func (s *ServiceDrynx) HandleEndVerification(msg *libdrynx.EndVerificationRequest) (network.Message, error) {
 	//block until all verification of the proofs is done (and of course inserted in the skipchain)
-	sb := <-protocols.CastToQueryInfo(s.Request.Get(msg.QueryInfoID)).EndVerificationChannel
+	qi, err := protocols.CastToQueryInfo(s.Request.Get(msg.QueryInfoID))
+	if err != nil {
+		return nil, err
+	}
+	sb := <-qi.EndVerificationChannel
 	return &libdrynx.Reply{Latest: &sb}, nil
 }
 
@@ -210,17 +244,21 @@ func (s *ServiceDrynx) HandleGetBlock(request *libdrynx.GetBlock) (network.Messa
 	}
 
 	err := s.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("genesis"))
-		blockIDbytes := b.Get([]byte(request.ID))
+		// the buckets are created with the first blocks, the block of a survey being inserted once its proofs are verified
+		var blockIDbytes []byte
+		for _, bucket := range []string{"genesis", "mapping"} {
+			if b := tx.Bucket([]byte(bucket)); b != nil && len(blockIDbytes) == 0 {
+				blockIDbytes = b.Get([]byte(request.ID))
+			}
+		}
 		if len(blockIDbytes) == 0 {
-			b = tx.Bucket([]byte("mapping"))
-			blockIDbytes = b.Get([]byte(request.ID))
+			return fmt.Errorf("no block for survey %v", request.ID)
 		}
 		blockID = skipchain.SkipBlockID(blockIDbytes)
 		return nil
 	})
 	if err != nil {
-		log.Error("Error getting index" + err.Error())
+		return nil, err
 	}
 
 	block, err := s.Skipchain.GetSingleBlock(request.Roster, blockID)
@@ -237,10 +275,11 @@ func (s *ServiceDrynx) HandleGetBlock(request *libdrynx.GetBlock) (network.Messa
 func (s *ServiceDrynx) HandleGetProofs(request *libdrynx.GetProofs) (network.Message, error) {
 	//Open the DB if it is not open
 	timeGetProof := libunlynx.StartTimer(s.ServerIdentity().String() + "_GetProofs")
+	endGetProofs := metrics.Phase("get_proofs")
 	if s.DB == nil {
 		db, err := OpenDB(s.DBPath)
 		if err != nil {
-			log.ErrFatal(err, "Could not open db")
+			return nil, fmt.Errorf("could not open db: %v", err)
 		}
 		s.DB = db
 	}
@@ -313,6 +352,7 @@ func (s *ServiceDrynx) HandleGetProofs(request *libdrynx.GetProofs) (network.Mes
 	}
 
 	libunlynx.EndTimer(timeGetProof)
+	endGetProofs()
 	return &libdrynx.ProofsAsMap{Proofs: result}, nil
 }
 
@@ -350,7 +390,10 @@ func (s *ServiceDrynx) NewProofCollectionProtocolInstance(tn *onet.TreeNodeInsta
 
 	proofCollection := pi.(*protocols.ProofCollectionProtocol)
 	if !tn.IsRoot() {
-		survey := castToSurvey(s.Survey.Get(target))
+		survey, err := castToSurvey(s.Survey.Get(target))
+		if err != nil {
+			return nil, err
+		}
 
 		// TODO: Add channel to ensure that the query has been set
 		proofCollection.SQ = survey.SurveyQuery
@@ -361,8 +404,12 @@ func (s *ServiceDrynx) NewProofCollectionProtocolInstance(tn *onet.TreeNodeInsta
 
 		// if root of the VN
 		if s.ServerIdentity().String() == survey.SurveyQuery.Query.RosterVNs.List[0].String() {
-			proofCollection.SharedBMChannel = protocols.CastToQueryInfo(s.Request.Get(target)).SharedBMChannel
-			proofCollection.SharedBMChannelToTerminate = protocols.CastToQueryInfo(s.Request.Get(target)).SharedBMChannelToTerminate
+			qi, err := protocols.CastToQueryInfo(s.Request.Get(target))
+			if err != nil {
+				return nil, err
+			}
+			proofCollection.SharedBMChannel = qi.SharedBMChannel
+			proofCollection.SharedBMChannelToTerminate = qi.SharedBMChannelToTerminate
 		}
 	}
 
@@ -381,7 +428,7 @@ func (s *ServiceDrynx) CreateProofCollectionPIs(tree *onet.Tree, targetSurvey, n
 
 	pi, err := s.NewProtocol(tn, &conf)
 	if err != nil {
-		log.Fatal("Error running" + name)
+		return nil, fmt.Errorf("error running %v: %v", name, err)
 	}
 
 	err = s.RegisterProtocolInstance(pi)
@@ -400,12 +447,16 @@ func (s *ServiceDrynx) verifyFuncBitmap(newID []byte, newSB *skipchain.SkipBlock
 	//Get data of the newBlock
 	_, msg, err := network.Unmarshal(newSB.Data, libunlynx.SuiTe)
 	if err != nil {
-		log.Fatal("Error in Verify Bitmap")
+		log.Error("[SERVICE] <VN> Server", s.ServerIdentity(), "unable to read the block to verify:", err)
 		return false
 	}
 
 	//Get bitmap that was inserted in newBlock
-	blockData := msg.(*libdrynx.DataBlock)
+	blockData, ok := msg.(*libdrynx.DataBlock)
+	if !ok {
+		log.Error("[SERVICE] <VN> Server", s.ServerIdentity(), "block to verify without the verification of a survey")
+		return false
+	}
 	bitMap := blockData.Proofs
 	bitMapFromServ := make(map[string]int64)
 
@@ -413,14 +464,24 @@ func (s *ServiceDrynx) verifyFuncBitmap(newID []byte, newSB *skipchain.SkipBlock
 	err = s.DB.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(s.ServerIdentity().Address))
 		if b == nil {
-			log.Fatal("No bucket")
+			return errors.New("no bitmap stored")
 		}
 		v := b.Get([]byte(blockData.SurveyID + "/map"))
-		_, message, _ := network.Unmarshal(v, libunlynx.SuiTe)
-		result := message.(*libdrynx.BitMap)
+		_, message, err := network.Unmarshal(v, libunlynx.SuiTe)
+		if err != nil {
+			return err
+		}
+		result, ok := message.(*libdrynx.BitMap)
+		if !ok {
+			return fmt.Errorf("no bitmap stored for survey %v", blockData.SurveyID)
+		}
 		bitMapFromServ = result.BitMap
 		return nil
 	})
+	if err != nil {
+		log.Error("[SERVICE] <VN> Server", s.ServerIdentity(), "unable to verify the block of survey", blockData.SurveyID, ":", err)
+		return false
+	}
 
 	//Compare the bitmap you get from DB to all bitmap Stored in Block
 	for i, v := range bitMapFromServ {
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ldsec/drynx/lib"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// StatusPath is where the status of a node is served as JSON, on the port of its client, for monitoring
+const StatusPath = "/v3/" + ServiceName + "/GetStatus"
+
+// typeName describes the type of a loader or a neutralizer, empty if there is none
+func typeName(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// HandleGetStatus describes what the node is doing, for its operators
+func (s *ServiceDrynx) HandleGetStatus(recq *libdrynx.GetStatus) (network.Message, error) {
+	s.collectSurveys()
+
+	datasets := make([]libdrynx.DatasetStatus, 0, len(s.datasets))
+	for name, ds := range s.datasets {
+		datasets = append(datasets, libdrynx.DatasetStatus{
+			Name:        name,
+			Loader:      typeName(ds.loader),
+			Neutralizer: typeName(ds.neutralizer),
+		})
+	}
+	sort.Slice(datasets, func(i, j int) bool { return datasets[i].Name < datasets[j].Name })
+
+	status := libdrynx.Status{
+		Roles:    s.roles,
+		Datasets: datasets,
+		Uptime:   int64(time.Since(s.started).Seconds()),
+		Surveys:  s.controls.list(),
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.LastSkipBlock != nil {
+		status.SkipchainHead = hex.EncodeToString(s.LastSkipBlock.Hash)
+		status.SkipchainIndex = s.LastSkipBlock.Index
+	}
+	if s.DBPath != "" {
+		if info, err := os.Stat(s.DBPath); err == nil {
+			status.DBSize = info.Size()
+		}
+	}
+
+	return &status, nil
+}
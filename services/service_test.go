@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/ldsec/drynx/lib"
 	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/provider"
 	"github.com/ldsec/drynx/lib/provider/loaders"
 	"github.com/ldsec/drynx/lib/provider/neutralizers"
 	"github.com/ldsec/drynx/lib/range"
@@ -25,6 +26,11 @@ import (
 )
 
 func generateNodes(local *onet.LocalTest, nbrServers int, nbrDPs int, nbrVNs int, randomRange [2]float64) (*onet.Roster, *onet.Roster, *onet.Roster) {
+	return generateNodesWithDatasets(local, nbrServers, nbrDPs, nbrVNs, randomRange, nil)
+}
+
+// generateNodesWithDatasets also serves the given datasets at the data providers
+func generateNodesWithDatasets(local *onet.LocalTest, nbrServers int, nbrDPs int, nbrVNs int, randomRange [2]float64, datasets map[string]provider.Loader) (*onet.Roster, *onet.Roster, *onet.Roster) {
 	if randomRange[0] > randomRange[1] {
 		panic("randomRange: minimum > maximum")
 	}
@@ -33,11 +39,13 @@ func generateNodes(local *onet.LocalTest, nbrServers int, nbrDPs int, nbrVNs int
 	if err != nil {
 		panic(err)
 	}
-	services.NewBuilder().
+	builder := services.NewBuilder().
 		WithComputingNode().
-		WithDataProvider(loader, neutralizers.NewMinimumResultsSize(0)).
-		WithVerifyingNode().
-		Start()
+		WithDataProvider(loader, neutralizers.NewMinimumResultsSize(0))
+	for name, dataset := range datasets {
+		builder = builder.WithDataset(name, dataset, neutralizers.NewMinimumResultsSize(0))
+	}
+	builder.WithVerifyingNode().Start()
 
 	_, elTotal, _ := local.GenTree(nbrServers+nbrDPs+nbrVNs, true)
 
@@ -55,6 +63,49 @@ func generateNodes(local *onet.LocalTest, nbrServers int, nbrDPs int, nbrVNs int
 	return rosterServers, rosterDPs, rosterVNs
 }
 
+// logisticRegressionLoader provides a dataset file as read by libdrynxencoding.LoadData, as the features then the label.
+// The records are split among the DPs sharing the loader: each call provides the next of nbrDPs parts, so that the DPs
+// of a survey hold each record once.
+type logisticRegressionLoader struct {
+	datasetName, filePath string
+	nbrDPs                int
+
+	mutex sync.Mutex
+	next  int
+}
+
+func newLogisticRegressionLoader(datasetName, filePath string, nbrDPs int) *logisticRegressionLoader {
+	return &logisticRegressionLoader{datasetName: datasetName, filePath: filePath, nbrDPs: nbrDPs}
+}
+
+func (l *logisticRegressionLoader) Provide(query libdrynx.Query) ([][]float64, error) {
+	X, y := libdrynxencoding.LoadData(l.datasetName, l.filePath)
+	if len(X) == 0 {
+		return nil, fmt.Errorf("no records in %v", l.filePath)
+	}
+
+	l.mutex.Lock()
+	part := l.next
+	l.next = (l.next + 1) % l.nbrDPs
+	l.mutex.Unlock()
+
+	ret := make([][]float64, len(X[0])+1)
+	for i := part; i < len(X); i += l.nbrDPs {
+		for j, v := range X[i] {
+			ret[j] = append(ret[j], v)
+		}
+		ret[len(X[i])] = append(ret[len(X[i])], float64(y[i]))
+	}
+	return ret, nil
+}
+
+// selectLogisticRegression makes the query select the features and the label of the dataset used for the regression
+func selectLogisticRegression(sq *libdrynx.SurveyQuery) {
+	lrParameters := sq.Query.Operation.LRParameters
+	sq.Query.Dataset = lrParameters.DatasetName
+	sq.Query.Selector = make([]libdrynx.ColumnID, lrParameters.NbrFeatures+1)
+}
+
 // how to repartition the DPs: each server as a list of data providers
 func repartitionDPs(elServers *onet.Roster, elDPs *onet.Roster, dpRepartition []int64) map[string]*[]network.ServerIdentity {
 	if len(dpRepartition) > len(elServers.List) {
@@ -138,7 +189,10 @@ func TestServiceDrynx(t *testing.T) {
 		minGenerateData := 3
 		maxGenerateData := 4
 		dimensions := 5
-		operation := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		operation, err := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		// define the ranges for the input validation (1 range per data provider output)
 		var u, l int64
@@ -281,19 +335,15 @@ func TestServiceDrynx(t *testing.T) {
 		}
 
 		// send query and receive results
-		grp, aggr, err := client.SendSurveyQuery(sq)
+		result, err := client.SendSurveyQuery(sq)
 
 		if err != nil {
 			t.Fatal("'Drynx' service did not start.", err)
 		}
 
 		// Result printing
-		if len(*grp) != 0 && len(*grp) != len(*aggr) {
-			t.Fatal("Results format problem")
-		} else {
-			for i, v := range *aggr {
-				log.Lvl1((*grp)[i], ": ", v)
-			}
+		for _, group := range result.Groups {
+			log.Lvl1(group.Key, ": ", group.Operations)
 		}
 
 	}
@@ -359,7 +409,6 @@ func TestServiceDrynx(t *testing.T) {
 
 func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 	t.Skip()
-	os.Remove("pre_compute_multiplications.gob")
 	log.SetDebugVisible(2)
 
 	//------SET PARAMS--------
@@ -413,7 +462,6 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 	}
 
 	lrParameters.DatasetName = "SPECTF"
-	lrParameters.FilePath = filePathTraining
 	lrParameters.NbrRecords = int64(len(XTrain))
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
@@ -441,7 +489,8 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 	}
 
 	local := onet.NewLocalTest(libunlynx.SuiTe)
-	elServers, elDPs, elVNs := generateNodes(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4})
+	elServers, elDPs, elVNs := generateNodesWithDatasets(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4},
+		map[string]provider.Loader{lrParameters.DatasetName: newLogisticRegressionLoader(lrParameters.DatasetName, filePathTraining, nbrDPs)})
 
 	if proofs == 0 {
 		elVNs = nil
@@ -466,7 +515,10 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 		minGenerateData := 3
 		maxGenerateData := 4
 		dimensions := 5
-		operation := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		operation, err := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		if err != nil {
+			t.Fatal(err)
+		}
 		operation.LRParameters = lrParameters
 
 		// define the ranges for the input validation (1 range per data provider output)
@@ -577,6 +629,7 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 		surveyID := "query-" + op
 
 		sq := client.GenerateSurveyQuery(elServers, elVNs, dpToServers, idToPublic, surveyID, operation, ranges, ps, proofs, obfuscation, thresholdEntityProofsVerif, diffP, cuttingFactor)
+		selectLogisticRegression(&sq)
 		if !libdrynx.CheckParameters(sq, diffPri) {
 			log.Fatal("Oups!")
 		}
@@ -610,22 +663,18 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 		}
 
 		// send query and receive results
-		grp, aggr, err := client.SendSurveyQuery(sq)
+		result, err := client.SendSurveyQuery(sq)
 
 		if err != nil {
 			t.Fatal("'Drynx' service did not start.", err)
 		}
 
 		// Result printing
-		if len(*grp) != 0 && len(*grp) != len(*aggr) {
-			t.Fatal("Results format problem")
-		} else {
-			for i, v := range *aggr {
-				log.Lvl1((*grp)[i], ": ", v)
-			}
+		for _, group := range result.Groups {
+			log.Lvl1(group.Key, ": ", group.Operations)
 		}
-		if len(*aggr) != 0 {
-			weights := (*aggr)[0]
+		if len(result.Groups) != 0 {
+			weights := result.Groups[0].Operations[0].Values
 			if standardisationMode == 1 || standardisationMode == 2 {
 				means = nil
 				standardDeviations = nil
@@ -717,51 +766,6 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 
 func TestServiceDrynxLogisticRegression(t *testing.T) {
 	t.Skip()
-	os.Remove("pre_compute_multiplications.gob")
-
-	// these nodes act as both servers and data providers
-	local := onet.NewLocalTest(libunlynx.SuiTe)
-	local1 := onet.NewLocalTest(libunlynx.SuiTe)
-	local2 := onet.NewLocalTest(libunlynx.SuiTe)
-
-	loader, err := loaders.NewRandom(0, 2, 100)
-	if err != nil {
-		panic(err)
-	}
-	services.NewBuilder().
-		WithComputingNode().
-		WithDataProvider(loader, neutralizers.NewMinimumResultsSize(0)).
-		WithVerifyingNode().
-		Start()
-
-	// create servers and data providers
-	_, el, _ := local.GenTree(10, true)
-	//data providers
-	_, el1, _ := local1.GenTree(10, true)
-	//VNS
-	_, elVNs, _ := local2.GenTree(3, true)
-	//repartition
-	dpRepartition := []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
-	//dpRepartition := []int64{1}
-	dpToServers := make(map[string]*[]network.ServerIdentity, 0)
-	count := 0
-	for i, v := range el.List {
-		index := v.String()
-		value := make([]network.ServerIdentity, dpRepartition[i])
-		dpToServers[index] = &value
-		for j := range *dpToServers[index] {
-			val := el1.List[count]
-			count = count + 1
-			(*dpToServers[index])[j] = *val
-		}
-	}
-
-	proofs := 0 // 0 is not proof, 1 is proofs, 2 is optimized proofs
-
-	defer local.CloseAll()
-
-	// Create a client (querier) for the service)
-	client := services.NewDrynxClient(el.List[0], strconv.Itoa(0))
 
 	// ---- simulation parameters -----
 	numberTrials := 10
@@ -823,6 +827,46 @@ func TestServiceDrynxLogisticRegression(t *testing.T) {
 	filePathTraining := "../data/" + dataset + "_dataset_training.txt"
 	filePathTesting := "../data/" + dataset + "_dataset_testing.txt"
 
+	services.NewBuilder().
+		WithComputingNode().
+		WithDataset(dataset, newLogisticRegressionLoader(dataset, filePathTraining, 10), neutralizers.NewMinimumResultsSize(0)).
+		WithVerifyingNode().
+		Start()
+
+	// these nodes act as both servers and data providers
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	local1 := onet.NewLocalTest(libunlynx.SuiTe)
+	local2 := onet.NewLocalTest(libunlynx.SuiTe)
+
+	// create servers and data providers
+	_, el, _ := local.GenTree(10, true)
+	//data providers
+	_, el1, _ := local1.GenTree(10, true)
+	//VNS
+	_, elVNs, _ := local2.GenTree(3, true)
+	//repartition
+	dpRepartition := []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	//dpRepartition := []int64{1}
+	dpToServers := make(map[string]*[]network.ServerIdentity, 0)
+	count := 0
+	for i, v := range el.List {
+		index := v.String()
+		value := make([]network.ServerIdentity, dpRepartition[i])
+		dpToServers[index] = &value
+		for j := range *dpToServers[index] {
+			val := el1.List[count]
+			count = count + 1
+			(*dpToServers[index])[j] = *val
+		}
+	}
+
+	proofs := 0 // 0 is not proof, 1 is proofs, 2 is optimized proofs
+
+	defer local.CloseAll()
+
+	// Create a client (querier) for the service)
+	client := services.NewDrynxClient(el.List[0], strconv.Itoa(0))
+
 	meanAccuracy := 0.0
 	meanPrecision := 0.0
 	meanRecall := 0.0
@@ -873,7 +917,7 @@ func TestServiceDrynxLogisticRegression(t *testing.T) {
 			standardDeviations = nil
 		}
 
-		lrParameters.FilePath = filePathTraining
+		lrParameters.DatasetName = dataset
 		lrParameters.NbrRecords = int64(len(trainingSet))
 		lrParameters.NbrFeatures = int64(len(XTrain[0]))
 		lrParameters.Means = means
@@ -936,23 +980,20 @@ func TestServiceDrynxLogisticRegression(t *testing.T) {
 		// query sending + results receiving
 		cuttingFactor := 0
 		sq := client.GenerateSurveyQuery(el, elVNs, dpToServers, idToPublic, uuid.NewV4().String(), operation, ranges, ps, proofs, false, thresholdEntityProofsVerif, diffP, cuttingFactor)
-		grp, aggr, err := client.SendSurveyQuery(sq)
+		selectLogisticRegression(&sq)
+		result, err := client.SendSurveyQuery(sq)
 
 		if err != nil {
 			t.Fatal("'Drynx' service did not start.", err)
 		}
 
 		// Result printing
-		if len(*grp) != 0 && len(*grp) != len(*aggr) {
-			t.Fatal("Results format problem")
-		} else {
-			for i, v := range *aggr {
-				log.Lvl1((*grp)[i], ": ", v)
-			}
+		for _, group := range result.Groups {
+			log.Lvl1(group.Key, ": ", group.Operations)
 		}
 
-		if len(*aggr) != 0 {
-			weights := (*aggr)[0]
+		if len(result.Groups) != 0 {
+			weights := result.Groups[0].Operations[0].Values
 			if standardisationMode == 1 || standardisationMode == 2 {
 				means = nil
 				standardDeviations = nil
@@ -1041,7 +1082,6 @@ func performanceEvaluation(weights []float64, XTest [][]float64, yTest []int64,
 
 func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 	t.Skip()
-	os.Remove("pre_compute_multiplications.gob")
 	log.SetDebugVisible(2)
 
 	//------SET PARAMS--------
@@ -1137,7 +1177,6 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 		standardDeviations = nil
 	}
 
-	lrParameters.FilePath = filePathTraining
 	lrParameters.NbrRecords = int64(len(XTrain))
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
@@ -1165,7 +1204,8 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 	}
 
 	local := onet.NewLocalTest(libunlynx.SuiTe)
-	elServers, elDPs, elVNs := generateNodes(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4})
+	elServers, elDPs, elVNs := generateNodesWithDatasets(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4},
+		map[string]provider.Loader{lrParameters.DatasetName: newLogisticRegressionLoader(lrParameters.DatasetName, filePathTraining, nbrDPs)})
 
 	if proofs == 0 {
 		elVNs = nil
@@ -1190,7 +1230,10 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 		minGenerateData := 3
 		maxGenerateData := 4
 		dimensions := 5
-		operation := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		operation, err := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		if err != nil {
+			t.Fatal(err)
+		}
 		operation.LRParameters = lrParameters
 
 		// define the ranges for the input validation (1 range per data provider output)
@@ -1301,6 +1344,7 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 		surveyID := "query-" + op
 
 		sq := client.GenerateSurveyQuery(elServers, elVNs, dpToServers, idToPublic, surveyID, operation, ranges, ps, proofs, obfuscation, thresholdEntityProofsVerif, diffP, cuttingFactor)
+		selectLogisticRegression(&sq)
 		if !libdrynx.CheckParameters(sq, diffPri) {
 			log.Fatal("Oups!")
 		}
@@ -1334,22 +1378,18 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 		}
 
 		// send query and receive results
-		grp, aggr, err := client.SendSurveyQuery(sq)
+		result, err := client.SendSurveyQuery(sq)
 
 		if err != nil {
 			t.Fatal("'Drynx' service did not start.", err)
 		}
 
 		// Result printing
-		if len(*grp) != 0 && len(*grp) != len(*aggr) {
-			t.Fatal("Results format problem")
-		} else {
-			for i, v := range *aggr {
-				log.Lvl1((*grp)[i], ": ", v)
-			}
+		for _, group := range result.Groups {
+			log.Lvl1(group.Key, ": ", group.Operations)
 		}
-		if len(*aggr) != 0 {
-			weights := (*aggr)[0]
+		if len(result.Groups) != 0 {
+			weights := result.Groups[0].Operations[0].Values
 			if standardisationMode == 1 || standardisationMode == 2 {
 				means = nil
 				standardDeviations = nil
@@ -1439,7 +1479,6 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 
 func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 	t.Skip()
-	os.Remove("pre_compute_multiplications.gob")
 	log.SetDebugVisible(2)
 
 	//------SET PARAMS--------
@@ -1512,7 +1551,6 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 		standardDeviations = nil
 	}
 
-	lrParameters.FilePath = filePathTraining
 	lrParameters.NbrRecords = int64(len(XTrain))
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
@@ -1539,7 +1577,8 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 	}
 
 	local := onet.NewLocalTest(cothority.Suite)
-	elServers, elDPs, elVNs := generateNodes(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4})
+	elServers, elDPs, elVNs := generateNodesWithDatasets(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4},
+		map[string]provider.Loader{lrParameters.DatasetName: newLogisticRegressionLoader(lrParameters.DatasetName, filePathTraining, nbrDPs)})
 
 	if proofs == 0 {
 		elVNs = nil
@@ -1564,7 +1603,10 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 		minGenerateData := 3
 		maxGenerateData := 4
 		dimensions := 5
-		operation := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		operation, err := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		if err != nil {
+			t.Fatal(err)
+		}
 		operation.LRParameters = lrParameters
 
 		// define the ranges for the input validation (1 range per data provider output)
@@ -1671,6 +1713,7 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 		surveyID := "query-" + op
 
 		sq := client.GenerateSurveyQuery(elServers, elVNs, dpToServers, idToPublic, surveyID, operation, ranges, ps, proofs, obfuscation, thresholdEntityProofsVerif, diffP, cuttingFactor)
+		selectLogisticRegression(&sq)
 		if !libdrynx.CheckParameters(sq, diffPri) {
 			log.Fatal("Oups!")
 		}
@@ -1704,22 +1747,18 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 		}
 
 		// send query and receive results
-		grp, aggr, err := client.SendSurveyQuery(sq)
+		result, err := client.SendSurveyQuery(sq)
 
 		if err != nil {
 			t.Fatal("'Drynx' service did not start.", err)
 		}
 
 		// Result printing
-		if len(*grp) != 0 && len(*grp) != len(*aggr) {
-			t.Fatal("Results format problem")
-		} else {
-			for i, v := range *aggr {
-				log.Lvl1((*grp)[i], ": ", v)
-			}
+		for _, group := range result.Groups {
+			log.Lvl1(group.Key, ": ", group.Operations)
 		}
-		if len(*aggr) != 0 {
-			weights := (*aggr)[0]
+		if len(result.Groups) != 0 {
+			weights := result.Groups[0].Operations[0].Values
 			if standardisationMode == 1 || standardisationMode == 2 {
 				means = nil
 				standardDeviations = nil
@@ -1809,7 +1848,6 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 
 func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 	t.Skip()
-	os.Remove("pre_compute_multiplications.gob")
 	log.SetDebugVisible(2)
 
 	//------SET PARAMS--------
@@ -1906,7 +1944,6 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 		standardDeviations = nil
 	}
 
-	lrParameters.FilePath = filePathTraining
 	lrParameters.NbrRecords = int64(len(XTrain))
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
@@ -1933,7 +1970,8 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 	}
 
 	local := onet.NewLocalTest(cothority.Suite)
-	elServers, elDPs, elVNs := generateNodes(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4})
+	elServers, elDPs, elVNs := generateNodesWithDatasets(local, nbrServers, nbrDPs, nbrVNs, [2]float64{3, 4},
+		map[string]provider.Loader{lrParameters.DatasetName: newLogisticRegressionLoader(lrParameters.DatasetName, filePathTraining, nbrDPs)})
 
 	if proofs == 0 {
 		elVNs = nil
@@ -1958,7 +1996,10 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 		minGenerateData := 3
 		maxGenerateData := 4
 		dimensions := 5
-		operation := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		operation, err := libdrynx.ChooseOperation(op, minGenerateData, maxGenerateData, dimensions, cuttingFactor)
+		if err != nil {
+			t.Fatal(err)
+		}
 		operation.LRParameters = lrParameters
 
 		// define the ranges for the input validation (1 range per data provider output)
@@ -2069,6 +2110,7 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 		surveyID := "query-" + op
 
 		sq := client.GenerateSurveyQuery(elServers, elVNs, dpToServers, idToPublic, surveyID, operation, ranges, ps, proofs, obfuscation, thresholdEntityProofsVerif, diffP, cuttingFactor)
+		selectLogisticRegression(&sq)
 		if !libdrynx.CheckParameters(sq, diffPri) {
 			log.Fatal("Oups!")
 		}
@@ -2102,22 +2144,18 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 		}
 
 		// send query and receive results
-		grp, aggr, err := client.SendSurveyQuery(sq)
+		result, err := client.SendSurveyQuery(sq)
 
 		if err != nil {
 			t.Fatal("'Drynx' service did not start.", err)
 		}
 
 		// Result printing
-		if len(*grp) != 0 && len(*grp) != len(*aggr) {
-			t.Fatal("Results format problem")
-		} else {
-			for i, v := range *aggr {
-				log.Lvl1((*grp)[i], ": ", v)
-			}
+		for _, group := range result.Groups {
+			log.Lvl1(group.Key, ": ", group.Operations)
 		}
-		if len(*aggr) != 0 {
-			weights := (*aggr)[0]
+		if len(result.Groups) != 0 {
+			weights := result.Groups[0].Operations[0].Values
 			if standardisationMode == 1 || standardisationMode == 2 {
 				means = nil
 				standardDeviations = nil
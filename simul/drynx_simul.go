@@ -3,7 +3,7 @@ package main
 import (
 	"github.com/ldsec/drynx/lib/range"
 	"go.dedis.ch/kyber/v3"
-	"os"
+	"strconv"
 
 	"sync"
 
@@ -11,6 +11,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/provider"
 	"github.com/ldsec/drynx/lib/provider/loaders"
 	"github.com/ldsec/drynx/lib/provider/neutralizers"
 	"github.com/ldsec/drynx/services"
@@ -74,7 +75,12 @@ func NewSimulationDrynx(config string) (onet.Simulation, error) {
 		return nil, err
 	}
 
-	loader, err := loaders.NewRandom(float64(sl.MinData), float64(sl.MaxData), sl.DPRows)
+	var loader provider.Loader
+	if sl.OperationName == "logistic regression" {
+		loader, err = newLogisticRegressionLoader(sl)
+	} else {
+		loader, err = loaders.NewRandom(float64(sl.MinData), float64(sl.MaxData), sl.DPRows)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -87,6 +93,37 @@ func NewSimulationDrynx(config string) (onet.Simulation, error) {
 	return sl, nil
 }
 
+// logisticRegressionColumns are the features then the label of the logistic regression dataset
+func logisticRegressionColumns(nbrFeatures int64) []libdrynx.ColumnID {
+	columns := make([]libdrynx.ColumnID, nbrFeatures+1)
+	for i := range columns[:nbrFeatures] {
+		columns[i] = libdrynx.ColumnID("feature" + strconv.Itoa(i))
+	}
+	columns[nbrFeatures] = "label"
+	return columns
+}
+
+// newLogisticRegressionLoader creates NbrRecords rows of DPRows-1 uniform features and a random label
+func newLogisticRegressionLoader(sl *SimulationDrynx) (provider.Loader, error) {
+	feature, err := loaders.NewUniform(float64(sl.MinData), float64(sl.MaxData))
+	if err != nil {
+		return nil, err
+	}
+	label, err := loaders.NewBernoulli(0.5)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := logisticRegressionColumns(int64(sl.DPRows) - 1)
+	columns := make([]loaders.SyntheticColumn, len(ids))
+	for i, id := range ids {
+		columns[i] = loaders.SyntheticColumn{ID: id, Distribution: feature}
+	}
+	columns[len(columns)-1].Distribution = label
+
+	return loaders.NewSynthetic(time.Now().UnixNano(), uint(sl.NbrRecords), columns, nil)
+}
+
 // Setup creates the tree used for that simulation
 func (sim *SimulationDrynx) Setup(dir string, hosts []string) (*onet.SimulationConfig, error) {
 	sc := &onet.SimulationConfig{}
@@ -104,7 +141,6 @@ func (sim *SimulationDrynx) Setup(dir string, hosts []string) (*onet.SimulationC
 
 // Run starts the simulation.
 func (sim *SimulationDrynx) Run(config *onet.SimulationConfig) error {
-	os.Remove("pre_compute_multiplications.gob")
 
 	// has to be set here because cannot be in toml file
 	diffP := libdrynx.QueryDiffP{LapMean: sim.DiffPEpsilon, LapScale: sim.DiffPDelta, Quanta: sim.DiffPQuanta, NoiseListSize: sim.DiffPSize, Scale: sim.DiffPScale, Limit: sim.DiffPLimit}
@@ -118,7 +154,6 @@ func (sim *SimulationDrynx) Run(config *onet.SimulationConfig) error {
 	}
 
 	lrParameters := libdrynx.LogisticRegressionParameters{
-		FilePath:                    "",
 		NbrRecords:                  int64(sim.NbrRecords),
 		NbrFeatures:                 m,
 		Means:                       means,
@@ -368,6 +403,9 @@ func (sim *SimulationDrynx) Run(config *onet.SimulationConfig) error {
 		thresholdEntityProofsVerif = []float64{sim.ThresholdGeneral, sim.ThresholdOther, sim.ThresholdOther, sim.ThresholdOther, sim.ThresholdOther}
 	}
 	sq := client.GenerateSurveyQuery(rosterServers, rosterVNs, dpToServers, idToPublic, surveyID, operation, ranges, ps, sim.Proofs, sim.Obfuscation, thresholdEntityProofsVerif, diffP, sim.CuttingFactor)
+	if sim.OperationName == "logistic regression" {
+		sq.Query.Selector = logisticRegressionColumns(m)
+	}
 	if !libdrynx.CheckParameters(sq, diffP.NoiseListSize > 0) {
 		log.Fatal("Oups!")
 	}
@@ -405,19 +443,15 @@ func (sim *SimulationDrynx) Run(config *onet.SimulationConfig) error {
 	}
 
 	// send query and receive results
-	grp, aggr, err := client.SendSurveyQuery(sq)
+	result, err := client.SendSurveyQuery(sq)
 
 	if err != nil {
 		log.Fatal("'Drynx' service did not start.", err)
 	}
 
 	// Result printing
-	if len(*grp) != 0 && len(*grp) != len(*aggr) {
-		log.Fatal("Results format problem")
-	} else {
-		for i, v := range *aggr {
-			log.Lvl1((*grp)[i], ": ", v)
-		}
+	for _, group := range result.Groups {
+		log.Lvl1(group.Key, ": ", group.Operations)
 	}
 
 	if len(elVNs) > 0 {
@@ -2,6 +2,7 @@ set -eumo pipefail
 
 readonly node_count=5
 readonly host_name=localhost
+neutralizer='minimum-results-size 0'
 
 tmpdir=$(mktemp -d)
 cd "$tmpdir"
@@ -43,6 +44,18 @@ readonly port_base=$((RANDOM + 1024))
 readonly port_top=$((port_base + 2*node_count - 1))
 nodes=''
 publics=''
+# columns of the random loader, redefine to add some
+random_columns() {
+	cat
+}
+# datasets to serve besides the default one, redefine to add some
+more_datasets() {
+	cat
+}
+# settings of the nodes besides their roles, redefine to add some
+more_settings() {
+	cat
+}
 start_nodes() {
 	local loader=random
 	if [ $# -eq 1 ]
@@ -61,9 +74,12 @@ start_nodes() {
 
 		echo "$node_conf" |
 				server data-provider new $loader |
-					server data-provider set-neutralizer minimum-results-size 0 |
+					random_columns |
+					server data-provider set-neutralizer $neutralizer |
+				more_datasets |
 				server computing-node new |
 				server verifying-node new |
+				more_settings |
 				DEBUG_COLOR=true server run &
 		nodes+=" $!"
 	done